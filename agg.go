@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/venzy/gator/internal/config"
+)
+
+func handlerAgg(s *state, cmd command) error {
+	timeBetweenReqs, err := time.ParseDuration(cmd.args[0])
+	if err != nil {
+		return fmt.Errorf("Invalid time-between-requests argument (duration) '%s' - %s\n", cmd.args[0], err)
+	}
+
+	pidFilePath := cmd.stringFlag("pidfile")
+	if pidFilePath == "" {
+		configPath, err := config.FilePath()
+		if err != nil {
+			return err
+		}
+		pidFilePath = filepath.Join(filepath.Dir(configPath), ".gator-agg.pid")
+	}
+	pidFile, err := lockPidFile(pidFilePath)
+	if err != nil {
+		return err
+	}
+	defer pidFile.release()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+	defer signal.Stop(reload)
+
+	fmt.Printf("Collecting feeds every %s (pid %d)\n", timeBetweenReqs, os.Getpid())
+
+	aggLoop(ctx, s, timeBetweenReqs, reload)
+	return nil
+}
+
+// Fetches a feed every period until ctx is cancelled, letting any fetch in
+// progress finish first. Anything arriving on reload re-reads the config file.
+func aggLoop(ctx context.Context, s *state, period time.Duration, reload <-chan os.Signal) {
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+
+	for {
+		// The fetch carries on after agg is asked to stop
+		fetchCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), feedFetchTimeout)
+		err := scapeFeeds(fetchCtx, s)
+		if errors.Is(err, sql.ErrNoRows) {
+			fmt.Println("No feeds to collect yet")
+		} else if err != nil {
+			fmt.Printf("Problem collecting feed: %v\n", err)
+		}
+		cancel()
+		// Outside the fetch's time limit, as each webhook gets its own
+		sendWebhooks(ctx, s)
+
+	wait:
+		for {
+			select {
+			case <-ctx.Done():
+				fmt.Println("Stopped collecting feeds")
+				return
+			case <-reload:
+				reloadConfig(s)
+			case <-ticker.C:
+				break wait
+			}
+		}
+	}
+}
+
+func reloadConfig(s *state) {
+	cfg, err := config.Read()
+	if err != nil {
+		fmt.Printf("Problem reloading config, carrying on with the old one: %v\n", err)
+		return
+	}
+
+	if cfg.DbUrl != s.cfg.DbUrl {
+		fmt.Println("Database URL in config has changed - restart agg to use it")
+		cfg.DbUrl = s.cfg.DbUrl
+	}
+	*s.cfg = cfg
+	fmt.Println("Reloaded config")
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAggLoopFinishesFetchWhenStopped(t *testing.T) {
+	server := newFeedServer(t)
+
+	s := newMemoryState(t)
+	mustRun(t, s, "register", "alice")
+	mustRun(t, s, "addfeed", "Test", server.URL+"/feeds/rss.xml")
+
+	// Already stopped, but the first fetch is under way before that's noticed
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	captureStdout(t, func() { aggLoop(ctx, s, time.Hour, nil) })
+
+	if posts := runListing[postRecord](t, s, "browse", "10"); len(posts) != 3 {
+		t.Errorf("got %d posts, want 3", len(posts))
+	}
+}
+
+func TestAggLoopReloadsConfig(t *testing.T) {
+	s := newMemoryState(t)
+	s.cfg.DbUrl = "sqlite://old.db"
+	s.cfg.CurrentUserName = "alice"
+
+	configPath := filepath.Join(os.Getenv("HOME"), ".gatorconfig.json")
+	config := `{"db_url": "sqlite://new.db", "current_user_name": "bob"}`
+	if err := os.WriteFile(configPath, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	reload := make(chan os.Signal)
+	done := make(chan struct{})
+	captureStdout(t, func() {
+		go func() {
+			aggLoop(ctx, s, time.Hour, reload)
+			close(done)
+		}()
+		reload <- os.Interrupt
+		cancel()
+		<-done
+	})
+
+	if s.cfg.CurrentUserName != "bob" {
+		t.Errorf("got current user %q after reload, want bob", s.cfg.CurrentUserName)
+	}
+	// Changing database needs a restart
+	if s.cfg.DbUrl != "sqlite://old.db" {
+		t.Errorf("got database URL %q after reload, want it unchanged", s.cfg.DbUrl)
+	}
+}
+
+func TestLockPidFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "agg.pid")
+
+	first, err := lockPidFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lockPidFile(path); err == nil {
+		t.Fatal("expected an error locking a pidfile that's already locked")
+	}
+
+	first.release()
+	second, err := lockPidFile(path)
+	if err != nil {
+		t.Fatalf("locking a released pidfile: %v", err)
+	}
+	second.release()
+}
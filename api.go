@@ -0,0 +1,618 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/venzy/gator/internal/database"
+)
+
+// Default and maximum number of posts in a page from the API
+const (
+	apiDefaultLimit = 20
+	apiMaxLimit     = 200
+)
+
+// Version 1 of the JSON API. Changes that would break clients go in a new
+// version alongside it.
+func (srv *server) registerAPIRoutes() {
+	srv.mux.HandleFunc("GET /api/v1/me", srv.withUser(srv.apiMe))
+	srv.mux.HandleFunc("GET /api/v1/users", srv.withUser(srv.apiUsers))
+
+	srv.mux.HandleFunc("GET /api/v1/feeds", srv.withUser(srv.apiFeeds))
+	srv.mux.HandleFunc("POST /api/v1/feeds", srv.withUser(srv.apiAddFeed))
+	srv.mux.HandleFunc("GET /api/v1/feeds/{feedID}", srv.withUser(srv.apiFeed))
+
+	srv.mux.HandleFunc("GET /api/v1/follows", srv.withUser(srv.apiFollows))
+	srv.mux.HandleFunc("POST /api/v1/follows", srv.withUser(srv.apiFollow))
+	srv.mux.HandleFunc("PUT /api/v1/follows/{feedID}", srv.withUser(srv.apiUpdateFollow))
+	srv.mux.HandleFunc("DELETE /api/v1/follows/{feedID}", srv.withUser(srv.apiUnfollow))
+
+	srv.mux.HandleFunc("GET /api/v1/posts", srv.withUser(srv.apiPosts))
+	srv.mux.HandleFunc("GET /api/v1/search", srv.withUser(srv.apiSearch))
+	srv.mux.HandleFunc("GET /api/v1/posts/{postID}", srv.withUser(srv.apiPost))
+	srv.mux.HandleFunc("PUT /api/v1/posts/{postID}/read", srv.withUser(srv.apiSetPostState(true, postRead)))
+	srv.mux.HandleFunc("DELETE /api/v1/posts/{postID}/read", srv.withUser(srv.apiSetPostState(false, postRead)))
+	srv.mux.HandleFunc("PUT /api/v1/posts/{postID}/star", srv.withUser(srv.apiSetPostState(true, postStarred)))
+	srv.mux.HandleFunc("DELETE /api/v1/posts/{postID}/star", srv.withUser(srv.apiSetPostState(false, postStarred)))
+	srv.mux.HandleFunc("PUT /api/v1/posts/{postID}/tags/{tag}", srv.withUser(srv.apiTagPost))
+	srv.mux.HandleFunc("DELETE /api/v1/posts/{postID}/tags/{tag}", srv.withUser(srv.apiUntagPost))
+
+	srv.mux.HandleFunc("GET /api/v1/tags", srv.withUser(srv.apiTags))
+
+	// JSON rather than the mux's plain text for anything else under the API
+	srv.mux.HandleFunc("/api/", func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, notFound("No such API endpoint %s %s", r.Method, r.URL.Path))
+	})
+}
+
+type apiUser struct {
+	ID        uuid.UUID `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type apiFeed struct {
+	ID            uuid.UUID  `json:"id"`
+	Name          string     `json:"name"`
+	Url           string     `json:"url"`
+	AddedBy       uuid.UUID  `json:"added_by"`
+	AddedAt       time.Time  `json:"added_at"`
+	LastFetchedAt *time.Time `json:"last_fetched_at"`
+}
+
+type apiFollow struct {
+	FeedID     uuid.UUID `json:"feed_id"`
+	Feed       string    `json:"feed"`
+	Url        string    `json:"url"`
+	Folder     string    `json:"folder"`
+	FollowedAt time.Time `json:"followed_at"`
+}
+
+type apiPost struct {
+	ID        uuid.UUID `json:"id"`
+	FeedID    uuid.UUID `json:"feed_id"`
+	Feed      string    `json:"feed"`
+	Folder    string    `json:"folder"`
+	Title     string    `json:"title"`
+	Url       string    `json:"url"`
+	Published time.Time `json:"published"`
+	Fetched   time.Time `json:"fetched"`
+	Read      bool      `json:"read"`
+	Starred   bool      `json:"starred"`
+	Text      string    `json:"text"`
+	// Only for a single post, as they can be long
+	Html string   `json:"html,omitempty"`
+	Tags []string `json:"tags,omitempty"`
+}
+
+type apiPostPage struct {
+	Posts []apiPost `json:"posts"`
+	// Pass as after= to get the next page, when there may be one
+	Next string `json:"next,omitempty"`
+}
+
+func toAPIUser(user database.User) apiUser {
+	return apiUser{ID: user.ID, Name: user.Name, CreatedAt: user.CreatedAt}
+}
+
+func toAPIFeed(feed database.Feed) apiFeed {
+	record := apiFeed{
+		ID:      feed.ID,
+		Name:    feed.Name,
+		Url:     feed.Url,
+		AddedBy: feed.UserID,
+		AddedAt: feed.CreatedAt,
+	}
+	if feed.LastFetchedAt.Valid {
+		record.LastFetchedAt = &feed.LastFetchedAt.Time
+	}
+	return record
+}
+
+func (srv *server) apiMe(w http.ResponseWriter, r *http.Request, user database.User) error {
+	writeJSON(w, http.StatusOK, toAPIUser(user))
+	return nil
+}
+
+func (srv *server) apiUsers(w http.ResponseWriter, r *http.Request, _ database.User) error {
+	users, err := srv.s.db.GetUsers(r.Context())
+	if err != nil {
+		return fmt.Errorf("Problem fetching users: %v", err)
+	}
+
+	records := make([]apiUser, 0, len(users))
+	for _, user := range users {
+		records = append(records, toAPIUser(user))
+	}
+	writeJSON(w, http.StatusOK, records)
+	return nil
+}
+
+func (srv *server) apiFeeds(w http.ResponseWriter, r *http.Request, _ database.User) error {
+	feeds, err := srv.s.db.GetFeeds(r.Context())
+	if err != nil {
+		return fmt.Errorf("Problem fetching all feeds: %v", err)
+	}
+
+	records := make([]apiFeed, 0, len(feeds))
+	for _, feed := range feeds {
+		records = append(records, toAPIFeed(feed))
+	}
+	writeJSON(w, http.StatusOK, records)
+	return nil
+}
+
+func (srv *server) apiFeed(w http.ResponseWriter, r *http.Request, _ database.User) error {
+	feed, err := srv.feedFromPath(r)
+	if err != nil {
+		return err
+	}
+	writeJSON(w, http.StatusOK, toAPIFeed(feed))
+	return nil
+}
+
+// Adds a feed and follows it, as addfeed does
+func (srv *server) apiAddFeed(w http.ResponseWriter, r *http.Request, user database.User) error {
+	var body struct {
+		Name string `json:"name"`
+		Url  string `json:"url"`
+	}
+	if err := decodeBody(w, r, &body); err != nil {
+		return err
+	}
+	if body.Name == "" || body.Url == "" {
+		return badRequest("A feed needs a name and a url")
+	}
+
+	if _, err := srv.s.db.GetFeedByURL(r.Context(), body.Url); err == nil {
+		return conflict("Feed URL '%s' has already been added", body.Url)
+	}
+
+	now := time.Now()
+	feed, err := srv.s.db.CreateFeed(
+		r.Context(),
+		database.CreateFeedParams{
+			ID:        uuid.New(),
+			CreatedAt: now,
+			UpdatedAt: now,
+			Name:      body.Name,
+			Url:       body.Url,
+			UserID:    user.ID,
+		})
+	if err != nil {
+		return fmt.Errorf("Problem creating feed: %v", err)
+	}
+
+	_, err = srv.s.db.CreateFeedFollow(
+		r.Context(),
+		database.CreateFeedFollowParams{
+			ID:        uuid.New(),
+			CreatedAt: now,
+			UpdatedAt: now,
+			UserID:    user.ID,
+			FeedID:    feed.ID,
+		})
+	if err != nil {
+		return fmt.Errorf("Problem creating new follow: %v", err)
+	}
+
+	writeJSON(w, http.StatusCreated, toAPIFeed(feed))
+	return nil
+}
+
+func (srv *server) apiFollows(w http.ResponseWriter, r *http.Request, user database.User) error {
+	follows, err := srv.s.db.GetFeedFollowsForUser(r.Context(), user.ID)
+	if err != nil {
+		return fmt.Errorf("Problem fetching follows for user '%s': %v", user.Name, err)
+	}
+
+	records := make([]apiFollow, 0, len(follows))
+	for _, follow := range follows {
+		records = append(records, apiFollow{
+			FeedID:     follow.FeedID,
+			Feed:       follow.FeedName,
+			Url:        follow.FeedUrl,
+			Folder:     follow.Folder.String,
+			FollowedAt: follow.CreatedAt,
+		})
+	}
+	writeJSON(w, http.StatusOK, records)
+	return nil
+}
+
+func (srv *server) apiFollow(w http.ResponseWriter, r *http.Request, user database.User) error {
+	var body struct {
+		FeedID uuid.UUID `json:"feed_id"`
+		Folder string    `json:"folder"`
+	}
+	if err := decodeBody(w, r, &body); err != nil {
+		return err
+	}
+
+	feed, err := srv.s.db.GetFeedByID(r.Context(), body.FeedID)
+	if err != nil {
+		return notFound("Feed '%s' not in database!", body.FeedID)
+	}
+	if _, err := srv.findFollow(r, user, feed.ID); err == nil {
+		return conflict("User '%s' is already following '%s'", user.Name, feed.Url)
+	}
+
+	now := time.Now()
+	_, err = srv.s.db.CreateFeedFollow(
+		r.Context(),
+		database.CreateFeedFollowParams{
+			ID:        uuid.New(),
+			CreatedAt: now,
+			UpdatedAt: now,
+			UserID:    user.ID,
+			FeedID:    feed.ID,
+		})
+	if err != nil {
+		return fmt.Errorf("Problem creating feed_follows record for user '%s' and feed URL '%s': %v", user.Name, feed.Url, err)
+	}
+
+	if body.Folder != "" {
+		if err := srv.setFolder(r, user, feed, body.Folder); err != nil {
+			return err
+		}
+	}
+
+	follow, err := srv.findFollow(r, user, feed.ID)
+	if err != nil {
+		return err
+	}
+	writeJSON(w, http.StatusCreated, follow)
+	return nil
+}
+
+// Only the folder can be changed - an empty one takes the feed out of its
+// folder
+func (srv *server) apiUpdateFollow(w http.ResponseWriter, r *http.Request, user database.User) error {
+	feed, err := srv.feedFromPath(r)
+	if err != nil {
+		return err
+	}
+
+	var body struct {
+		Folder string `json:"folder"`
+	}
+	if err := decodeBody(w, r, &body); err != nil {
+		return err
+	}
+	if err := srv.setFolder(r, user, feed, body.Folder); err != nil {
+		return err
+	}
+
+	follow, err := srv.findFollow(r, user, feed.ID)
+	if err != nil {
+		return err
+	}
+	writeJSON(w, http.StatusOK, follow)
+	return nil
+}
+
+func (srv *server) apiUnfollow(w http.ResponseWriter, r *http.Request, user database.User) error {
+	feed, err := srv.feedFromPath(r)
+	if err != nil {
+		return err
+	}
+	if _, err := srv.findFollow(r, user, feed.ID); err != nil {
+		return err
+	}
+
+	err = srv.s.db.DeleteFeedFollow(
+		r.Context(),
+		database.DeleteFeedFollowParams{
+			UserID: user.ID,
+			FeedID: feed.ID,
+		})
+	if err != nil {
+		return fmt.Errorf("Problem unfollowing '%s' for user '%s': %v", feed.Url, user.Name, err)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (srv *server) setFolder(r *http.Request, user database.User, feed database.Feed, folder string) error {
+	updated, err := srv.s.db.SetFeedFollowFolder(
+		r.Context(),
+		database.SetFeedFollowFolderParams{
+			UserID:    user.ID,
+			FeedID:    feed.ID,
+			Folder:    sql.NullString{String: folder, Valid: folder != ""},
+			UpdatedAt: time.Now(),
+		})
+	if err != nil {
+		return fmt.Errorf("Problem setting folder for '%s' for user '%s': %v", feed.Url, user.Name, err)
+	}
+	if updated == 0 {
+		return notFound("User '%s' is not following '%s'", user.Name, feed.Url)
+	}
+	return nil
+}
+
+func (srv *server) findFollow(r *http.Request, user database.User, feedID uuid.UUID) (apiFollow, error) {
+	follows, err := srv.s.db.GetFeedFollowsForUser(r.Context(), user.ID)
+	if err != nil {
+		return apiFollow{}, fmt.Errorf("Problem fetching follows for user '%s': %v", user.Name, err)
+	}
+	for _, follow := range follows {
+		if follow.FeedID == feedID {
+			return apiFollow{
+				FeedID:     follow.FeedID,
+				Feed:       follow.FeedName,
+				Url:        follow.FeedUrl,
+				Folder:     follow.Folder.String,
+				FollowedAt: follow.CreatedAt,
+			}, nil
+		}
+	}
+	return apiFollow{}, notFound("User '%s' is not following feed '%s'", user.Name, feedID)
+}
+
+func (srv *server) feedFromPath(r *http.Request) (database.Feed, error) {
+	feedID, err := uuid.Parse(r.PathValue("feedID"))
+	if err != nil {
+		return database.Feed{}, badRequest("Invalid feed ID '%s'", r.PathValue("feedID"))
+	}
+	feed, err := srv.s.db.GetFeedByID(r.Context(), feedID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.Feed{}, notFound("Feed '%s' not in database!", feedID)
+	} else if err != nil {
+		return database.Feed{}, fmt.Errorf("Problem fetching feed '%s': %v", feedID, err)
+	}
+	return feed, nil
+}
+
+// Takes the same filters as browse, named as its flags are, plus a limit
+// (default 20)
+func (srv *server) apiPosts(w http.ResponseWriter, r *http.Request, user database.User) error {
+	return srv.writePostPage(w, r, user)
+}
+
+// Posts with the text q in their title or content, filtered as for posts
+func (srv *server) apiSearch(w http.ResponseWriter, r *http.Request, user database.User) error {
+	if r.URL.Query().Get("q") == "" {
+		return badRequest("Search needs some text to look for, in q")
+	}
+	return srv.writePostPage(w, r, user)
+}
+
+func (srv *server) writePostPage(w http.ResponseWriter, r *http.Request, user database.User) error {
+	filters, err := browseFiltersFromQuery(r)
+	if err != nil {
+		return err
+	}
+	params, err := filters.params(user.ID, time.Now())
+	if err != nil {
+		return badRequest("%v", err)
+	}
+
+	posts, err := srv.s.db.BrowsePostsForUser(r.Context(), params)
+	if err != nil {
+		return fmt.Errorf("Problem fetching posts for user '%s': %v", user.Name, err)
+	}
+
+	page := apiPostPage{Posts: make([]apiPost, 0, len(posts))}
+	for _, post := range posts {
+		page.Posts = append(page.Posts, apiPost{
+			ID:        post.ID,
+			FeedID:    post.FeedID,
+			Feed:      post.FeedName,
+			Folder:    post.Folder.String,
+			Title:     post.Title,
+			Url:       post.Url,
+			Published: post.PublishedAt,
+			Fetched:   post.CreatedAt,
+			Read:      post.IsRead,
+			Starred:   post.IsStarred,
+			Text:      post.DescriptionText.String,
+		})
+	}
+	if len(posts) == int(filters.Limit) {
+		last := posts[len(posts)-1]
+		page.Next = encodeBrowseCursor(last.SortTime, last.ID, filters.Sort)
+	}
+
+	writeJSON(w, http.StatusOK, page)
+	return nil
+}
+
+func browseFiltersFromQuery(r *http.Request) (browseFilters, error) {
+	query := r.URL.Query()
+	filters := browseFilters{
+		Feed:   query.Get("feed"),
+		Folder: query.Get("folder"),
+		Since:  query.Get("since"),
+		Until:  query.Get("until"),
+		Tag:    query.Get("tag"),
+		Search: query.Get("q"),
+		Sort:   query.Get("sort"),
+		After:  query.Get("after"),
+		Limit:  apiDefaultLimit,
+	}
+	if filters.Sort == "" {
+		filters.Sort = "published"
+	}
+
+	for name, target := range map[string]*bool{"unread": &filters.Unread, "starred": &filters.Starred} {
+		if value := query.Get(name); value != "" {
+			parsed, err := strconv.ParseBool(value)
+			if err != nil {
+				return filters, badRequest("Invalid %s '%s': must be true or false", name, value)
+			}
+			*target = parsed
+		}
+	}
+
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > apiMaxLimit {
+			return filters, badRequest("Invalid limit '%s': must be from 1 to %d", value, apiMaxLimit)
+		}
+		filters.Limit = int32(limit)
+	}
+
+	return filters, nil
+}
+
+// A single post, with its HTML and tags
+func (srv *server) apiPost(w http.ResponseWriter, r *http.Request, user database.User) error {
+	post, err := srv.postFromPath(r, user)
+	if err != nil {
+		return err
+	}
+	return srv.writePost(w, r, user, post.ID)
+}
+
+// Which per-user state of a post to set
+type postState int
+
+const (
+	postRead postState = iota
+	postStarred
+)
+
+func (srv *server) apiSetPostState(set bool, which postState) apiHandler {
+	return func(w http.ResponseWriter, r *http.Request, user database.User) error {
+		post, err := srv.postFromPath(r, user)
+		if err != nil {
+			return err
+		}
+		if err := srv.setPostState(r, user, post.ID, which, set); err != nil {
+			return fmt.Errorf("Problem updating post '%s': %v", post.Title, err)
+		}
+
+		return srv.writePost(w, r, user, post.ID)
+	}
+}
+
+func (srv *server) setPostState(r *http.Request, user database.User, postID uuid.UUID, which postState, set bool) error {
+	var err error
+	switch {
+	case which == postRead && set:
+		err = srv.s.db.MarkPostRead(r.Context(), database.MarkPostReadParams{ID: uuid.New(), CreatedAt: time.Now(), UserID: user.ID, PostID: postID})
+	case which == postRead:
+		_, err = srv.s.db.MarkPostUnread(r.Context(), database.MarkPostUnreadParams{UserID: user.ID, PostID: postID})
+	case set:
+		err = srv.s.db.StarPost(r.Context(), database.StarPostParams{ID: uuid.New(), CreatedAt: time.Now(), UserID: user.ID, PostID: postID})
+	default:
+		_, err = srv.s.db.UnstarPost(r.Context(), database.UnstarPostParams{UserID: user.ID, PostID: postID})
+	}
+	return err
+}
+
+func (srv *server) apiTagPost(w http.ResponseWriter, r *http.Request, user database.User) error {
+	post, err := srv.postFromPath(r, user)
+	if err != nil {
+		return err
+	}
+	tag, err := normaliseTag(r.PathValue("tag"))
+	if err != nil {
+		return badRequest("%v", err)
+	}
+
+	err = srv.s.db.CreatePostTag(
+		r.Context(),
+		database.CreatePostTagParams{
+			ID:        uuid.New(),
+			CreatedAt: time.Now(),
+			UserID:    user.ID,
+			PostID:    post.ID,
+			Tag:       tag,
+		})
+	if err != nil {
+		return fmt.Errorf("Problem tagging post '%s' with '%s': %v", post.Title, tag, err)
+	}
+
+	return srv.writePost(w, r, user, post.ID)
+}
+
+func (srv *server) apiUntagPost(w http.ResponseWriter, r *http.Request, user database.User) error {
+	post, err := srv.postFromPath(r, user)
+	if err != nil {
+		return err
+	}
+	tag, err := normaliseTag(r.PathValue("tag"))
+	if err != nil {
+		return badRequest("%v", err)
+	}
+
+	_, err = srv.s.db.DeletePostTag(
+		r.Context(),
+		database.DeletePostTagParams{
+			UserID: user.ID,
+			PostID: post.ID,
+			Tag:    tag,
+		})
+	if err != nil {
+		return fmt.Errorf("Problem removing tag '%s' from post '%s': %v", tag, post.Title, err)
+	}
+
+	return srv.writePost(w, r, user, post.ID)
+}
+
+func (srv *server) apiTags(w http.ResponseWriter, r *http.Request, user database.User) error {
+	counts, err := srv.s.db.GetTagCountsForUser(r.Context(), user.ID)
+	if err != nil {
+		return fmt.Errorf("Problem fetching tags for user '%s': %v", user.Name, err)
+	}
+
+	records := make([]tagCountRecord, 0, len(counts))
+	for _, count := range counts {
+		records = append(records, tagCountRecord{Tag: count.Tag, Posts: count.PostCount})
+	}
+	writeJSON(w, http.StatusOK, records)
+	return nil
+}
+
+// Posts in feeds the user doesn't follow are as good as missing
+func (srv *server) postFromPath(r *http.Request, user database.User) (database.GetPostForUserRow, error) {
+	postID, err := uuid.Parse(r.PathValue("postID"))
+	if err != nil {
+		return database.GetPostForUserRow{}, badRequest("Invalid post ID '%s'", r.PathValue("postID"))
+	}
+	post, err := srv.s.db.GetPostForUser(r.Context(), database.GetPostForUserParams{UserID: user.ID, ID: postID})
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.GetPostForUserRow{}, notFound("Post '%s' not found in feeds followed by '%s'", postID, user.Name)
+	} else if err != nil {
+		return database.GetPostForUserRow{}, fmt.Errorf("Problem fetching post '%s': %v", postID, err)
+	}
+	return post, nil
+}
+
+// Writes a post as it is now, re-reading it after any changes
+func (srv *server) writePost(w http.ResponseWriter, r *http.Request, user database.User, postID uuid.UUID) error {
+	post, err := srv.s.db.GetPostForUser(r.Context(), database.GetPostForUserParams{UserID: user.ID, ID: postID})
+	if err != nil {
+		return fmt.Errorf("Problem fetching post '%s': %v", postID, err)
+	}
+	tags, err := srv.s.db.GetTagsForPost(r.Context(), database.GetTagsForPostParams{UserID: user.ID, PostID: post.ID})
+	if err != nil {
+		return fmt.Errorf("Problem fetching tags for post '%s': %v", post.Title, err)
+	}
+
+	writeJSON(w, http.StatusOK, apiPost{
+		ID:        post.ID,
+		FeedID:    post.FeedID,
+		Feed:      post.FeedName,
+		Folder:    post.Folder.String,
+		Title:     post.Title,
+		Url:       post.Url,
+		Published: post.PublishedAt,
+		Fetched:   post.CreatedAt,
+		Read:      post.IsRead,
+		Starred:   post.IsStarred,
+		Text:      post.DescriptionText.String,
+		Html:      post.Description.String,
+		Tags:      tags,
+	})
+	return nil
+}
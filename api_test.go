@@ -0,0 +1,268 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// Creates an API token for the logged-in user
+func createToken(t *testing.T, s *state, name string, scope string) string {
+	t.Helper()
+
+	output := strings.TrimSpace(mustRun(t, s, "token", "create", "--scope", scope, name))
+	return output[strings.LastIndex(output, "\n")+1:]
+}
+
+// Makes an API request with a token, decoding the JSON response into out if
+// it's not nil, and returning the status
+func apiRequest(t *testing.T, baseURL string, token string, method string, path string, body any, out any) int {
+	t.Helper()
+
+	var encoded []byte
+	if body != nil {
+		var err error
+		if encoded, err = json.Marshal(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	req, err := http.NewRequest(method, baseURL+path, bytes.NewReader(encoded))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	if out != nil && res.StatusCode < 300 {
+		if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+			t.Fatalf("%s %s: problem decoding response: %v", method, path, err)
+		}
+	}
+	return res.StatusCode
+}
+
+func apiPostTitles(posts []apiPost) []string {
+	var titles []string
+	for _, post := range posts {
+		titles = append(titles, post.Title)
+	}
+	return titles
+}
+
+func TestAPIFeedsAndFollows(t *testing.T) {
+	feeds := newFeedServer(t)
+
+	for name, newState := range testStates {
+		t.Run(name, func(t *testing.T) {
+			s := newState(t)
+			mustRun(t, s, "register", "alice")
+			token := createToken(t, s, "test", scopeWrite)
+			api := httptest.NewServer(newServer(s))
+			defer api.Close()
+
+			var me apiUser
+			if status := apiRequest(t, api.URL, token, "GET", "/api/v1/me", nil, &me); status != http.StatusOK || me.Name != "alice" {
+				t.Errorf("got %d %+v for me, want alice", status, me)
+			}
+
+			var feed apiFeed
+			body := map[string]string{"name": "Test", "url": feeds.URL + "/feeds/rss.xml"}
+			if status := apiRequest(t, api.URL, token, "POST", "/api/v1/feeds", body, &feed); status != http.StatusCreated {
+				t.Fatalf("got status %d adding a feed", status)
+			}
+			if status := apiRequest(t, api.URL, token, "POST", "/api/v1/feeds", body, nil); status != http.StatusConflict {
+				t.Errorf("got status %d adding a feed twice, want 409", status)
+			}
+			if status := apiRequest(t, api.URL, token, "POST", "/api/v1/feeds", map[string]string{"nmae": "Typo"}, nil); status != http.StatusBadRequest {
+				t.Errorf("got status %d for an unknown field, want 400", status)
+			}
+
+			var follows []apiFollow
+			apiRequest(t, api.URL, token, "GET", "/api/v1/follows", nil, &follows)
+			if len(follows) != 1 || follows[0].FeedID != feed.ID {
+				t.Errorf("got follows %+v, want just the new feed", follows)
+			}
+
+			var follow apiFollow
+			if status := apiRequest(t, api.URL, token, "PUT", "/api/v1/follows/"+feed.ID.String(), map[string]string{"folder": "news"}, &follow); status != http.StatusOK || follow.Folder != "news" {
+				t.Errorf("got %d %+v filing the feed, want it in news", status, follow)
+			}
+
+			// Bob follows alice's feed by ID
+			mustRun(t, s, "register", "bob")
+			token = createToken(t, s, "test", scopeWrite)
+			if status := apiRequest(t, api.URL, token, "POST", "/api/v1/follows", map[string]any{"feed_id": feed.ID, "folder": "tech"}, &follow); status != http.StatusCreated || follow.Folder != "tech" {
+				t.Errorf("got %d %+v following, want a follow in tech", status, follow)
+			}
+			if status := apiRequest(t, api.URL, token, "POST", "/api/v1/follows", map[string]any{"feed_id": feed.ID}, nil); status != http.StatusConflict {
+				t.Errorf("got status %d following twice, want 409", status)
+			}
+
+			if status := apiRequest(t, api.URL, token, "DELETE", "/api/v1/follows/"+feed.ID.String(), nil, nil); status != http.StatusNoContent {
+				t.Errorf("got status %d unfollowing", status)
+			}
+			if status := apiRequest(t, api.URL, token, "DELETE", "/api/v1/follows/"+feed.ID.String(), nil, nil); status != http.StatusNotFound {
+				t.Errorf("got status %d unfollowing again, want 404", status)
+			}
+			if status := apiRequest(t, api.URL, token, "GET", "/api/v1/feeds/nonsense", nil, nil); status != http.StatusBadRequest {
+				t.Errorf("got status %d for a bad feed ID, want 400", status)
+			}
+			if status := apiRequest(t, api.URL, token, "GET", "/api/v1/nowhere", nil, nil); status != http.StatusNotFound {
+				t.Errorf("got status %d for an unknown endpoint, want 404", status)
+			}
+		})
+	}
+}
+
+func TestAPIPosts(t *testing.T) {
+	feeds := newFeedServer(t)
+
+	for name, newState := range testStates {
+		t.Run(name, func(t *testing.T) {
+			s := newState(t)
+			mustRun(t, s, "register", "alice")
+			mustRun(t, s, "addfeed", "Test", feeds.URL+"/feeds/rss.xml")
+			captureStdout(t, func() { scapeFeeds(context.Background(), s) })
+			token := createToken(t, s, "test", scopeWrite)
+			api := httptest.NewServer(newServer(s))
+			defer api.Close()
+
+			// Paging through with the next cursor
+			var page apiPostPage
+			apiRequest(t, api.URL, token, "GET", "/api/v1/posts?limit=2", nil, &page)
+			if page.Next == "" {
+				t.Fatalf("no next cursor on a full page: %+v", page)
+			}
+			var nextPage apiPostPage
+			apiRequest(t, api.URL, token, "GET", "/api/v1/posts?limit=2&after="+page.Next, nil, &nextPage)
+			got := append(apiPostTitles(page.Posts), apiPostTitles(nextPage.Posts)...)
+			if want := []string{"Undated Post", "Second Post", "First Post"}; !slices.Equal(got, want) {
+				t.Errorf("got pages %v, want %v", got, want)
+			}
+			if nextPage.Next != "" {
+				t.Errorf("got a next cursor on the last page")
+			}
+
+			second := page.Posts[1]
+			var post apiPost
+			if status := apiRequest(t, api.URL, token, "PUT", "/api/v1/posts/"+second.ID.String()+"/read", nil, &post); status != http.StatusOK || !post.Read {
+				t.Errorf("got %d %+v marking read", status, post)
+			}
+			apiRequest(t, api.URL, token, "PUT", "/api/v1/posts/"+second.ID.String()+"/star", nil, &post)
+			apiRequest(t, api.URL, token, "PUT", "/api/v1/posts/"+second.ID.String()+"/tags/Later", nil, &post)
+			if !post.Starred || !slices.Equal(post.Tags, []string{"later"}) {
+				t.Errorf("got %+v, want it starred and tagged", post)
+			}
+
+			apiRequest(t, api.URL, token, "GET", "/api/v1/posts?unread=true", nil, &page)
+			if got := apiPostTitles(page.Posts); !slices.Equal(got, []string{"Undated Post", "First Post"}) {
+				t.Errorf("unread: got %v", got)
+			}
+			apiRequest(t, api.URL, token, "GET", "/api/v1/posts?starred=true&tag=later", nil, &page)
+			if got := apiPostTitles(page.Posts); !slices.Equal(got, []string{"Second Post"}) {
+				t.Errorf("starred and tagged: got %v", got)
+			}
+
+			apiRequest(t, api.URL, token, "DELETE", "/api/v1/posts/"+second.ID.String()+"/read", nil, &post)
+			if post.Read {
+				t.Errorf("got %+v, want it unread", post)
+			}
+
+			apiRequest(t, api.URL, token, "GET", "/api/v1/search?q=second", nil, &page)
+			if got := apiPostTitles(page.Posts); !slices.Equal(got, []string{"Second Post"}) {
+				t.Errorf("search: got %v", got)
+			}
+
+			for path, want := range map[string]int{
+				"/api/v1/search":                     http.StatusBadRequest,
+				"/api/v1/posts?limit=0":              http.StatusBadRequest,
+				"/api/v1/posts?sort=oldest":          http.StatusBadRequest,
+				"/api/v1/posts?unread=maybe":         http.StatusBadRequest,
+				"/api/v1/posts?after=nonsense":       http.StatusBadRequest,
+				"/api/v1/posts/" + uuid.Nil.String(): http.StatusNotFound,
+			} {
+				if status := apiRequest(t, api.URL, token, "GET", path, nil, nil); status != want {
+					t.Errorf("got status %d for %s, want %d", status, path, want)
+				}
+			}
+
+			// Bob doesn't follow the feed, so its posts aren't there for him
+			mustRun(t, s, "register", "bob")
+			token = createToken(t, s, "test", scopeWrite)
+			if status := apiRequest(t, api.URL, token, "GET", "/api/v1/posts/"+second.ID.String(), nil, nil); status != http.StatusNotFound {
+				t.Errorf("got status %d for a post in an unfollowed feed, want 404", status)
+			}
+		})
+	}
+}
+
+func TestAPITokens(t *testing.T) {
+	for name, newState := range testStates {
+		t.Run(name, func(t *testing.T) {
+			s := newState(t)
+			mustRun(t, s, "register", "alice")
+			readToken := createToken(t, s, "phone", scopeRead)
+			writeToken := createToken(t, s, "laptop", scopeWrite)
+			api := httptest.NewServer(newServer(s))
+			defer api.Close()
+
+			if _, err := runCommand(t, s, "token", "create", "phone"); err == nil {
+				t.Error("expected an error creating a token with a name already used")
+			}
+			if _, err := runCommand(t, s, "token", "create", "--scope", "admin", "other"); err == nil {
+				t.Error("expected an error creating a token with an unknown scope")
+			}
+
+			feed := map[string]string{"name": "Test", "url": "https://example.com/feed.xml"}
+			for _, check := range []struct {
+				token  string
+				method string
+				body   any
+				want   int
+			}{
+				{"", "GET", nil, http.StatusUnauthorized},
+				{"gator_nonsense", "GET", nil, http.StatusUnauthorized},
+				{readToken, "GET", nil, http.StatusOK},
+				{readToken, "POST", feed, http.StatusForbidden},
+				{writeToken, "POST", feed, http.StatusCreated},
+			} {
+				if status := apiRequest(t, api.URL, check.token, check.method, "/api/v1/feeds", check.body, nil); status != check.want {
+					t.Errorf("got status %d for %s with token %q, want %d", status, check.method, check.token, check.want)
+				}
+			}
+
+			tokens := runListing[tokenRecord](t, s, "token", "list")
+			if len(tokens) != 2 || tokens[0].Name != "laptop" || tokens[0].LastUsed == "never" || tokens[1].Scope != scopeRead {
+				t.Errorf("got tokens %+v, want laptop (used) and phone (read)", tokens)
+			}
+
+			mustRun(t, s, "token", "revoke", "phone")
+			if status := apiRequest(t, api.URL, readToken, "GET", "/api/v1/feeds", nil, nil); status != http.StatusUnauthorized {
+				t.Errorf("got status %d with a revoked token, want 401", status)
+			}
+			if _, err := runCommand(t, s, "token", "revoke", "phone"); err == nil {
+				t.Error("expected an error revoking a token twice")
+			}
+
+			// Tokens go with their user
+			mustRun(t, s, "reset")
+			if status := apiRequest(t, api.URL, writeToken, "GET", "/api/v1/feeds", nil, nil); status != http.StatusUnauthorized {
+				t.Errorf("got status %d with a deleted user's token, want 401", status)
+			}
+		})
+	}
+}
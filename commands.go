@@ -1,37 +1,371 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"log"
+	"os"
+	"slices"
+	"strings"
+	"time"
 )
 
 type command struct {
-	name string
-	args []string
+	name  string
+	args  []string
+	flags *flag.FlagSet
 }
 
+// Everything the registry knows about a command - enough to validate its
+// arguments and flags before the handler runs, and to generate help
+type commandSpec struct {
+	name        string
+	aliases     []string
+	description string
+	args        []argSpec
+	flags       []flagSpec
+	handler     func(*state, command) error
+	// Left out of help and completion, for commands meant for scripts
+	hidden bool
+	// For commands that don't need the database, or manage its schema
+	skipSchemaCheck bool
+}
+
+type argSpec struct {
+	name     string
+	usage    string
+	optional bool
+	// Takes all remaining arguments, at least one unless optional
+	variadic bool
+	complete completer
+}
+
+// A typed flag - the type of the default value (string, bool, int or
+// time.Duration) decides the type of the flag
+type flagSpec struct {
+	name     string
+	usage    string
+	defValue any
+	complete completer
+}
+
+// Command names and aliases are matched case-insensitively, so both are
+// stored lowercase
 type commands struct {
-	registry map[string]func(*state, command) error
+	registry map[string]commandSpec
+	aliases  map[string]string
 }
 
 func NewCommands() *commands {
 	return &commands{
-		registry: make(map[string]func(*state, command) error),
+		registry: make(map[string]commandSpec),
+		aliases:  make(map[string]string),
 	}
 }
 
-func (c *commands) register(name string, f func(*state, command) error) {
-	if _, exists := c.registry[name]; exists {
-		log.Fatalf("Attempt to double-register command '%s'", name)
+func (c *commands) register(spec commandSpec) {
+	spec.name = strings.ToLower(spec.name)
+	if _, exists := c.lookup(spec.name); exists {
+		log.Fatalf("Attempt to double-register command '%s'", spec.name)
 	}
 
-	c.registry[name] = f
+	for i, alias := range spec.aliases {
+		alias = strings.ToLower(alias)
+		if _, exists := c.lookup(alias); exists {
+			log.Fatalf("Attempt to register alias '%s' of command '%s' which is already a command or alias", alias, spec.name)
+		}
+		spec.aliases[i] = alias
+		c.aliases[alias] = spec.name
+	}
+
+	c.registry[spec.name] = spec
+}
+
+// Finds a command by name or alias
+func (c *commands) lookup(name string) (commandSpec, bool) {
+	name = strings.ToLower(name)
+	if target, isAlias := c.aliases[name]; isAlias {
+		name = target
+	}
+	spec, ok := c.registry[name]
+	return spec, ok
 }
 
 func (c *commands) run(s *state, cmd command) error {
-	if cmdFunc, ok := c.registry[cmd.name]; ok {
-		return cmdFunc(s, cmd)
+	spec, ok := c.lookup(cmd.name)
+	if !ok {
+		// User-defined aliases from the config file can't shadow built-in
+		// commands, so only get a look-in here
+		expanded, err := expandUserAlias(s.cfg.Aliases, cmd)
+		if err != nil {
+			return err
+		}
+		if spec, ok = c.lookup(expanded.name); ok {
+			cmd = expanded
+		}
+	}
+	if !ok {
+		return fmt.Errorf("Command does not exist: '%s' (see '%s help')", cmd.name, os.Args[0])
+	}
+
+	flags := spec.flagSet()
+	args, err := parseInterspersed(flags, cmd.args)
+	if errors.Is(err, flag.ErrHelp) {
+		spec.printHelp(os.Stdout)
+		return nil
+	}
+	if err != nil {
+		return spec.usageError("%v", err)
+	}
+	if err := spec.checkArgs(args); err != nil {
+		return err
+	}
+
+	if s.conn != nil && !spec.skipSchemaCheck {
+		if err := ensureSchemaCurrent(s); err != nil {
+			return err
+		}
+	}
+
+	cmd.args = args
+	cmd.flags = flags
+	return spec.handler(s, cmd)
+}
+
+// Sorted by name, for help
+func (c *commands) specs() []commandSpec {
+	var specs []commandSpec
+	for _, spec := range c.registry {
+		specs = append(specs, spec)
+	}
+	slices.SortFunc(specs, func(a, b commandSpec) int { return strings.Compare(a.name, b.name) })
+	return specs
+}
+
+func (c *commands) handlerHelp(s *state, cmd command) error {
+	if len(cmd.args) == 1 {
+		spec, ok := c.lookup(cmd.args[0])
+		if !ok {
+			return fmt.Errorf("Command does not exist: '%s'", cmd.args[0])
+		}
+		spec.printHelp(os.Stdout)
+		return nil
+	}
+
+	c.printUsage(os.Stdout)
+	return nil
+}
+
+func (c *commands) printUsage(w io.Writer) {
+	fmt.Fprintf(w, "Usage: %s [global options] <command> [flags] [args]\n\n", os.Args[0])
+	fmt.Fprintf(w, "Global options:\n")
+	fmt.Fprintf(w, "  -o, --output <format>  listing format: table (default), json, jsonl, csv or a Go template\n\n")
+	fmt.Fprintf(w, "Commands:\n")
+
+	visible := slices.DeleteFunc(c.specs(), func(spec commandSpec) bool { return spec.hidden })
+	width := 0
+	for _, spec := range visible {
+		width = max(width, len(spec.displayName()))
+	}
+	for _, spec := range visible {
+		fmt.Fprintf(w, "  %-*s  %s\n", width, spec.displayName(), spec.description)
+	}
+
+	fmt.Fprintf(w, "\nRun '%s help <command>' or '%s <command> --help' for details of a command\n", os.Args[0], os.Args[0])
+}
+
+// Name with any aliases, e.g. "browse (ls)"
+func (spec commandSpec) displayName() string {
+	if len(spec.aliases) == 0 {
+		return spec.name
+	}
+	return fmt.Sprintf("%s (%s)", spec.name, strings.Join(spec.aliases, ", "))
+}
+
+func (spec commandSpec) usageLine() string {
+	parts := []string{os.Args[0], spec.name}
+	if len(spec.flags) > 0 {
+		parts = append(parts, "[flags]")
+	}
+	for _, arg := range spec.args {
+		name := "<" + arg.name + ">"
+		if arg.variadic {
+			name += "..."
+		}
+		if arg.optional {
+			name = "[" + name + "]"
+		}
+		parts = append(parts, name)
+	}
+	return strings.Join(parts, " ")
+}
+
+func (spec commandSpec) printHelp(w io.Writer) {
+	fmt.Fprintf(w, "Usage: %s\n\n%s\n", spec.usageLine(), spec.description)
+
+	if len(spec.aliases) > 0 {
+		fmt.Fprintf(w, "\nAliases: %s\n", strings.Join(spec.aliases, ", "))
+	}
+
+	if len(spec.args) > 0 {
+		fmt.Fprintf(w, "\nArguments:\n")
+		for _, arg := range spec.args {
+			fmt.Fprintf(w, "  %s\n    \t%s\n", arg.name, arg.usage)
+		}
+	}
+
+	if len(spec.flags) > 0 {
+		fmt.Fprintf(w, "\nFlags:\n")
+		flags := spec.flagSet()
+		flags.SetOutput(w)
+		flags.PrintDefaults()
+	}
+}
+
+func (spec commandSpec) usageError(format string, a ...any) error {
+	return fmt.Errorf("%s: %s\nUsage: %s", spec.name, fmt.Sprintf(format, a...), spec.usageLine())
+}
+
+func (spec commandSpec) flagSet() *flag.FlagSet {
+	flags := flag.NewFlagSet(spec.name, flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+
+	for _, f := range spec.flags {
+		switch defValue := f.defValue.(type) {
+		case string:
+			flags.String(f.name, defValue, f.usage)
+		case bool:
+			flags.Bool(f.name, defValue, f.usage)
+		case int:
+			flags.Int(f.name, defValue, f.usage)
+		case time.Duration:
+			flags.Duration(f.name, defValue, f.usage)
+		default:
+			log.Fatalf("Unsupported type %T for flag '%s' of command '%s'", defValue, f.name, spec.name)
+		}
+	}
+
+	return flags
+}
+
+func (spec commandSpec) checkArgs(args []string) error {
+	minArgs, maxArgs := 0, 0
+	for _, arg := range spec.args {
+		if !arg.optional {
+			minArgs++
+		}
+		if arg.variadic {
+			maxArgs = -1
+		} else if maxArgs >= 0 {
+			maxArgs++
+		}
+	}
+
+	switch {
+	case len(args) < minArgs:
+		return spec.usageError("expected at least %d argument(s), got %d", minArgs, len(args))
+	case maxArgs >= 0 && len(args) > maxArgs:
+		return spec.usageError("expected at most %d argument(s), got %d", maxArgs, len(args))
+	}
+
+	return nil
+}
+
+// The flag package stops at the first positional argument, but we'd like
+// "browse 10 --unread" to work as well as "browse --unread 10". A lone "--"
+// still ends flag parsing.
+func parseInterspersed(flags *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := flags.Parse(args); err != nil {
+			return nil, err
+		}
+		rest := flags.Args()
+		if len(rest) == 0 {
+			return positional, nil
+		}
+		if parsed := len(args) - len(rest); parsed > 0 && args[parsed-1] == "--" {
+			return append(positional, rest...), nil
+		}
+		positional = append(positional, rest[0])
+		args = rest[1:]
+	}
+}
+
+func (c command) stringFlag(name string) string {
+	return c.flags.Lookup(name).Value.(flag.Getter).Get().(string)
+}
+
+func (c command) boolFlag(name string) bool {
+	return c.flags.Lookup(name).Value.(flag.Getter).Get().(bool)
+}
+
+func (c command) intFlag(name string) int {
+	return c.flags.Lookup(name).Value.(flag.Getter).Get().(int)
+}
+
+func (c command) durationFlag(name string) time.Duration {
+	return c.flags.Lookup(name).Value.(flag.Getter).Get().(time.Duration)
+}
+
+// Config file aliases map a name to a command line, e.g. "unread": "browse
+// --unread 20". Arguments given to the alias follow the preset ones.
+func expandUserAlias(aliases map[string]string, cmd command) (command, error) {
+	// Lowercased when the config was read
+	name := strings.ToLower(cmd.name)
+	expansion, ok := aliases[name]
+	if !ok {
+		return cmd, nil
+	}
+
+	words, err := splitCommandLine(expansion)
+	if err != nil {
+		return cmd, fmt.Errorf("Problem with alias '%s' in config file: %v", name, err)
+	}
+	if len(words) == 0 {
+		return cmd, fmt.Errorf("Alias '%s' in config file is empty", name)
+	}
+
+	return command{name: words[0], args: append(words[1:], cmd.args...)}, nil
+}
+
+// Splits on whitespace, honouring single and double quotes so alias
+// arguments can contain spaces
+func splitCommandLine(line string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	inWord := false
+	var quote rune
+
+	for _, r := range line {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			word.WriteRune(r)
+		case r == '\'' || r == '"':
+			quote = r
+			inWord = true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote", quote)
+	}
+	if inWord {
+		words = append(words, word.String())
 	}
 
-	return fmt.Errorf("Command does not exist: '%s'", cmd.name)
+	return words, nil
 }
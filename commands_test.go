@@ -0,0 +1,171 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/venzy/gator/internal/config"
+)
+
+func TestParseInterspersed(t *testing.T) {
+	spec := commandSpec{
+		name: "test",
+		flags: []flagSpec{
+			{name: "unread", defValue: false},
+			{name: "feed", defValue: ""},
+			{name: "every", defValue: time.Minute},
+		},
+	}
+
+	tests := []struct {
+		args     []string
+		wantArgs []string
+		unread   bool
+		feed     string
+	}{
+		{[]string{"10"}, []string{"10"}, false, ""},
+		{[]string{"--unread", "10"}, []string{"10"}, true, ""},
+		{[]string{"10", "--unread", "--feed", "x", "20"}, []string{"10", "20"}, true, "x"},
+		{[]string{"--feed=x", "a", "--", "--unread"}, []string{"a", "--unread"}, false, "x"},
+		{nil, nil, false, ""},
+	}
+	for _, tt := range tests {
+		flags := spec.flagSet()
+		args, err := parseInterspersed(flags, tt.args)
+		if err != nil {
+			t.Errorf("%q: %v", tt.args, err)
+			continue
+		}
+		cmd := command{flags: flags}
+		if !slices.Equal(args, tt.wantArgs) || cmd.boolFlag("unread") != tt.unread || cmd.stringFlag("feed") != tt.feed {
+			t.Errorf("%q: got args %q, unread %v, feed %q", tt.args, args, cmd.boolFlag("unread"), cmd.stringFlag("feed"))
+		}
+	}
+
+	for _, args := range [][]string{{"--nope"}, {"--every", "soon"}, {"10", "--feed"}} {
+		if _, err := parseInterspersed(spec.flagSet(), args); err == nil {
+			t.Errorf("%q: expected an error", args)
+		}
+	}
+}
+
+func TestCheckArgs(t *testing.T) {
+	tests := []struct {
+		args    []argSpec
+		counts  []int
+		failing []int
+	}{
+		{nil, []int{0}, []int{1}},
+		{[]argSpec{{name: "a"}, {name: "b", optional: true}}, []int{1, 2}, []int{0, 3}},
+		{[]argSpec{{name: "a", variadic: true}}, []int{1, 5}, []int{0}},
+		{[]argSpec{{name: "a"}, {name: "rest", optional: true, variadic: true}}, []int{1, 2, 9}, []int{0}},
+	}
+	for _, tt := range tests {
+		spec := commandSpec{name: "test", args: tt.args}
+		for _, count := range tt.counts {
+			if err := spec.checkArgs(make([]string, count)); err != nil {
+				t.Errorf("%+v with %d args: %v", tt.args, count, err)
+			}
+		}
+		for _, count := range tt.failing {
+			if err := spec.checkArgs(make([]string, count)); err == nil || !strings.Contains(err.Error(), "Usage:") {
+				t.Errorf("%+v with %d args: got %v, want a usage error", tt.args, count, err)
+			}
+		}
+	}
+}
+
+func TestSplitCommandLine(t *testing.T) {
+	tests := []struct {
+		line string
+		want []string
+	}{
+		{"", nil},
+		{"  browse  --unread\t20 ", []string{"browse", "--unread", "20"}},
+		{`browse --search "two words" --folder 'it''s'`, []string{"browse", "--search", "two words", "--folder", "its"}},
+		{`tag url ""`, []string{"tag", "url", ""}},
+	}
+	for _, tt := range tests {
+		got, err := splitCommandLine(tt.line)
+		if err != nil || !slices.Equal(got, tt.want) {
+			t.Errorf("%q: got %q, %v, want %q", tt.line, got, err, tt.want)
+		}
+	}
+
+	if _, err := splitCommandLine(`browse "unfinished`); err == nil {
+		t.Error("expected an error for an unterminated quote")
+	}
+}
+
+func TestExpandUserAlias(t *testing.T) {
+	aliases := map[string]string{
+		"news":  "browse --unread --folder news",
+		"empty": "  ",
+		"bad":   `browse "oops`,
+	}
+
+	tests := []struct {
+		cmd  command
+		want command
+	}{
+		{command{name: "news", args: []string{"20"}}, command{name: "browse", args: []string{"--unread", "--folder", "news", "20"}}},
+		{command{name: "NEWS"}, command{name: "browse", args: []string{"--unread", "--folder", "news"}}},
+		{command{name: "feeds", args: []string{"x"}}, command{name: "feeds", args: []string{"x"}}},
+	}
+	for _, tt := range tests {
+		got, err := expandUserAlias(aliases, tt.cmd)
+		if err != nil || got.name != tt.want.name || !slices.Equal(got.args, tt.want.args) {
+			t.Errorf("%+v: got %+v, %v, want %+v", tt.cmd, got, err, tt.want)
+		}
+	}
+
+	for _, name := range []string{"empty", "bad"} {
+		if _, err := expandUserAlias(aliases, command{name: name}); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestPrintUsage(t *testing.T) {
+	c := NewCommands()
+	c.register(commandSpec{name: "short", description: "Visible"})
+	c.register(commandSpec{name: "a-much-longer-hidden-command", description: "Hidden", hidden: true})
+
+	var out strings.Builder
+	c.printUsage(&out)
+	if strings.Contains(out.String(), "Hidden") {
+		t.Error("help lists a hidden command")
+	}
+	if !strings.Contains(out.String(), "  short  Visible\n") {
+		t.Errorf("got help %q, want columns sized for the visible commands", out.String())
+	}
+}
+
+func TestConfigAliasesNormalised(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	writeConfig := func(contents string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(home, ".gatorconfig.json"), []byte(contents), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	writeConfig(`{"aliases": {"News": "browse --folder news"}}`)
+	cfg, err := config.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := cfg.Aliases["news"]; got != "browse --folder news" {
+		t.Errorf("got aliases %v, want News lowercased", cfg.Aliases)
+	}
+
+	writeConfig(`{"aliases": {"News": "browse", "NEWS": "feeds"}}`)
+	if _, err := config.Read(); err == nil {
+		t.Error("expected an error for aliases differing only in case")
+	}
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Supplies candidate values for an argument or flag when completing a command
+// line. Errors just mean no candidates - there's nobody to show them to.
+type completer func(s *state) ([]string, error)
+
+func completeValues(values ...string) completer {
+	return func(_ *state) ([]string, error) {
+		return values, nil
+	}
+}
+
+func completeUsernames(s *state) ([]string, error) {
+	users, err := s.db.GetUsers(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, user := range users {
+		names = append(names, user.Name)
+	}
+	return names, nil
+}
+
+func completeFeedURLs(s *state) ([]string, error) {
+	feeds, err := s.db.GetFeeds(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	var urls []string
+	for _, feed := range feeds {
+		urls = append(urls, feed.Url)
+	}
+	return urls, nil
+}
+
+func completeFollowedFeedURLs(s *state) ([]string, error) {
+	user, err := currentUser(s)
+	if err != nil {
+		return nil, err
+	}
+
+	follows, err := s.db.GetFeedFollowsForUser(context.Background(), user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	var urls []string
+	for _, follow := range follows {
+		urls = append(urls, follow.FeedUrl)
+	}
+	return urls, nil
+}
+
+func completeFolders(s *state) ([]string, error) {
+	user, err := currentUser(s)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.db.GetFoldersForUser(context.Background(), user.ID)
+}
+
+func completeTags(s *state) ([]string, error) {
+	user, err := currentUser(s)
+	if err != nil {
+		return nil, err
+	}
+
+	counts, err := s.db.GetTagCountsForUser(context.Background(), user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	var tags []string
+	for _, count := range counts {
+		tags = append(tags, count.Tag)
+	}
+	return tags, nil
+}
+
+func (c *commands) completeCommandNames(_ *state) ([]string, error) {
+	var names []string
+	for _, spec := range c.specs() {
+		if !spec.hidden {
+			names = append(names, spec.name)
+		}
+	}
+	return names, nil
+}
+
+func (c *commands) handlerCompletion(s *state, cmd command) error {
+	script, ok := completionScripts[cmd.args[0]]
+	if !ok {
+		return fmt.Errorf("No completion script for shell '%s': must be bash, zsh or fish", cmd.args[0])
+	}
+
+	fmt.Print(script)
+	return nil
+}
+
+// Called by the completion scripts with the words of the command line so
+// far, the last being the (possibly empty) word being completed. Prints
+// matching candidates one per line.
+func (c *commands) handlerComplete(s *state, cmd command) error {
+	words := cmd.args
+	if len(words) == 0 {
+		words = []string{""}
+	}
+	current := words[len(words)-1]
+
+	candidates, _ := c.completionCandidates(s, words[:len(words)-1], current)
+	for _, candidate := range candidates {
+		if strings.HasPrefix(candidate, current) {
+			fmt.Println(candidate)
+		}
+	}
+
+	return nil
+}
+
+func (c *commands) completionCandidates(s *state, previous []string, current string) ([]string, error) {
+	// Skip global options
+	for len(previous) > 0 && strings.HasPrefix(previous[0], "-") {
+		if previous[0] == "-o" || previous[0] == "--output" || previous[0] == "-output" {
+			if len(previous) == 1 {
+				return []string{outputTable, outputJSON, outputJSONL, outputCSV}, nil
+			}
+			previous = previous[1:]
+		}
+		previous = previous[1:]
+	}
+
+	if len(previous) == 0 {
+		if strings.HasPrefix(current, "-") {
+			return []string{"--output", "--help"}, nil
+		}
+		return c.completeCommandNames(s)
+	}
+
+	spec, ok := c.lookup(previous[0])
+	if !ok {
+		return nil, nil
+	}
+
+	// Work out whether we're completing a flag value or which positional
+	// argument we're up to
+	positional := 0
+	var pendingFlag *flagSpec
+	flagsDone := false
+	for _, word := range previous[1:] {
+		if pendingFlag != nil {
+			pendingFlag = nil
+			continue
+		}
+		if !flagsDone && word == "--" {
+			flagsDone = true
+			continue
+		}
+		if !flagsDone && strings.HasPrefix(word, "-") && len(word) > 1 {
+			name, _, hasValue := strings.Cut(strings.TrimLeft(word, "-"), "=")
+			if f := spec.findFlag(name); f != nil && !hasValue {
+				if _, isBool := f.defValue.(bool); !isBool {
+					pendingFlag = f
+				}
+			}
+			continue
+		}
+		positional++
+	}
+
+	if pendingFlag != nil {
+		if pendingFlag.complete == nil {
+			return nil, nil
+		}
+		return pendingFlag.complete(s)
+	}
+
+	if !flagsDone && strings.HasPrefix(current, "-") {
+		candidates := []string{"--help"}
+		for _, f := range spec.flags {
+			candidates = append(candidates, "--"+f.name)
+		}
+		return candidates, nil
+	}
+
+	var arg *argSpec
+	switch {
+	case positional < len(spec.args):
+		arg = &spec.args[positional]
+	case len(spec.args) > 0 && spec.args[len(spec.args)-1].variadic:
+		arg = &spec.args[len(spec.args)-1]
+	}
+	if arg == nil || arg.complete == nil {
+		return nil, nil
+	}
+
+	return arg.complete(s)
+}
+
+func (spec commandSpec) findFlag(name string) *flagSpec {
+	for i := range spec.flags {
+		if spec.flags[i].name == name {
+			return &spec.flags[i]
+		}
+	}
+	return nil
+}
+
+// The scripts hand the command line to 'gator __complete', so they never need
+// regenerating as commands, feeds or folders change
+var completionScripts = map[string]string{
+	"bash": `# bash completion for gator
+# Add to ~/.bashrc: source <(gator completion bash)
+_gator() {
+    local cur words cword
+    if declare -F _get_comp_words_by_ref >/dev/null; then
+        # Don't split feed URLs at the colon
+        _get_comp_words_by_ref -n =: cur words cword
+    else
+        cur="${COMP_WORDS[COMP_CWORD]}"
+        words=("${COMP_WORDS[@]}")
+        cword=$COMP_CWORD
+    fi
+
+    local IFS=$'\n'
+    COMPREPLY=($(compgen -W "$(gator __complete -- "${words[@]:1:cword}" 2>/dev/null)" -- "$cur"))
+
+    if declare -F __ltrim_colon_completions >/dev/null; then
+        __ltrim_colon_completions "$cur"
+    fi
+}
+complete -o default -F _gator gator
+`,
+	"zsh": `#compdef gator
+# zsh completion for gator
+# Add to ~/.zshrc: source <(gator completion zsh)
+_gator() {
+    local -a candidates
+    candidates=("${(@f)$(gator __complete -- "${(@)words[2,CURRENT]}" 2>/dev/null)}")
+    compadd -a candidates
+}
+compdef _gator gator
+`,
+	"fish": `# fish completion for gator
+# Save as ~/.config/fish/completions/gator.fish, or: gator completion fish | source
+function __gator_complete
+    set -l tokens (commandline -opc) (commandline -ct)
+    gator __complete -- $tokens[2..-1] 2>/dev/null
+end
+complete -c gator -f -a '(__gator_complete)'
+`,
+}
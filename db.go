@@ -0,0 +1,71 @@
+package main
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/venzy/gator/internal/database"
+
+	_ "github.com/lib/pq"
+	_ "modernc.org/sqlite"
+)
+
+const (
+	dialectPostgres = "postgres"
+	dialectSQLite   = "sqlite"
+)
+
+// SQLite versions of the queries in sql/queries that aren't portable
+//
+//go:embed sql/sqlite/queries/*.sql
+var sqliteQueryFiles embed.FS
+
+// Opens the database named by the config's db_url - a Postgres URL, or
+// sqlite://<path> for a SQLite database file (sqlite:///home/me/gator.db for
+// an absolute path, sqlite://~/gator.db under the home directory)
+func openDatabase(dbURL string) (*sql.DB, *database.Queries, string, error) {
+	path, isSQLite := strings.CutPrefix(dbURL, "sqlite://")
+	if !isSQLite {
+		db, err := sql.Open("postgres", dbURL)
+		if err != nil {
+			return nil, nil, "", err
+		}
+		return db, database.New(db), dialectPostgres, nil
+	}
+
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return nil, nil, "", err
+		}
+		path = filepath.Join(homeDir, rest)
+	}
+	if path == "" {
+		return nil, nil, "", fmt.Errorf("No SQLite database path in db_url '%s'", dbURL)
+	}
+
+	// Foreign keys are off by default in SQLite, but we rely on cascading
+	// deletes. Times are written in a format SQLite's date functions, and
+	// sorting, understand.
+	dsn := "file:" + path + "?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_time_format=sqlite"
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	queryFiles, err := fs.Sub(sqliteQueryFiles, "sql/sqlite/queries")
+	if err != nil {
+		return nil, nil, "", err
+	}
+	queries, err := database.NewSQLite(db, queryFiles)
+	if err != nil {
+		return nil, nil, "", fmt.Errorf("Problem loading SQLite queries: %v", err)
+	}
+
+	return db, queries, dialectSQLite, nil
+}
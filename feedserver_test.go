@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andybalholm/brotli"
+	"github.com/venzy/gator/internal/config"
+	"github.com/venzy/gator/internal/database/memory"
+)
+
+// Items in the feed served at /huge - a few megabytes of XML
+const hugeFeedItems = 5000
+
+// A local stand-in for the feeds out on the internet: the fixtures in
+// testdata/feeds under /feeds/, plus some badly behaved endpoints
+func newFeedServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.Handle("/feeds/", http.StripPrefix("/feeds/", http.FileServer(http.Dir("testdata/feeds"))))
+	// A fixture served with the Content-Type given by the type parameter
+	mux.HandleFunc("/typed/{name}", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", r.URL.Query().Get("type"))
+		w.Write(readFixture(t, r.PathValue("name")))
+	})
+	// The smallest GIF there is, for sites' favicons
+	mux.HandleFunc("/favicon.ico", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/gif")
+		w.Write([]byte("GIF89a\x01\x00\x01\x00\x80\x00\x00\xff\xff\xff\x00\x00\x00!\xf9\x04\x01\x00\x00\x00\x00,\x00\x00\x00\x00\x01\x00\x01\x00\x00\x02\x02D\x01\x00;"))
+	})
+	mux.HandleFunc("/redirect", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/feeds/rss.xml", http.StatusFound)
+	})
+	mux.HandleFunc("/moved", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/feeds/rss.xml", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/moved-twice", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/moved", http.StatusPermanentRedirect)
+	})
+	mux.HandleFunc("/moved-then-redirect", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/redirect", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/redirect-loop", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/redirect-loop", http.StatusFound)
+	})
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		// Hang until the client gives up, so closing the server isn't held up
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Minute):
+		}
+	})
+	mux.HandleFunc("/gzip", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		writer := gzip.NewWriter(w)
+		writer.Write(readFixture(t, "rss.xml"))
+		writer.Close()
+	})
+	mux.HandleFunc("/brotli", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "br")
+		writer := brotli.NewWriter(w)
+		writer.Write(readFixture(t, "rss.xml"))
+		writer.Close()
+	})
+	// A feed titled with the request's User-Agent
+	mux.HandleFunc("/user-agent", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `<rss version="2.0"><channel><title>%s</title></channel></rss>`, html.EscapeString(r.UserAgent()))
+	})
+	mux.HandleFunc("/huge", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/rss+xml")
+		io.WriteString(w, hugeFeed(hugeFeedItems))
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func readFixture(t *testing.T, name string) []byte {
+	contents, err := os.ReadFile(filepath.Join("testdata", "feeds", name))
+	if err != nil {
+		t.Error(err)
+	}
+	return contents
+}
+
+// fetchOptions as they'd be with nothing in the config file
+func defaultFetchOptions(t *testing.T) fetchOptions {
+	t.Helper()
+
+	opts, err := newFetchOptions(config.FetchConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return opts
+}
+
+func hugeFeed(items int) string {
+	var feed strings.Builder
+	feed.WriteString(`<?xml version="1.0" encoding="UTF-8"?><rss version="2.0"><channel><title>Huge Feed</title>`)
+	padding := strings.Repeat("All work and no play makes Jack a dull boy. ", 20)
+	for i := range items {
+		fmt.Fprintf(&feed, "<item><title>Post %d</title><link>https://example.com/huge/%d</link>", i, i)
+		fmt.Fprintf(&feed, "<description>%s</description><pubDate>Mon, 06 Jan 2025 09:00:00 +0000</pubDate></item>", padding)
+	}
+	feed.WriteString("</channel></rss>")
+	return feed.String()
+}
+
+// Handlers save the logged in user to the config file in the home directory,
+// so each test gets its own
+func setTestHome(t *testing.T) {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+}
+
+func newMemoryState(t *testing.T) *state {
+	t.Helper()
+	setTestHome(t)
+	return &state{
+		db:     memory.New(),
+		cfg:    &config.Config{},
+		output: outputFormat{kind: outputTable},
+	}
+}
+
+// A state using a fresh SQLite database, migrated by the first command run
+func newSQLiteState(t *testing.T) *state {
+	t.Helper()
+	setTestHome(t)
+
+	conn, queries, dialect, err := openDatabase("sqlite://" + filepath.Join(t.TempDir(), "gator.db"))
+	if err != nil {
+		t.Fatalf("opening SQLite database: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return &state{
+		db:      queries,
+		conn:    conn,
+		dialect: dialect,
+		cfg:     &config.Config{AutoMigrate: true},
+		output:  outputFormat{kind: outputTable},
+	}
+}
+
+// The storage backends every handler test runs against
+var testStates = map[string]func(t *testing.T) *state{
+	"memory": newMemoryState,
+	"sqlite": newSQLiteState,
+}
+
+// Runs a command line as main would, returning what it printed
+func runCommand(t *testing.T, s *state, words ...string) (string, error) {
+	t.Helper()
+
+	cliCommands := NewCommands()
+	registerCommands(cliCommands)
+
+	var err error
+	output := captureStdout(t, func() {
+		err = cliCommands.run(s, command{name: words[0], args: words[1:]})
+	})
+	return output, err
+}
+
+// Like runCommand, for commands that should succeed
+func mustRun(t *testing.T, s *state, words ...string) string {
+	t.Helper()
+
+	output, err := runCommand(t, s, words...)
+	if err != nil {
+		t.Fatalf("%s: %v", strings.Join(words, " "), err)
+	}
+	return output
+}
+
+// Runs a listing command with JSON output, decoding the records
+func runListing[T any](t *testing.T, s *state, words ...string) []T {
+	t.Helper()
+
+	previous := s.output
+	s.output = outputFormat{kind: outputJSON}
+	defer func() { s.output = previous }()
+
+	var records []T
+	output := mustRun(t, s, words...)
+	if err := json.Unmarshal([]byte(output), &records); err != nil {
+		t.Fatalf("%s: decoding output %q: %v", strings.Join(words, " "), output, err)
+	}
+	return records
+}
+
+func captureStdout(t *testing.T, f func()) string {
+	t.Helper()
+
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = writer
+	defer func() { os.Stdout = stdout }()
+
+	captured := make(chan string)
+	go func() {
+		var buf bytes.Buffer
+		io.Copy(&buf, reader)
+		captured <- buf.String()
+	}()
+
+	f()
+	writer.Close()
+	return <-captured
+}
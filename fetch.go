@@ -0,0 +1,165 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/andybalholm/brotli"
+	"github.com/venzy/gator/internal/config"
+)
+
+// Defaults for anything not set in the config file's "fetch" section
+const (
+	defaultConnectTimeout  = 10 * time.Second
+	defaultResponseTimeout = 30 * time.Second
+	defaultMaxBodySize     = 10 << 20
+	defaultMaxRedirects    = 5
+	defaultContactURL      = "https://github.com/venzy/gator"
+)
+
+type fetchOptions struct {
+	connectTimeout  time.Duration
+	responseTimeout time.Duration
+	maxBodySize     int64
+	maxRedirects    int
+	userAgent       string
+}
+
+func newFetchOptions(cfg config.FetchConfig) (fetchOptions, error) {
+	opts := fetchOptions{
+		connectTimeout:  defaultConnectTimeout,
+		responseTimeout: defaultResponseTimeout,
+		maxBodySize:     defaultMaxBodySize,
+		maxRedirects:    defaultMaxRedirects,
+		userAgent:       cfg.UserAgent,
+	}
+
+	var err error
+	if cfg.ConnectTimeout != "" {
+		if opts.connectTimeout, err = time.ParseDuration(cfg.ConnectTimeout); err != nil {
+			return opts, fmt.Errorf("Invalid fetch connect_timeout '%s' in config file: %v", cfg.ConnectTimeout, err)
+		}
+	}
+	if cfg.ResponseTimeout != "" {
+		if opts.responseTimeout, err = time.ParseDuration(cfg.ResponseTimeout); err != nil {
+			return opts, fmt.Errorf("Invalid fetch response_timeout '%s' in config file: %v", cfg.ResponseTimeout, err)
+		}
+	}
+	if cfg.MaxBodySize != 0 {
+		opts.maxBodySize = cfg.MaxBodySize
+	}
+	if cfg.MaxRedirects != nil {
+		if *cfg.MaxRedirects < 0 {
+			return opts, fmt.Errorf("Invalid fetch max_redirects %d in config file: can't be negative", *cfg.MaxRedirects)
+		}
+		opts.maxRedirects = *cfg.MaxRedirects
+	}
+
+	if opts.userAgent == "" {
+		contactURL := cfg.ContactURL
+		if contactURL == "" {
+			contactURL = defaultContactURL
+		}
+		opts.userAgent = fmt.Sprintf("gator/%s (+%s)", version, contactURL)
+	}
+
+	return opts, nil
+}
+
+type fetchResponse struct {
+	body        []byte
+	contentType string
+	// Where the feed now lives, if it's been permanently redirected
+	movedTo string
+}
+
+// A client for requests to other sites, going through any proxy and giving up
+// on slow connections as configured. Close its idle connections when done.
+func newHTTPClient(opts fetchOptions, timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{Timeout: opts.connectTimeout}
+	transport := &http.Transport{
+		Proxy:               http.ProxyFromEnvironment,
+		DialContext:         dialer.DialContext,
+		TLSHandshakeTimeout: opts.connectTimeout,
+	}
+	return &http.Client{Transport: transport, Timeout: timeout}
+}
+
+func fetchURL(ctx context.Context, opts fetchOptions, url string) (*fetchResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("Error creating request: %s", err)
+	}
+	req.Header.Set("User-Agent", opts.userAgent)
+	// Asking for compression ourselves means decompressing ourselves, but
+	// Go's transport only does gzip
+	req.Header.Set("Accept-Encoding", "gzip, br")
+
+	client := newHTTPClient(opts, opts.responseTimeout)
+	defer client.CloseIdleConnections()
+
+	// Only an unbroken chain of permanent redirects from the original URL
+	// means the feed has moved
+	var movedTo string
+	permanent := true
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if len(via) > opts.maxRedirects {
+			return fmt.Errorf("more than %d redirects", opts.maxRedirects)
+		}
+		status := req.Response.StatusCode
+		if permanent && (status == http.StatusMovedPermanently || status == http.StatusPermanentRedirect) {
+			movedTo = req.URL.String()
+		} else {
+			permanent = false
+		}
+		return nil
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("Error making request: %s", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Unexpected response status: %s", res.Status)
+	}
+
+	var body io.Reader = res.Body
+	switch encoding := strings.ToLower(res.Header.Get("Content-Encoding")); encoding {
+	case "", "identity":
+	case "gzip":
+		gzipReader, err := gzip.NewReader(res.Body)
+		if err != nil {
+			return nil, fmt.Errorf("Error reading gzip response body: %s", err)
+		}
+		defer gzipReader.Close()
+		body = gzipReader
+	case "br":
+		body = brotli.NewReader(res.Body)
+	default:
+		return nil, fmt.Errorf("Unsupported response Content-Encoding '%s'", encoding)
+	}
+
+	// Read one byte over the limit to tell a body that's exactly the limit
+	// from one that's too big
+	contents, err := io.ReadAll(io.LimitReader(body, opts.maxBodySize+1))
+	if err != nil {
+		return nil, fmt.Errorf("Error reading response body: %s", err)
+	}
+	if int64(len(contents)) > opts.maxBodySize {
+		return nil, fmt.Errorf("Response body is larger than the maximum of %d bytes", opts.maxBodySize)
+	}
+
+	return &fetchResponse{
+		body:        contents,
+		contentType: res.Header.Get("Content-Type"),
+		movedTo:     movedTo,
+	}, nil
+}
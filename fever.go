@@ -0,0 +1,550 @@
+package main
+
+import (
+	"crypto/md5"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/venzy/gator/internal/database"
+)
+
+// The Fever API (https://feedafever.com/api), for mobile apps that sync with
+// it. Apps log in with the user's name as the email and an API token as the
+// password, and everything is a request to /fever/?api with flags saying what
+// to send back.
+const feverAPIVersion = 3
+
+// Most items sent at once - apps page through with since_id and max_id
+const feverItemsLimit = 50
+
+// How many posts are marked read at a time when marking a feed or group
+const feverMarkBatch = 500
+
+func (srv *server) registerFeverRoutes() {
+	srv.mux.HandleFunc("/fever", srv.fever)
+	srv.mux.HandleFunc("/fever/", srv.fever)
+}
+
+// Fever's api_key is the MD5 of "email:password", which gator can't work out
+// from hashes, so it's worked out for each token when the token is created.
+// It works as a password too, so only its hash is stored, like the token's.
+func feverKey(userName string, token string) string {
+	sum := md5.Sum([]byte(userName + ":" + token))
+	return hex.EncodeToString(sum[:])
+}
+
+type feverGroup struct {
+	ID    int64  `json:"id"`
+	Title string `json:"title"`
+}
+
+type feverFeedsGroup struct {
+	GroupID int64 `json:"group_id"`
+	// Comma separated, as with all of Fever's lists of ids
+	FeedIDs string `json:"feed_ids"`
+}
+
+type feverFeed struct {
+	ID                int64  `json:"id"`
+	FaviconID         int64  `json:"favicon_id"`
+	Title             string `json:"title"`
+	Url               string `json:"url"`
+	SiteUrl           string `json:"site_url"`
+	IsSpark           int    `json:"is_spark"`
+	LastUpdatedOnTime int64  `json:"last_updated_on_time"`
+}
+
+type feverItem struct {
+	ID            int64  `json:"id"`
+	FeedID        int64  `json:"feed_id"`
+	Title         string `json:"title"`
+	Author        string `json:"author"`
+	HTML          string `json:"html"`
+	Url           string `json:"url"`
+	IsSaved       int    `json:"is_saved"`
+	IsRead        int    `json:"is_read"`
+	CreatedOnTime int64  `json:"created_on_time"`
+}
+
+type feverFavicon struct {
+	ID int64 `json:"id"`
+	// A data URI without the "data:"
+	Data string `json:"data"`
+}
+
+// Fever always answers 200 with JSON, even when the key is wrong - "auth": 0
+// tells the app
+func (srv *server) fever(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodySize)
+	if err := r.ParseForm(); err != nil {
+		writeError(w, badRequest("Invalid request: %v", err))
+		return
+	}
+	if _, ok := r.Form["api"]; !ok {
+		writeError(w, notFound("Fever requests need the api parameter"))
+		return
+	}
+
+	response := map[string]any{"api_version": feverAPIVersion, "auth": 0}
+	user, token, err := srv.feverUser(r)
+	if err != nil {
+		var apiErr *apiError
+		if errors.As(err, &apiErr) && apiErr.status == http.StatusUnauthorized {
+			writeJSON(w, http.StatusOK, response)
+		} else {
+			writeError(w, err)
+		}
+		return
+	}
+	response["auth"] = 1
+
+	if err := srv.feverRespond(r, user, token, response); err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// Who a Fever request is from, going by its api_key
+func (srv *server) feverUser(r *http.Request) (database.User, database.ApiToken, error) {
+	apiKey := strings.ToLower(strings.TrimSpace(r.Form.Get("api_key")))
+	if apiKey == "" {
+		return database.User{}, database.ApiToken{}, unauthorised("No Fever api_key")
+	}
+
+	apiToken, err := srv.s.db.GetAPITokenByFeverKey(r.Context(), sql.NullString{String: hashToken(apiKey), Valid: true})
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.User{}, database.ApiToken{}, unauthorised("Invalid Fever api_key")
+	} else if err != nil {
+		return database.User{}, database.ApiToken{}, fmt.Errorf("Problem checking Fever api_key: %v", err)
+	}
+
+	user, err := srv.tokenOwner(r, apiToken)
+	return user, apiToken, err
+}
+
+func (srv *server) feverRespond(r *http.Request, user database.User, token database.ApiToken, response map[string]any) error {
+	requested := func(name string) bool {
+		_, ok := r.Form[name]
+		return ok
+	}
+
+	// Number anything new first, so that new posts always come after the
+	// ones an app has already seen. Read-only keys don't write, so they see
+	// what storing posts has numbered already.
+	if token.Scope == scopeWrite {
+		if err := srv.s.db.NumberNewFeeds(r.Context()); err != nil {
+			return fmt.Errorf("Problem numbering feeds: %v", err)
+		}
+		if err := srv.s.db.NumberNewPosts(r.Context()); err != nil {
+			return fmt.Errorf("Problem numbering posts: %v", err)
+		}
+	}
+
+	if mark := r.Form.Get("mark"); mark != "" {
+		if token.Scope != scopeWrite {
+			return forbidden("API token '%s' is read-only", token.Name)
+		}
+		if err := srv.feverMark(r, user, mark); err != nil {
+			return err
+		}
+	}
+
+	feeds, err := srv.s.db.GetNumberedFeedsForUser(r.Context(), user.ID)
+	if err != nil {
+		return fmt.Errorf("Problem fetching feeds for user '%s': %v", user.Name, err)
+	}
+	var lastRefreshed int64
+	for _, feed := range feeds {
+		if feed.LastFetchedAt.Valid {
+			lastRefreshed = max(lastRefreshed, feed.LastFetchedAt.Time.Unix())
+		}
+	}
+	response["last_refreshed_on_time"] = lastRefreshed
+
+	if requested("groups") || requested("feeds") {
+		groups, feedsGroups, err := srv.feverGroups(r, user, feeds)
+		if err != nil {
+			return err
+		}
+		if requested("groups") {
+			response["groups"] = groups
+		}
+		if requested("feeds") {
+			response["feeds"] = toFeverFeeds(feeds)
+		}
+		response["feeds_groups"] = feedsGroups
+	}
+
+	if requested("favicons") {
+		response["favicons"] = srv.feverFavicons(r, feeds)
+	}
+
+	if requested("items") {
+		items, err := srv.feverItems(r, user)
+		if err != nil {
+			return err
+		}
+		total, err := srv.s.db.CountFeverItems(r.Context(), user.ID)
+		if err != nil {
+			return fmt.Errorf("Problem counting posts for user '%s': %v", user.Name, err)
+		}
+		response["items"] = items
+		response["total_items"] = total
+	}
+
+	// Fever's hot links aren't something gator has
+	if requested("links") {
+		response["links"] = []any{}
+	}
+
+	if requested("unread_item_ids") {
+		numbers, err := srv.s.db.GetUnreadPostNumbers(r.Context(), user.ID)
+		if err != nil {
+			return fmt.Errorf("Problem fetching unread posts for user '%s': %v", user.Name, err)
+		}
+		response["unread_item_ids"] = joinNumbers(numbers)
+	}
+	if requested("saved_item_ids") {
+		numbers, err := srv.s.db.GetStarredPostNumbers(r.Context(), user.ID)
+		if err != nil {
+			return fmt.Errorf("Problem fetching starred posts for user '%s': %v", user.Name, err)
+		}
+		response["saved_item_ids"] = joinNumbers(numbers)
+	}
+
+	return nil
+}
+
+// Folders are groups, numbered in name order. Feeds not in a folder aren't in
+// any group, which apps show on their own.
+func (srv *server) feverGroups(r *http.Request, user database.User, feeds []database.GetNumberedFeedsForUserRow) ([]feverGroup, []feverFeedsGroup, error) {
+	folders, err := srv.s.db.GetFoldersForUser(r.Context(), user.ID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Problem fetching folders for user '%s': %v", user.Name, err)
+	}
+
+	groups := make([]feverGroup, 0, len(folders))
+	feedsGroups := make([]feverFeedsGroup, 0, len(folders))
+	for i, folder := range folders {
+		var numbers []int64
+		for _, feed := range feeds {
+			if feed.Folder.String == folder {
+				numbers = append(numbers, feed.Number)
+			}
+		}
+		groups = append(groups, feverGroup{ID: int64(i + 1), Title: folder})
+		feedsGroups = append(feedsGroups, feverFeedsGroup{GroupID: int64(i + 1), FeedIDs: joinNumbers(numbers)})
+	}
+	return groups, feedsGroups, nil
+}
+
+func toFeverFeeds(feeds []database.GetNumberedFeedsForUserRow) []feverFeed {
+	records := make([]feverFeed, 0, len(feeds))
+	for _, feed := range feeds {
+		record := feverFeed{
+			ID: feed.Number,
+			// Each feed has its own favicon id, even when they share a site
+			FaviconID: feed.Number,
+			Title:     feed.Name,
+			Url:       feed.Url,
+			SiteUrl:   siteURL(feed.Url),
+		}
+		if feed.LastFetchedAt.Valid {
+			record.LastUpdatedOnTime = feed.LastFetchedAt.Time.Unix()
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+// Items after since_id (oldest first), before max_id (newest first), the
+// ones in with_ids, or else the newest
+func (srv *server) feverItems(r *http.Request, user database.User) ([]feverItem, error) {
+	params := database.GetNumberedPostsForUserParams{UserID: user.ID, RowLimit: feverItemsLimit}
+
+	var rows []database.GetNumberedPostsForUserRow
+	if withIDs := r.Form.Get("with_ids"); withIDs != "" {
+		numbers, err := parseNumbers(withIDs)
+		if err != nil {
+			return nil, badRequest("Invalid with_ids '%s': %v", withIDs, err)
+		}
+		for _, number := range numbers[:min(len(numbers), feverItemsLimit)] {
+			params.Number = sql.NullInt64{Int64: number, Valid: true}
+			found, err := srv.s.db.GetNumberedPostsForUser(r.Context(), params)
+			if err != nil {
+				return nil, fmt.Errorf("Problem fetching posts for user '%s': %v", user.Name, err)
+			}
+			rows = append(rows, found...)
+		}
+	} else {
+		if sinceID := r.Form.Get("since_id"); sinceID != "" {
+			since, err := strconv.ParseInt(sinceID, 10, 64)
+			if err != nil {
+				return nil, badRequest("Invalid since_id '%s'", sinceID)
+			}
+			params.SinceNumber = sql.NullInt64{Int64: since, Valid: true}
+			params.Ascending = true
+		} else if maxID := r.Form.Get("max_id"); maxID != "" {
+			maxNumber, err := strconv.ParseInt(maxID, 10, 64)
+			if err != nil {
+				return nil, badRequest("Invalid max_id '%s'", maxID)
+			}
+			// max_id=0 is how apps ask for the newest
+			if maxNumber > 0 {
+				params.MaxNumber = sql.NullInt64{Int64: maxNumber, Valid: true}
+			}
+		}
+
+		var err error
+		rows, err = srv.s.db.GetNumberedPostsForUser(r.Context(), params)
+		if err != nil {
+			return nil, fmt.Errorf("Problem fetching posts for user '%s': %v", user.Name, err)
+		}
+	}
+
+	items := make([]feverItem, 0, len(rows))
+	for _, row := range rows {
+		item := feverItem{
+			ID:     row.Number,
+			FeedID: row.FeedNumber,
+			Title:  row.Title,
+			// Sanitised again in case it was stored before we sanitised
+			// posts on fetching
+			HTML:          descriptionPolicy.Sanitize(row.Description.String),
+			Url:           row.Url,
+			CreatedOnTime: row.PublishedAt.Unix(),
+		}
+		if row.IsRead {
+			item.IsRead = 1
+		}
+		if row.IsStarred {
+			item.IsSaved = 1
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// Marks one item read, unread, saved (starred) or unsaved, or everything in
+// a feed or group published before a time as read
+func (srv *server) feverMark(r *http.Request, user database.User, mark string) error {
+	as := r.Form.Get("as")
+	id, err := strconv.ParseInt(r.Form.Get("id"), 10, 64)
+	if err != nil {
+		return badRequest("Invalid id '%s'", r.Form.Get("id"))
+	}
+
+	if mark == "item" {
+		var which postState
+		var set bool
+		switch as {
+		case "read":
+			which, set = postRead, true
+		case "unread":
+			which, set = postRead, false
+		case "saved":
+			which, set = postStarred, true
+		case "unsaved":
+			which, set = postStarred, false
+		default:
+			return badRequest("Invalid as '%s' for an item: must be read, unread, saved or unsaved", as)
+		}
+
+		postID, err := srv.s.db.GetPostIDByNumber(r.Context(), id)
+		if errors.Is(err, sql.ErrNoRows) {
+			return notFound("Item %d not found", id)
+		} else if err != nil {
+			return fmt.Errorf("Problem fetching item %d: %v", id, err)
+		}
+		if _, err := srv.s.db.GetPostForUser(r.Context(), database.GetPostForUserParams{UserID: user.ID, ID: postID}); err != nil {
+			return notFound("Item %d not found in feeds followed by '%s'", id, user.Name)
+		}
+		if err := srv.setPostState(r, user, postID, which, set); err != nil {
+			return fmt.Errorf("Problem updating item %d: %v", id, err)
+		}
+		return nil
+	}
+
+	if as != "read" {
+		return badRequest("Invalid as '%s' for a %s: only read is supported", as, mark)
+	}
+	params := database.BrowsePostsForUserParams{
+		UserID:     user.ID,
+		UnreadOnly: true,
+		RowLimit:   feverMarkBatch,
+	}
+	if before := r.Form.Get("before"); before != "" {
+		seconds, err := strconv.ParseInt(before, 10, 64)
+		if err != nil {
+			return badRequest("Invalid before '%s'", before)
+		}
+		params.Until = sql.NullTime{Time: time.Unix(seconds, 0), Valid: true}
+	}
+
+	switch mark {
+	case "feed":
+		feedID, err := srv.s.db.GetFeedIDByNumber(r.Context(), id)
+		if errors.Is(err, sql.ErrNoRows) {
+			return notFound("Feed %d not found", id)
+		} else if err != nil {
+			return fmt.Errorf("Problem fetching feed %d: %v", id, err)
+		}
+		feed, err := srv.s.db.GetFeedByID(r.Context(), feedID)
+		if err != nil {
+			return fmt.Errorf("Problem fetching feed %d: %v", id, err)
+		}
+		params.FeedUrl = sql.NullString{String: feed.Url, Valid: true}
+
+	case "group":
+		switch {
+		// The "Kindling" group of everything
+		case id == 0:
+		// Sparks, which gator doesn't have
+		case id < 0:
+			return nil
+		default:
+			folders, err := srv.s.db.GetFoldersForUser(r.Context(), user.ID)
+			if err != nil {
+				return fmt.Errorf("Problem fetching folders for user '%s': %v", user.Name, err)
+			}
+			if id > int64(len(folders)) {
+				return notFound("Group %d not found", id)
+			}
+			params.Folder = sql.NullString{String: folders[id-1], Valid: true}
+		}
+
+	default:
+		return badRequest("Invalid mark '%s': must be item, feed or group", mark)
+	}
+
+	// Marked posts drop out of the unread ones, so this runs out
+	for {
+		posts, err := srv.s.db.BrowsePostsForUser(r.Context(), params)
+		if err != nil {
+			return fmt.Errorf("Problem fetching posts for user '%s': %v", user.Name, err)
+		}
+		for _, post := range posts {
+			if err := srv.setPostState(r, user, post.ID, postRead, true); err != nil {
+				return fmt.Errorf("Problem marking post '%s' read: %v", post.Title, err)
+			}
+		}
+		if len(posts) < feverMarkBatch {
+			return nil
+		}
+	}
+}
+
+// Favicons by site, fetched the first time an app asks for them - sites
+// without one are remembered as ""
+type faviconCache struct {
+	mu    sync.Mutex
+	icons map[string]string
+}
+
+// How many favicons to fetch at once
+const feverFaviconJobs = 4
+
+func (srv *server) feverFavicons(r *http.Request, feeds []database.GetNumberedFeedsForUserRow) []feverFavicon {
+	opts, err := newFetchOptions(srv.s.cfg.Fetch)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Problem fetching favicons: %v\n", err)
+		return []feverFavicon{}
+	}
+
+	srv.favicons.mu.Lock()
+	if srv.favicons.icons == nil {
+		srv.favicons.icons = make(map[string]string)
+	}
+	missing := make(map[string]bool)
+	for _, feed := range feeds {
+		site := siteURL(feed.Url)
+		if _, cached := srv.favicons.icons[site]; !cached {
+			missing[site] = true
+		}
+	}
+	srv.favicons.mu.Unlock()
+
+	// A few at a time, so a long list of feeds doesn't mean a flood of fetches
+	slots := make(chan struct{}, feverFaviconJobs)
+	var wg sync.WaitGroup
+	for site := range missing {
+		slots <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+			icon := fetchFavicon(r, opts, site)
+			srv.favicons.mu.Lock()
+			srv.favicons.icons[site] = icon
+			srv.favicons.mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	srv.favicons.mu.Lock()
+	defer srv.favicons.mu.Unlock()
+	favicons := make([]feverFavicon, 0, len(feeds))
+	for _, feed := range feeds {
+		if icon := srv.favicons.icons[siteURL(feed.Url)]; icon != "" {
+			favicons = append(favicons, feverFavicon{ID: feed.Number, Data: icon})
+		}
+	}
+	return favicons
+}
+
+// The site's /favicon.ico as "<type>;base64,<data>", or "" if it hasn't one
+func fetchFavicon(r *http.Request, opts fetchOptions, site string) string {
+	res, err := fetchURL(r.Context(), opts, site+"favicon.ico")
+	if err != nil {
+		return ""
+	}
+
+	contentType, _, _ := strings.Cut(res.contentType, ";")
+	if !strings.HasPrefix(contentType, "image/") {
+		contentType = http.DetectContentType(res.body)
+	}
+	if !strings.HasPrefix(contentType, "image/") {
+		return ""
+	}
+	return contentType + ";base64," + base64.StdEncoding.EncodeToString(res.body)
+}
+
+// The root of the site a feed is on, as gator doesn't keep feeds' links
+func siteURL(feedURL string) string {
+	parsed, err := url.Parse(feedURL)
+	if err != nil || parsed.Host == "" {
+		return feedURL
+	}
+	return parsed.Scheme + "://" + parsed.Host + "/"
+}
+
+func joinNumbers(numbers []int64) string {
+	parts := make([]string, 0, len(numbers))
+	for _, number := range numbers {
+		parts = append(parts, strconv.FormatInt(number, 10))
+	}
+	return strings.Join(parts, ",")
+}
+
+func parseNumbers(list string) ([]int64, error) {
+	var numbers []int64
+	for _, part := range strings.Split(list, ",") {
+		number, err := strconv.ParseInt(strings.TrimSpace(part), 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		numbers = append(numbers, number)
+	}
+	return numbers, nil
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+type feverResponse struct {
+	Auth          int               `json:"auth"`
+	Groups        []feverGroup      `json:"groups"`
+	FeedsGroups   []feverFeedsGroup `json:"feeds_groups"`
+	Feeds         []feverFeed       `json:"feeds"`
+	Favicons      []feverFavicon    `json:"favicons"`
+	Items         []feverItem       `json:"items"`
+	TotalItems    int64             `json:"total_items"`
+	UnreadItemIDs string            `json:"unread_item_ids"`
+	SavedItemIDs  string            `json:"saved_item_ids"`
+}
+
+// Makes a Fever request the way apps do, posting the api_key and any other
+// form values, with what to send back in the query string
+func feverRequest(t *testing.T, baseURL string, apiKey string, query string, form url.Values) (feverResponse, int) {
+	t.Helper()
+
+	if form == nil {
+		form = url.Values{}
+	}
+	form.Set("api_key", apiKey)
+	res, err := http.PostForm(baseURL+"/fever/?api&"+query, form)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	var response feverResponse
+	if res.StatusCode == http.StatusOK {
+		if err := json.NewDecoder(res.Body).Decode(&response); err != nil {
+			t.Fatalf("fever %s: problem decoding response: %v", query, err)
+		}
+	}
+	return response, res.StatusCode
+}
+
+func TestFever(t *testing.T) {
+	feeds := newFeedServer(t)
+
+	for name, newState := range testStates {
+		t.Run(name, func(t *testing.T) {
+			s := newState(t)
+			mustRun(t, s, "register", "alice")
+			mustRun(t, s, "addfeed", "RSS", feeds.URL+"/feeds/rss.xml")
+			mustRun(t, s, "addfeed", "HTML", feeds.URL+"/feeds/html.xml")
+			mustRun(t, s, "folder", feeds.URL+"/feeds/html.xml", "Blogs")
+			captureStdout(t, func() { scapeFeeds(context.Background(), s) })
+			apiKey := feverKey("alice", createToken(t, s, "phone", scopeWrite))
+			readOnlyKey := feverKey("alice", createToken(t, s, "watch", scopeRead))
+			api := httptest.NewServer(newServer(s))
+			defer api.Close()
+
+			// The database only has its hash
+			if _, err := s.db.GetAPITokenByFeverKey(context.Background(), sql.NullString{String: apiKey, Valid: true}); err == nil {
+				t.Error("found a token by its raw Fever api_key")
+			}
+			if response, _ := feverRequest(t, api.URL, "nonsense", "groups", nil); response.Auth != 0 {
+				t.Error("got auth 1 with a bad api_key")
+			}
+
+			// Posts are numbered as they're stored, so read-only keys see
+			// them without anything writing first
+			if response, _ := feverRequest(t, api.URL, readOnlyKey, "feeds&items", nil); len(response.Feeds) != 2 || len(response.Items) == 0 {
+				t.Errorf("got %d feeds and %d items with a read-only key, want both feeds and their items", len(response.Feeds), len(response.Items))
+			}
+
+			response, _ := feverRequest(t, api.URL, apiKey, "groups&feeds&favicons", nil)
+			if response.Auth != 1 || len(response.Feeds) != 2 || len(response.Favicons) != 2 {
+				t.Fatalf("got %+v, want two feeds with favicons", response)
+			}
+			if !strings.HasPrefix(response.Favicons[0].Data, "image/gif;base64,") {
+				t.Errorf("got favicon data %q, want a base64 GIF", response.Favicons[0].Data)
+			}
+			htmlFeed := response.Feeds[1]
+			if len(response.Groups) != 1 || response.Groups[0].Title != "Blogs" ||
+				response.FeedsGroups[0].FeedIDs != strconv.FormatInt(htmlFeed.ID, 10) {
+				t.Errorf("got groups %+v and %+v, want Blogs with the HTML feed", response.Groups, response.FeedsGroups)
+			}
+
+			// Paging forwards from the start, and backwards from the end
+			response, _ = feverRequest(t, api.URL, apiKey, "items&since_id=0", nil)
+			items := response.Items
+			last := len(items) - 1
+			if last < 2 || response.TotalItems != int64(len(items)) {
+				t.Fatalf("got %d items of %d, want them all and at least 3", len(items), response.TotalItems)
+			}
+			for i := 1; i < len(items); i++ {
+				if items[i].ID <= items[i-1].ID {
+					t.Errorf("items not in id order: %d then %d", items[i-1].ID, items[i].ID)
+				}
+			}
+			response, _ = feverRequest(t, api.URL, apiKey, "items&since_id="+strconv.FormatInt(items[1].ID, 10), nil)
+			if len(response.Items) != last-1 || response.Items[0].ID != items[2].ID {
+				t.Errorf("got %+v after item %d, want the ones after it", response.Items, items[1].ID)
+			}
+			response, _ = feverRequest(t, api.URL, apiKey, "items&max_id="+strconv.FormatInt(items[last].ID, 10), nil)
+			if len(response.Items) != last || response.Items[0].ID != items[last-1].ID {
+				t.Errorf("got %+v before item %d, want the ones before it newest first", response.Items, items[last].ID)
+			}
+			withIDs := strconv.FormatInt(items[0].ID, 10) + "," + strconv.FormatInt(items[last].ID, 10)
+			if response, _ = feverRequest(t, api.URL, apiKey, "items&with_ids="+withIDs, nil); len(response.Items) != 2 {
+				t.Errorf("got %d items with ids %s, want 2", len(response.Items), withIDs)
+			}
+
+			// Marking an item read and saved
+			id := strconv.FormatInt(items[0].ID, 10)
+			feverRequest(t, api.URL, apiKey, "", url.Values{"mark": {"item"}, "as": {"read"}, "id": {id}})
+			response, _ = feverRequest(t, api.URL, apiKey, "unread_item_ids", url.Values{"mark": {"item"}, "as": {"saved"}, "id": {id}})
+			if strings.Contains(","+response.UnreadItemIDs+",", ","+id+",") || len(strings.Split(response.UnreadItemIDs, ",")) != last {
+				t.Errorf("got unread ids %q after marking %s read", response.UnreadItemIDs, id)
+			}
+			if response, _ = feverRequest(t, api.URL, apiKey, "saved_item_ids", nil); response.SavedItemIDs != id {
+				t.Errorf("got saved ids %q, want %s", response.SavedItemIDs, id)
+			}
+
+			// Marking a group, then everything, read
+			before := strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10)
+			response, _ = feverRequest(t, api.URL, apiKey, "unread_item_ids", url.Values{"mark": {"group"}, "as": {"read"}, "id": {"1"}, "before": {before}})
+			for _, item := range items {
+				unread := strings.Contains(","+response.UnreadItemIDs+",", ","+strconv.FormatInt(item.ID, 10)+",")
+				if want := item.ID != items[0].ID && item.FeedID != htmlFeed.ID; unread != want {
+					t.Errorf("got item %d unread %v after marking Blogs read, want %v", item.ID, unread, want)
+				}
+			}
+			response, _ = feverRequest(t, api.URL, apiKey, "unread_item_ids", url.Values{"mark": {"group"}, "as": {"read"}, "id": {"0"}, "before": {before}})
+			if response.UnreadItemIDs != "" {
+				t.Errorf("got unread ids %q after marking everything read, want none", response.UnreadItemIDs)
+			}
+
+			// Read-only tokens can sync but not mark
+			if response, _ := feverRequest(t, api.URL, readOnlyKey, "unread_item_ids", nil); response.Auth != 1 {
+				t.Error("got auth 0 with a read-only token")
+			}
+			if _, status := feverRequest(t, api.URL, readOnlyKey, "", url.Values{"mark": {"item"}, "as": {"unread"}, "id": {id}}); status != http.StatusForbidden {
+				t.Errorf("got status %d marking with a read-only token, want 403", status)
+			}
+		})
+	}
+}
@@ -0,0 +1,853 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/venzy/gator/internal/database"
+)
+
+// The Google Reader API, as spoken by FreshRSS and Miniflux, for apps like
+// NetNewsWire, FeedMe and Newsflash. Apps are pointed at the server's root
+// URL, log in through ClientLogin with the user's name and an API token, and
+// then send that token as "Authorization: GoogleLogin auth=<token>".
+//
+// Streams are the reading list, starred and read states, feeds ("feed/<n>",
+// numbered as for Fever) and labels, which are folders or, failing that, tags.
+const (
+	greaderReadingList = "user/-/state/com.google/reading-list"
+	greaderRead        = "user/-/state/com.google/read"
+	greaderStarred     = "user/-/state/com.google/starred"
+	greaderLabelPrefix = "user/-/label/"
+	greaderFeedPrefix  = "feed/"
+	greaderItemPrefix  = "tag:google.com,2005:reader/item/"
+)
+
+// Default and most items in a stream at once
+const (
+	greaderDefaultCount = 20
+	greaderMaxCount     = 1000
+)
+
+func (srv *server) registerGoogleReaderRoutes() {
+	srv.mux.HandleFunc("/accounts/ClientLogin", srv.greaderLogin)
+
+	const api = "/reader/api/0/"
+	srv.mux.HandleFunc("GET "+api+"token", srv.withGReaderUser(srv.greaderToken))
+	srv.mux.HandleFunc("GET "+api+"user-info", srv.withGReaderUser(srv.greaderUserInfo))
+	srv.mux.HandleFunc("GET "+api+"subscription/list", srv.withGReaderUser(srv.greaderSubscriptions))
+	srv.mux.HandleFunc("POST "+api+"subscription/edit", srv.withGReaderUser(srv.greaderEditSubscription))
+	srv.mux.HandleFunc("POST "+api+"subscription/quickadd", srv.withGReaderUser(srv.greaderQuickAdd))
+	srv.mux.HandleFunc("GET "+api+"tag/list", srv.withGReaderUser(srv.greaderTags))
+	srv.mux.HandleFunc("GET "+api+"unread-count", srv.withGReaderUser(srv.greaderUnreadCounts))
+	srv.mux.HandleFunc("GET "+api+"stream/items/ids", srv.withGReaderUser(srv.greaderItemIDs))
+	srv.mux.HandleFunc("GET "+api+"stream/contents/{stream...}", srv.withGReaderUser(srv.greaderStreamContents))
+	// Apps post item ids here just to fit more of them in, so it's a read
+	srv.mux.HandleFunc(api+"stream/items/contents", srv.withGReaderReader(srv.greaderItemContents))
+	srv.mux.HandleFunc("POST "+api+"edit-tag", srv.withGReaderUser(srv.greaderEditTags))
+	srv.mux.HandleFunc("POST "+api+"mark-all-as-read", srv.withGReaderUser(srv.greaderMarkAllRead))
+}
+
+type greaderSubscription struct {
+	ID         string            `json:"id"`
+	Title      string            `json:"title"`
+	Categories []greaderCategory `json:"categories"`
+	Url        string            `json:"url"`
+	HtmlUrl    string            `json:"htmlUrl"`
+	IconUrl    string            `json:"iconUrl"`
+}
+
+type greaderCategory struct {
+	ID    string `json:"id"`
+	Label string `json:"label"`
+}
+
+type greaderTag struct {
+	ID   string `json:"id"`
+	Type string `json:"type,omitempty"`
+}
+
+type greaderUnreadCount struct {
+	ID    string `json:"id"`
+	Count int64  `json:"count"`
+	// Microseconds, as a string like all of Google Reader's timestamps
+	NewestItemTimestampUsec string `json:"newestItemTimestampUsec"`
+}
+
+type greaderItemRef struct {
+	ID              string   `json:"id"`
+	DirectStreamIDs []string `json:"directStreamIds"`
+	TimestampUsec   string   `json:"timestampUsec"`
+}
+
+type greaderItem struct {
+	ID            string         `json:"id"`
+	CrawlTimeMsec string         `json:"crawlTimeMsec"`
+	TimestampUsec string         `json:"timestampUsec"`
+	Published     int64          `json:"published"`
+	Updated       int64          `json:"updated"`
+	Title         string         `json:"title"`
+	Author        string         `json:"author"`
+	Canonical     []greaderLink  `json:"canonical"`
+	Alternate     []greaderLink  `json:"alternate"`
+	Categories    []string       `json:"categories"`
+	Origin        greaderOrigin  `json:"origin"`
+	Summary       greaderContent `json:"summary"`
+}
+
+type greaderLink struct {
+	Href string `json:"href"`
+	Type string `json:"type,omitempty"`
+}
+
+type greaderOrigin struct {
+	StreamID string `json:"streamId"`
+	Title    string `json:"title"`
+	HtmlUrl  string `json:"htmlUrl"`
+}
+
+type greaderContent struct {
+	Direction string `json:"direction"`
+	Content   string `json:"content"`
+}
+
+type greaderStream struct {
+	Direction    string        `json:"direction"`
+	ID           string        `json:"id"`
+	Updated      int64         `json:"updated"`
+	Items        []greaderItem `json:"items"`
+	Continuation string        `json:"continuation,omitempty"`
+}
+
+// Like withUser, with the token from a GoogleLogin header, form values
+// parsed from the query and body, and errors as plain text, which is what
+// the apps expect
+func (srv *server) withGReaderUser(handler apiHandler) http.HandlerFunc {
+	return srv.greaderHandler(handler, false)
+}
+
+// Like withGReaderUser, but for requests that only read, whatever their
+// method, so read tokens can make them
+func (srv *server) withGReaderReader(handler apiHandler) http.HandlerFunc {
+	return srv.greaderHandler(handler, true)
+}
+
+func (srv *server) greaderHandler(handler apiHandler, reads bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodySize)
+		err := r.ParseForm()
+		if err != nil {
+			err = badRequest("Invalid request: %v", err)
+		}
+
+		if err == nil {
+			token, found := strings.CutPrefix(r.Header.Get("Authorization"), "GoogleLogin auth=")
+			if !found {
+				token = ""
+			}
+			var user database.User
+			writes := !reads && r.Method != http.MethodGet && r.Method != http.MethodHead
+			user, err = srv.scopedTokenUser(r, strings.TrimSpace(token), writes)
+			if err == nil {
+				err = handler(w, r, user)
+			}
+		}
+
+		if err != nil {
+			status := http.StatusInternalServerError
+			var apiErr *apiError
+			if errors.As(err, &apiErr) {
+				status = apiErr.status
+			} else {
+				fmt.Fprintf(os.Stderr, "Problem handling request: %v\n", err)
+			}
+			http.Error(w, err.Error(), status)
+		}
+	}
+}
+
+func writeOK(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprint(w, "OK")
+}
+
+// Logs in with the user's name as the Email and an API token as the Passwd,
+// handing back the token to authorise requests with
+func (srv *server) greaderLogin(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodySize)
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Error=BadAuthentication", http.StatusBadRequest)
+		return
+	}
+	name := r.Form.Get("Email")
+	token := strings.TrimSpace(r.Form.Get("Passwd"))
+
+	user, err := srv.s.db.GetUserByName(r.Context(), name)
+	if err != nil {
+		http.Error(w, "Error=BadAuthentication", http.StatusUnauthorized)
+		return
+	}
+	apiToken, err := srv.s.db.GetAPITokenByHash(r.Context(), hashToken(token))
+	if err != nil || apiToken.UserID != user.ID {
+		http.Error(w, "Error=BadAuthentication", http.StatusUnauthorized)
+		return
+	}
+
+	if r.Form.Get("output") == "json" {
+		writeJSON(w, http.StatusOK, map[string]string{"SID": token, "LSID": token, "Auth": token})
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintf(w, "SID=%s\nLSID=%s\nAuth=%s\n", token, token, token)
+}
+
+// The token apps send back as T with edits. It isn't checked - requests
+// carry the auth header, not a cookie, so there's no forgery to guard
+// against - but apps insist on having one.
+func (srv *server) greaderToken(w http.ResponseWriter, r *http.Request, user database.User) error {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprint(w, user.ID.String())
+	return nil
+}
+
+func (srv *server) greaderUserInfo(w http.ResponseWriter, r *http.Request, user database.User) error {
+	writeJSON(w, http.StatusOK, map[string]string{
+		"userId":        user.ID.String(),
+		"userName":      user.Name,
+		"userProfileId": user.ID.String(),
+		"userEmail":     "",
+	})
+	return nil
+}
+
+func (srv *server) greaderSubscriptions(w http.ResponseWriter, r *http.Request, user database.User) error {
+	if err := srv.s.db.NumberNewFeeds(r.Context()); err != nil {
+		return fmt.Errorf("Problem numbering feeds: %v", err)
+	}
+	feeds, err := srv.s.db.GetNumberedFeedsForUser(r.Context(), user.ID)
+	if err != nil {
+		return fmt.Errorf("Problem fetching feeds for user '%s': %v", user.Name, err)
+	}
+
+	subscriptions := make([]greaderSubscription, 0, len(feeds))
+	for _, feed := range feeds {
+		subscription := greaderSubscription{
+			ID:         greaderFeedID(feed.Number),
+			Title:      feed.Name,
+			Categories: []greaderCategory{},
+			Url:        feed.Url,
+			HtmlUrl:    siteURL(feed.Url),
+		}
+		if feed.Folder.Valid {
+			subscription.Categories = append(subscription.Categories, greaderCategory{
+				ID:    greaderLabelPrefix + feed.Folder.String,
+				Label: feed.Folder.String,
+			})
+		}
+		subscriptions = append(subscriptions, subscription)
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"subscriptions": subscriptions})
+	return nil
+}
+
+// Subscribes to, unsubscribes from or edits the labels of the feeds in s.
+// A feed's title can't be changed, as feeds are shared between users.
+func (srv *server) greaderEditSubscription(w http.ResponseWriter, r *http.Request, user database.User) error {
+	addFolder, err := greaderLabel(r.Form.Get("a"))
+	if err != nil {
+		return err
+	}
+	removeFolder, err := greaderLabel(r.Form.Get("r"))
+	if err != nil {
+		return err
+	}
+
+	for _, streamID := range r.Form["s"] {
+		var feed database.Feed
+		action := r.Form.Get("ac")
+		switch action {
+		case "subscribe":
+			feedURL, found := strings.CutPrefix(streamID, greaderFeedPrefix)
+			if !found {
+				return badRequest("Invalid feed '%s'", streamID)
+			}
+			if feed, err = srv.greaderSubscribe(r, user, feedURL, r.Form.Get("t")); err != nil {
+				return err
+			}
+
+		case "unsubscribe":
+			if feed, err = srv.greaderFeed(r, streamID); err != nil {
+				return err
+			}
+			err = srv.s.db.DeleteFeedFollow(r.Context(), database.DeleteFeedFollowParams{UserID: user.ID, FeedID: feed.ID})
+			if err != nil {
+				return fmt.Errorf("Problem unfollowing '%s' for user '%s': %v", feed.Url, user.Name, err)
+			}
+			continue
+
+		case "edit":
+			if feed, err = srv.greaderFeed(r, streamID); err != nil {
+				return err
+			}
+
+		default:
+			return badRequest("Invalid ac '%s': must be subscribe, unsubscribe or edit", action)
+		}
+
+		if addFolder != "" {
+			err = srv.setFolder(r, user, feed, addFolder)
+		} else if removeFolder != "" {
+			err = srv.setFolder(r, user, feed, "")
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	writeOK(w)
+	return nil
+}
+
+func (srv *server) greaderQuickAdd(w http.ResponseWriter, r *http.Request, user database.User) error {
+	feedURL := strings.TrimPrefix(r.Form.Get("quickadd"), greaderFeedPrefix)
+	if feedURL == "" {
+		return badRequest("Nothing to add: quickadd must be a feed URL")
+	}
+
+	feed, err := srv.greaderSubscribe(r, user, feedURL, "")
+	if err != nil {
+		return err
+	}
+	if err := srv.s.db.NumberNewFeeds(r.Context()); err != nil {
+		return fmt.Errorf("Problem numbering feeds: %v", err)
+	}
+	streamID, err := srv.greaderFeedStreamID(r, user, feed)
+	if err != nil {
+		return err
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"numResults": 1,
+		"query":      feedURL,
+		"streamId":   streamID,
+		"streamName": feed.Name,
+	})
+	return nil
+}
+
+// Follows a feed, adding it first if it's new to gator - named after the URL
+// if there's no title, as addfeed needs a name
+func (srv *server) greaderSubscribe(r *http.Request, user database.User, feedURL string, title string) (database.Feed, error) {
+	now := time.Now()
+	feed, err := srv.s.db.GetFeedByURL(r.Context(), feedURL)
+	if errors.Is(err, sql.ErrNoRows) {
+		if title == "" {
+			title = feedURL
+		}
+		feed, err = srv.s.db.CreateFeed(
+			r.Context(),
+			database.CreateFeedParams{
+				ID:        uuid.New(),
+				CreatedAt: now,
+				UpdatedAt: now,
+				Name:      title,
+				Url:       feedURL,
+				UserID:    user.ID,
+			})
+		if err != nil {
+			return database.Feed{}, fmt.Errorf("Problem creating feed: %v", err)
+		}
+	} else if err != nil {
+		return database.Feed{}, fmt.Errorf("Problem fetching feed '%s': %v", feedURL, err)
+	}
+
+	if _, err := srv.findFollow(r, user, feed.ID); err == nil {
+		return feed, nil
+	}
+	_, err = srv.s.db.CreateFeedFollow(
+		r.Context(),
+		database.CreateFeedFollowParams{
+			ID:        uuid.New(),
+			CreatedAt: now,
+			UpdatedAt: now,
+			UserID:    user.ID,
+			FeedID:    feed.ID,
+		})
+	if err != nil {
+		return database.Feed{}, fmt.Errorf("Problem creating feed_follows record for user '%s' and feed URL '%s': %v", user.Name, feed.Url, err)
+	}
+	return feed, nil
+}
+
+// Folders and tags are both labels
+func (srv *server) greaderTags(w http.ResponseWriter, r *http.Request, user database.User) error {
+	folders, err := srv.s.db.GetFoldersForUser(r.Context(), user.ID)
+	if err != nil {
+		return fmt.Errorf("Problem fetching folders for user '%s': %v", user.Name, err)
+	}
+	counts, err := srv.s.db.GetTagCountsForUser(r.Context(), user.ID)
+	if err != nil {
+		return fmt.Errorf("Problem fetching tags for user '%s': %v", user.Name, err)
+	}
+
+	tags := []greaderTag{{ID: greaderStarred}}
+	for _, folder := range folders {
+		tags = append(tags, greaderTag{ID: greaderLabelPrefix + folder, Type: "folder"})
+	}
+	for _, count := range counts {
+		tags = append(tags, greaderTag{ID: greaderLabelPrefix + count.Tag, Type: "tag"})
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"tags": tags})
+	return nil
+}
+
+// Unread counts for each feed and folder, and the reading list as a whole
+func (srv *server) greaderUnreadCounts(w http.ResponseWriter, r *http.Request, user database.User) error {
+	if err := srv.s.db.NumberNewFeeds(r.Context()); err != nil {
+		return fmt.Errorf("Problem numbering feeds: %v", err)
+	}
+	feeds, err := srv.s.db.GetNumberedFeedsForUser(r.Context(), user.ID)
+	if err != nil {
+		return fmt.Errorf("Problem fetching feeds for user '%s': %v", user.Name, err)
+	}
+	unread, err := srv.s.db.GetFollowedFeedsWithUnreadCounts(r.Context(), user.ID)
+	if err != nil {
+		return fmt.Errorf("Problem fetching unread counts for user '%s': %v", user.Name, err)
+	}
+	unreadByURL := make(map[string]int64)
+	for _, feed := range unread {
+		unreadByURL[feed.Url] = feed.UnreadCount
+	}
+
+	var total int64
+	folderCounts := make(map[string]int64)
+	counts := []greaderUnreadCount{}
+	for _, feed := range feeds {
+		count := unreadByURL[feed.Url]
+		total += count
+		if feed.Folder.Valid {
+			folderCounts[feed.Folder.String] += count
+		}
+		counts = append(counts, greaderUnreadCount{ID: greaderFeedID(feed.Number), Count: count, NewestItemTimestampUsec: "0"})
+	}
+	for folder, count := range folderCounts {
+		counts = append(counts, greaderUnreadCount{ID: greaderLabelPrefix + folder, Count: count, NewestItemTimestampUsec: "0"})
+	}
+	counts = append(counts, greaderUnreadCount{ID: greaderReadingList, Count: total, NewestItemTimestampUsec: "0"})
+
+	writeJSON(w, http.StatusOK, map[string]any{"max": greaderMaxCount, "unreadcounts": counts})
+	return nil
+}
+
+func (srv *server) greaderItemIDs(w http.ResponseWriter, r *http.Request, user database.User) error {
+	rows, continuation, err := srv.greaderStreamItems(r, user, r.Form.Get("s"))
+	if err != nil {
+		return err
+	}
+
+	refs := make([]greaderItemRef, 0, len(rows))
+	for _, row := range rows {
+		refs = append(refs, greaderItemRef{
+			ID:              strconv.FormatInt(row.Number, 10),
+			DirectStreamIDs: []string{},
+			TimestampUsec:   strconv.FormatInt(row.PublishedAt.UnixMicro(), 10),
+		})
+	}
+	response := map[string]any{"itemRefs": refs}
+	if continuation != "" {
+		response["continuation"] = continuation
+	}
+	writeJSON(w, http.StatusOK, response)
+	return nil
+}
+
+func (srv *server) greaderStreamContents(w http.ResponseWriter, r *http.Request, user database.User) error {
+	streamID := r.PathValue("stream")
+	if streamID == "" {
+		streamID = greaderReadingList
+	}
+	rows, continuation, err := srv.greaderStreamItems(r, user, streamID)
+	if err != nil {
+		return err
+	}
+	return srv.writeGReaderItems(w, r, user, streamID, rows, continuation)
+}
+
+// The items asked for by id with i, as apps do after fetching ids
+func (srv *server) greaderItemContents(w http.ResponseWriter, r *http.Request, user database.User) error {
+	var rows []database.GetNumberedPostsForUserRow
+	for _, itemID := range r.Form["i"] {
+		row, err := srv.greaderItem(r, user, itemID)
+		if err != nil {
+			return err
+		}
+		rows = append(rows, row)
+	}
+	return srv.writeGReaderItems(w, r, user, greaderReadingList, rows, "")
+}
+
+// Adds and removes the read and starred states, and labels, which here are
+// tags, on the items in i
+func (srv *server) greaderEditTags(w http.ResponseWriter, r *http.Request, user database.User) error {
+	for _, itemID := range r.Form["i"] {
+		row, err := srv.greaderItem(r, user, itemID)
+		if err != nil {
+			return err
+		}
+
+		for _, change := range []struct {
+			tags []string
+			set  bool
+		}{{r.Form["a"], true}, {r.Form["r"], false}} {
+			for _, tag := range change.tags {
+				if err := srv.greaderSetTag(r, user, row, greaderStreamID(tag), change.set); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	writeOK(w)
+	return nil
+}
+
+func (srv *server) greaderSetTag(r *http.Request, user database.User, row database.GetNumberedPostsForUserRow, tag string, set bool) error {
+	switch {
+	case tag == greaderRead:
+		return srv.setPostState(r, user, row.ID, postRead, set)
+	case tag == greaderStarred:
+		return srv.setPostState(r, user, row.ID, postStarred, set)
+	case strings.HasPrefix(tag, greaderLabelPrefix):
+		name, err := normaliseTag(strings.TrimPrefix(tag, greaderLabelPrefix))
+		if err != nil {
+			return badRequest("%v", err)
+		}
+		if set {
+			err = srv.s.db.CreatePostTag(
+				r.Context(),
+				database.CreatePostTagParams{
+					ID:        uuid.New(),
+					CreatedAt: time.Now(),
+					UserID:    user.ID,
+					PostID:    row.ID,
+					Tag:       name,
+				})
+		} else {
+			_, err = srv.s.db.DeletePostTag(
+				r.Context(),
+				database.DeletePostTagParams{
+					UserID: user.ID,
+					PostID: row.ID,
+					Tag:    name,
+				})
+		}
+		if err != nil {
+			return fmt.Errorf("Problem tagging post '%s': %v", row.Title, err)
+		}
+		return nil
+	default:
+		// Other states, like kept-unread, are nothing to gator
+		return nil
+	}
+}
+
+// Marks everything in a stream read, up to ts if given
+func (srv *server) greaderMarkAllRead(w http.ResponseWriter, r *http.Request, user database.User) error {
+	params, err := srv.greaderStreamParams(r, user, r.Form.Get("s"))
+	if err != nil {
+		return err
+	}
+	params.Read = sql.NullBool{Bool: false, Valid: true}
+	params.RowLimit = greaderMaxCount
+	if ts := r.Form.Get("ts"); ts != "" {
+		usec, err := strconv.ParseInt(ts, 10, 64)
+		if err != nil {
+			return badRequest("Invalid ts '%s'", ts)
+		}
+		params.PublishedUntil = sql.NullTime{Time: time.UnixMicro(usec), Valid: true}
+	}
+
+	// Marked posts drop out of the unread ones, so this runs out
+	for {
+		rows, err := srv.s.db.GetNumberedPostsForUser(r.Context(), params)
+		if err != nil {
+			return fmt.Errorf("Problem fetching posts for user '%s': %v", user.Name, err)
+		}
+		for _, row := range rows {
+			if err := srv.setPostState(r, user, row.ID, postRead, true); err != nil {
+				return fmt.Errorf("Problem marking post '%s' read: %v", row.Title, err)
+			}
+		}
+		if len(rows) < int(params.RowLimit) {
+			break
+		}
+	}
+
+	writeOK(w)
+	return nil
+}
+
+// A page of a stream, newest first unless r=o, along with the continuation
+// for the next page if there may be one. xt and it exclude and include the
+// read and starred states, and ot and nt limit by publication time.
+func (srv *server) greaderStreamItems(r *http.Request, user database.User, streamID string) ([]database.GetNumberedPostsForUserRow, string, error) {
+	params, err := srv.greaderStreamParams(r, user, streamID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	params.RowLimit = greaderDefaultCount
+	if n := r.Form.Get("n"); n != "" {
+		count, err := strconv.Atoi(n)
+		if err != nil || count < 1 {
+			return nil, "", badRequest("Invalid n '%s'", n)
+		}
+		params.RowLimit = int32(min(count, greaderMaxCount))
+	}
+
+	params.Ascending = r.Form.Get("r") == "o"
+	if c := r.Form.Get("c"); c != "" {
+		continuation, err := strconv.ParseInt(c, 10, 64)
+		if err != nil {
+			return nil, "", badRequest("Invalid continuation '%s'", c)
+		}
+		if params.Ascending {
+			params.SinceNumber = sql.NullInt64{Int64: continuation, Valid: true}
+		} else {
+			params.MaxNumber = sql.NullInt64{Int64: continuation, Valid: true}
+		}
+	}
+
+	for _, exclude := range r.Form["xt"] {
+		if greaderStreamID(exclude) == greaderRead {
+			params.Read = sql.NullBool{Bool: false, Valid: true}
+		}
+	}
+	for _, include := range r.Form["it"] {
+		switch greaderStreamID(include) {
+		case greaderRead:
+			params.Read = sql.NullBool{Bool: true, Valid: true}
+		case greaderStarred:
+			params.StarredOnly = true
+		}
+	}
+	for name, field := range map[string]*sql.NullTime{"ot": &params.PublishedSince, "nt": &params.PublishedUntil} {
+		if value := r.Form.Get(name); value != "" {
+			seconds, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return nil, "", badRequest("Invalid %s '%s'", name, value)
+			}
+			*field = sql.NullTime{Time: time.Unix(seconds, 0), Valid: true}
+		}
+	}
+
+	rows, err := srv.s.db.GetNumberedPostsForUser(r.Context(), params)
+	if err != nil {
+		return nil, "", fmt.Errorf("Problem fetching posts for user '%s': %v", user.Name, err)
+	}
+	var continuation string
+	if len(rows) == int(params.RowLimit) {
+		continuation = strconv.FormatInt(rows[len(rows)-1].Number, 10)
+	}
+	return rows, continuation, nil
+}
+
+// Query parameters for the posts in a stream
+func (srv *server) greaderStreamParams(r *http.Request, user database.User, streamID string) (database.GetNumberedPostsForUserParams, error) {
+	params := database.GetNumberedPostsForUserParams{UserID: user.ID}
+
+	if err := srv.s.db.NumberNewFeeds(r.Context()); err != nil {
+		return params, fmt.Errorf("Problem numbering feeds: %v", err)
+	}
+	if err := srv.s.db.NumberNewPosts(r.Context()); err != nil {
+		return params, fmt.Errorf("Problem numbering posts: %v", err)
+	}
+
+	streamID = greaderStreamID(streamID)
+	switch {
+	case streamID == "" || streamID == greaderReadingList:
+	case streamID == greaderStarred:
+		params.StarredOnly = true
+	case streamID == greaderRead:
+		params.Read = sql.NullBool{Bool: true, Valid: true}
+	case strings.HasPrefix(streamID, greaderFeedPrefix):
+		feed, err := srv.greaderFeed(r, streamID)
+		if err != nil {
+			return params, err
+		}
+		params.FeedID = uuid.NullUUID{UUID: feed.ID, Valid: true}
+	case strings.HasPrefix(streamID, greaderLabelPrefix):
+		label := strings.TrimPrefix(streamID, greaderLabelPrefix)
+		folders, err := srv.s.db.GetFoldersForUser(r.Context(), user.ID)
+		if err != nil {
+			return params, fmt.Errorf("Problem fetching folders for user '%s': %v", user.Name, err)
+		}
+		if slices.Contains(folders, label) {
+			params.Folder = sql.NullString{String: label, Valid: true}
+		} else {
+			params.Tag = sql.NullString{String: strings.ToLower(label), Valid: true}
+		}
+	default:
+		return params, badRequest("Invalid stream '%s'", streamID)
+	}
+	return params, nil
+}
+
+func (srv *server) writeGReaderItems(w http.ResponseWriter, r *http.Request, user database.User, streamID string, rows []database.GetNumberedPostsForUserRow, continuation string) error {
+	items := make([]greaderItem, 0, len(rows))
+	for _, row := range rows {
+		categories := []string{greaderReadingList}
+		if row.IsRead {
+			categories = append(categories, greaderRead)
+		}
+		if row.IsStarred {
+			categories = append(categories, greaderStarred)
+		}
+		if row.Folder.Valid {
+			categories = append(categories, greaderLabelPrefix+row.Folder.String)
+		}
+		tags, err := srv.s.db.GetTagsForPost(r.Context(), database.GetTagsForPostParams{UserID: user.ID, PostID: row.ID})
+		if err != nil {
+			return fmt.Errorf("Problem fetching tags for post '%s': %v", row.Title, err)
+		}
+		for _, tag := range tags {
+			categories = append(categories, greaderLabelPrefix+tag)
+		}
+
+		items = append(items, greaderItem{
+			ID:            fmt.Sprintf("%s%016x", greaderItemPrefix, row.Number),
+			CrawlTimeMsec: strconv.FormatInt(row.CreatedAt.UnixMilli(), 10),
+			TimestampUsec: strconv.FormatInt(row.PublishedAt.UnixMicro(), 10),
+			Published:     row.PublishedAt.Unix(),
+			Updated:       row.PublishedAt.Unix(),
+			Title:         row.Title,
+			Canonical:     []greaderLink{{Href: row.Url}},
+			Alternate:     []greaderLink{{Href: row.Url, Type: "text/html"}},
+			Categories:    categories,
+			Origin: greaderOrigin{
+				StreamID: greaderFeedID(row.FeedNumber),
+				Title:    row.FeedName,
+				HtmlUrl:  siteURL(row.FeedUrl),
+			},
+			// Sanitised again in case it was stored before we sanitised
+			// posts on fetching
+			Summary: greaderContent{Direction: "ltr", Content: descriptionPolicy.Sanitize(row.Description.String)},
+		})
+	}
+
+	writeJSON(w, http.StatusOK, greaderStream{
+		Direction:    "ltr",
+		ID:           streamID,
+		Updated:      time.Now().Unix(),
+		Items:        items,
+		Continuation: continuation,
+	})
+	return nil
+}
+
+// An item by its id, either the long "tag:google.com,..." form in hex or
+// just the number
+func (srv *server) greaderItem(r *http.Request, user database.User, itemID string) (database.GetNumberedPostsForUserRow, error) {
+	var number int64
+	var err error
+	if hex, found := strings.CutPrefix(itemID, greaderItemPrefix); found {
+		var unsigned uint64
+		unsigned, err = strconv.ParseUint(hex, 16, 64)
+		number = int64(unsigned)
+	} else {
+		number, err = strconv.ParseInt(itemID, 10, 64)
+	}
+	if err != nil {
+		return database.GetNumberedPostsForUserRow{}, badRequest("Invalid item id '%s'", itemID)
+	}
+
+	rows, err := srv.s.db.GetNumberedPostsForUser(
+		r.Context(),
+		database.GetNumberedPostsForUserParams{
+			UserID:   user.ID,
+			Number:   sql.NullInt64{Int64: number, Valid: true},
+			RowLimit: 1,
+		})
+	if err != nil {
+		return database.GetNumberedPostsForUserRow{}, fmt.Errorf("Problem fetching item %d: %v", number, err)
+	}
+	if len(rows) == 0 {
+		return database.GetNumberedPostsForUserRow{}, notFound("Item '%s' not found in feeds followed by '%s'", itemID, user.Name)
+	}
+	return rows[0], nil
+}
+
+// A feed by its stream id, "feed/" and either its number or its URL
+func (srv *server) greaderFeed(r *http.Request, streamID string) (database.Feed, error) {
+	rest, found := strings.CutPrefix(streamID, greaderFeedPrefix)
+	if !found {
+		return database.Feed{}, badRequest("Invalid feed '%s'", streamID)
+	}
+
+	if number, err := strconv.ParseInt(rest, 10, 64); err == nil {
+		feedID, err := srv.s.db.GetFeedIDByNumber(r.Context(), number)
+		if errors.Is(err, sql.ErrNoRows) {
+			return database.Feed{}, notFound("Feed '%s' not found", streamID)
+		} else if err != nil {
+			return database.Feed{}, fmt.Errorf("Problem fetching feed '%s': %v", streamID, err)
+		}
+		feed, err := srv.s.db.GetFeedByID(r.Context(), feedID)
+		if err != nil {
+			return database.Feed{}, fmt.Errorf("Problem fetching feed '%s': %v", streamID, err)
+		}
+		return feed, nil
+	}
+
+	feed, err := srv.s.db.GetFeedByURL(r.Context(), rest)
+	if err != nil {
+		return database.Feed{}, notFound("Feed '%s' not found", streamID)
+	}
+	return feed, nil
+}
+
+func (srv *server) greaderFeedStreamID(r *http.Request, user database.User, feed database.Feed) (string, error) {
+	feeds, err := srv.s.db.GetNumberedFeedsForUser(r.Context(), user.ID)
+	if err != nil {
+		return "", fmt.Errorf("Problem fetching feeds: %v", err)
+	}
+	for _, numbered := range feeds {
+		if numbered.Url == feed.Url {
+			return greaderFeedID(numbered.Number), nil
+		}
+	}
+	return greaderFeedPrefix + feed.Url, nil
+}
+
+func greaderFeedID(number int64) string {
+	return greaderFeedPrefix + strconv.FormatInt(number, 10)
+}
+
+// Stream ids can name the user, as "user/<id>/...", or not, as "user/-/...",
+// which is all the same to gator
+func greaderStreamID(streamID string) string {
+	parts := strings.SplitN(streamID, "/", 3)
+	if len(parts) == 3 && parts[0] == "user" {
+		return "user/-/" + parts[2]
+	}
+	return streamID
+}
+
+// The folder named by a label, or "" if there's none
+func greaderLabel(streamID string) (string, error) {
+	if streamID == "" {
+		return "", nil
+	}
+	label, found := strings.CutPrefix(greaderStreamID(streamID), greaderLabelPrefix)
+	if !found || label == "" {
+		return "", badRequest("Invalid label '%s'", streamID)
+	}
+	return label, nil
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Makes a Google Reader request, decoding JSON responses into out and
+// returning the body of any other
+func greaderRequest(t *testing.T, baseURL string, token string, method string, path string, form url.Values, out any) (string, int) {
+	t.Helper()
+
+	var body io.Reader
+	if method == http.MethodPost {
+		body = strings.NewReader(form.Encode())
+	} else if form != nil {
+		path += "?" + form.Encode()
+	}
+	req, err := http.NewRequest(method, baseURL+path, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if method == http.MethodPost {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "GoogleLogin auth="+token)
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != nil && res.StatusCode == http.StatusOK {
+		if err := json.Unmarshal(data, out); err != nil {
+			t.Fatalf("%s %s: problem decoding response %q: %v", method, path, data, err)
+		}
+	}
+	return string(data), res.StatusCode
+}
+
+func TestGoogleReader(t *testing.T) {
+	feeds := newFeedServer(t)
+
+	for name, newState := range testStates {
+		t.Run(name, func(t *testing.T) {
+			s := newState(t)
+			mustRun(t, s, "register", "alice")
+			mustRun(t, s, "addfeed", "RSS", feeds.URL+"/feeds/rss.xml")
+			mustRun(t, s, "addfeed", "HTML", feeds.URL+"/feeds/html.xml")
+			mustRun(t, s, "folder", feeds.URL+"/feeds/html.xml", "Blogs")
+			captureStdout(t, func() { scapeFeeds(context.Background(), s) })
+			token := createToken(t, s, "reader", scopeWrite)
+			readToken := createToken(t, s, "watch", scopeRead)
+			api := httptest.NewServer(newServer(s))
+			defer api.Close()
+
+			login := url.Values{"Email": {"alice"}, "Passwd": {"nonsense"}}
+			if _, status := greaderRequest(t, api.URL, "", http.MethodPost, "/accounts/ClientLogin", login, nil); status != http.StatusUnauthorized {
+				t.Errorf("got status %d logging in with a bad token, want 401", status)
+			}
+			login.Set("Passwd", token)
+			body, _ := greaderRequest(t, api.URL, "", http.MethodPost, "/accounts/ClientLogin", login, nil)
+			if !strings.Contains(body, "Auth="+token) {
+				t.Fatalf("got login response %q, want the token as Auth", body)
+			}
+
+			var subscriptions struct {
+				Subscriptions []greaderSubscription `json:"subscriptions"`
+			}
+			greaderRequest(t, api.URL, token, http.MethodGet, "/reader/api/0/subscription/list", url.Values{"output": {"json"}}, &subscriptions)
+			if len(subscriptions.Subscriptions) != 2 {
+				t.Fatalf("got %+v, want two subscriptions", subscriptions)
+			}
+			blogs := subscriptions.Subscriptions[1]
+			if len(blogs.Categories) != 1 || blogs.Categories[0].ID != "user/-/label/Blogs" {
+				t.Errorf("got categories %+v for the HTML feed, want the Blogs folder", blogs.Categories)
+			}
+
+			var stream greaderStream
+			greaderRequest(t, api.URL, token, http.MethodGet, "/reader/api/0/stream/contents/user/-/state/com.google/reading-list", url.Values{"n": {"2"}}, &stream)
+			if len(stream.Items) != 2 || stream.Continuation == "" {
+				t.Fatalf("got %d items and continuation %q, want two and a continuation", len(stream.Items), stream.Continuation)
+			}
+			first := stream.Items[0]
+			greaderRequest(t, api.URL, token, http.MethodGet, "/reader/api/0/stream/contents/user/-/state/com.google/reading-list", url.Values{"n": {"2"}, "c": {stream.Continuation}}, &stream)
+			if len(stream.Items) == 0 || stream.Items[0].ID == first.ID {
+				t.Errorf("got %+v for the next page, want different items", stream.Items)
+			}
+
+			var folder greaderStream
+			greaderRequest(t, api.URL, token, http.MethodGet, "/reader/api/0/stream/contents/user/-/label/Blogs", nil, &folder)
+			for _, item := range folder.Items {
+				if item.Origin.StreamID != blogs.ID {
+					t.Errorf("got item from %s in the Blogs folder", item.Origin.StreamID)
+				}
+			}
+
+			// ot gives items from a time on, nt those from before it
+			cutoff := time.Date(2025, 1, 7, 9, 0, 0, 0, time.UTC).Unix()
+			for _, tt := range []struct {
+				param string
+				after bool
+				want  int
+			}{{"ot", true, 2}, {"nt", false, 1}} {
+				var timed greaderStream
+				greaderRequest(t, api.URL, token, http.MethodGet, "/reader/api/0/stream/contents/user/-/state/com.google/reading-list", url.Values{tt.param: {strconv.FormatInt(cutoff, 10)}}, &timed)
+				if len(timed.Items) != tt.want {
+					t.Errorf("got %d items with %s, want %d", len(timed.Items), tt.param, tt.want)
+				}
+				for _, item := range timed.Items {
+					if (item.Published >= cutoff) != tt.after {
+						t.Errorf("got item published at %d with %s=%d", item.Published, tt.param, cutoff)
+					}
+				}
+			}
+
+			edit := url.Values{"i": {first.ID}, "a": {"user/-/state/com.google/read", "user/-/state/com.google/starred", "user/-/label/Later"}}
+			if body, _ := greaderRequest(t, api.URL, token, http.MethodPost, "/reader/api/0/edit-tag", edit, nil); body != "OK" {
+				t.Fatalf("got %q editing tags, want OK", body)
+			}
+			var contents greaderStream
+			greaderRequest(t, api.URL, token, http.MethodPost, "/reader/api/0/stream/items/contents", url.Values{"i": {first.ID}}, &contents)
+			if len(contents.Items) != 1 {
+				t.Fatalf("got %d items by id, want 1", len(contents.Items))
+			}
+			for _, category := range []string{greaderRead, greaderStarred, "user/-/label/later"} {
+				if !slices.Contains(contents.Items[0].Categories, category) {
+					t.Errorf("got categories %v, want %s", contents.Items[0].Categories, category)
+				}
+			}
+
+			// Read tokens can post for items, but not to change them
+			if _, status := greaderRequest(t, api.URL, readToken, http.MethodPost, "/reader/api/0/stream/items/contents", url.Values{"i": {first.ID}}, nil); status != http.StatusOK {
+				t.Errorf("got status %d fetching items with a read token, want 200", status)
+			}
+			if _, status := greaderRequest(t, api.URL, readToken, http.MethodPost, "/reader/api/0/edit-tag", edit, nil); status != http.StatusForbidden {
+				t.Errorf("got status %d editing tags with a read token, want 403", status)
+			}
+
+			var ids struct {
+				ItemRefs []greaderItemRef `json:"itemRefs"`
+			}
+			greaderRequest(t, api.URL, token, http.MethodGet, "/reader/api/0/stream/items/ids", url.Values{"s": {greaderStarred}}, &ids)
+			if len(ids.ItemRefs) != 1 {
+				t.Errorf("got %d starred ids, want 1", len(ids.ItemRefs))
+			}
+
+			greaderRequest(t, api.URL, token, http.MethodPost, "/reader/api/0/mark-all-as-read", url.Values{"s": {greaderReadingList}}, nil)
+			var counts struct {
+				UnreadCounts []greaderUnreadCount `json:"unreadcounts"`
+			}
+			greaderRequest(t, api.URL, token, http.MethodGet, "/reader/api/0/unread-count", nil, &counts)
+			for _, count := range counts.UnreadCounts {
+				if count.Count != 0 {
+					t.Errorf("got %d unread in %s after marking all read", count.Count, count.ID)
+				}
+			}
+
+			unsubscribe := url.Values{"ac": {"unsubscribe"}, "s": {blogs.ID}}
+			greaderRequest(t, api.URL, token, http.MethodPost, "/reader/api/0/subscription/edit", unsubscribe, nil)
+			greaderRequest(t, api.URL, token, http.MethodGet, "/reader/api/0/subscription/list", nil, &subscriptions)
+			if len(subscriptions.Subscriptions) != 1 {
+				t.Errorf("got %d subscriptions after unsubscribing, want 1", len(subscriptions.Subscriptions))
+			}
+
+			if _, status := greaderRequest(t, api.URL, "", http.MethodGet, "/reader/api/0/tag/list", nil, nil); status != http.StatusUnauthorized {
+				t.Errorf("got status %d without a token, want 401", status)
+			}
+		})
+	}
+}
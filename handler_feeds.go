@@ -8,29 +8,7 @@ import (
 	"time"
 )
 
-func handlerAgg(s *state, cmd command) error {
-	if len(cmd.args) != 1 {
-		return fmt.Errorf("agg requires one argument, the time between requests - a duration string like 1s, 1m , 1h5m3s etc")
-	}
-
-	timeBetweenReqs, err := time.ParseDuration(cmd.args[0])
-	if err != nil {
-		return fmt.Errorf("Invalid time-between-requests argument (duration) '%s' - %s\n", cmd.args[0], err)
-	}
-
-	fmt.Printf("Collecting feeds every %s\n", timeBetweenReqs)
-
-	ticker := time.NewTicker(timeBetweenReqs)
-	for ; ; <-ticker.C {
-		scapeFeeds(s)
-	}
-}
-
 func handlerAddFeed(s *state, cmd command, user database.User) error {
-	if len(cmd.args) != 2 {
-		return fmt.Errorf("addfeed requires two arguments, the feed name and URL")
-	}
-
 	feedname := cmd.args[0]
 	feedURL := cmd.args[1]
 
@@ -75,14 +53,56 @@ func handlerFeeds(s *state, _ command) error {
 		return fmt.Errorf("Problem fetching all feeds: %v", err)
 	}
 
+	records := make([]feedRecord, 0, len(feedList))
 	for _, feedData := range feedList {
 		user, err := s.db.GetUserByID(context.Background(), feedData.UserID)
 		if err != nil {
 			return fmt.Errorf("Problem getting user name for userID %s associated with feed %s (%s)", feedData.UserID, feedData.Name, feedData.Url)
 		}
 
-		fmt.Printf("%s %s %s\n", feedData.Name, feedData.Url, user.Name)
+		records = append(records, feedRecord{
+			Name:    feedData.Name,
+			Url:     feedData.Url,
+			User:    user.Name,
+			AddedAt: feedData.CreatedAt,
+		})
 	}
 
-	return nil
+	return printRecords(s, records)
+}
+
+type feedRecord struct {
+	Name    string    `json:"name"`
+	Url     string    `json:"url"`
+	User    string    `json:"user"`
+	AddedAt time.Time `json:"added_at"`
+}
+
+func handlerWarnings(s *state, cmd command, user database.User) error {
+	warnings, err := s.db.GetFeedWarningsForUser(context.Background(), user.ID)
+	if err != nil {
+		return fmt.Errorf("Problem fetching feed warnings for user '%s': %v", user.Name, err)
+	}
+
+	records := make([]warningRecord, 0, len(warnings))
+	for _, warning := range warnings {
+		if len(cmd.args) == 1 && warning.FeedUrl != cmd.args[0] {
+			continue
+		}
+		records = append(records, warningRecord{
+			Feed:      warning.FeedName,
+			Url:       warning.FeedUrl,
+			FetchedAt: warning.CreatedAt,
+			Message:   warning.Message,
+		})
+	}
+
+	return printRecords(s, records)
+}
+
+type warningRecord struct {
+	Feed      string    `json:"feed"`
+	Url       string    `json:"url"`
+	FetchedAt time.Time `json:"fetched_at"`
+	Message   string    `json:"message"`
 }
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 	"github.com/google/uuid"
 	"github.com/venzy/gator/internal/database"
@@ -9,10 +10,6 @@ import (
 )
 
 func handlerFollow(s *state, cmd command, user database.User) error {
-	if len(cmd.args) != 1 {
-		return fmt.Errorf("follow requires one argument, the feed URL")
-	}
-
 	feedURL := cmd.args[0]
 
 	// Get feed ID from URL
@@ -48,18 +45,27 @@ func handlerFollowing(s *state, _ command, user database.User) error {
 		return fmt.Errorf("Problem fetching follows for user '%s': %v", s.cfg.CurrentUserName, err)
 	}
 
+	records := make([]followRecord, 0, len(follows))
 	for _, follow := range follows {
-		fmt.Printf("%s\n", follow.FeedName)
+		records = append(records, followRecord{
+			Feed:       follow.FeedName,
+			Url:        follow.FeedUrl,
+			Folder:     follow.Folder.String,
+			FollowedAt: follow.CreatedAt,
+		})
 	}
 
-	return nil
+	return printRecords(s, records)
 }
 
-func handlerUnfollow(s *state, cmd command, user database.User) error {
-	if len(cmd.args) != 1 {
-		return fmt.Errorf("unfollow requires one argument, the feed URL")
-	}
+type followRecord struct {
+	Feed       string    `json:"feed"`
+	Url        string    `json:"url"`
+	Folder     string    `json:"folder"`
+	FollowedAt time.Time `json:"followed_at"`
+}
 
+func handlerUnfollow(s *state, cmd command, user database.User) error {
 	feedURL := cmd.args[0]
 
 	// Get feed ID from URL
@@ -84,3 +90,40 @@ func handlerUnfollow(s *state, cmd command, user database.User) error {
 	return nil
 }
 
+func handlerFolder(s *state, cmd command, user database.User) error {
+	feedURL := cmd.args[0]
+
+	// Get feed ID from URL
+	feed, err := s.db.GetFeedByURL(context.Background(), feedURL)
+	if err != nil {
+		return fmt.Errorf("Feed URL '%s' not in database!", feedURL)
+	}
+
+	var folder sql.NullString
+	if len(cmd.args) == 2 && cmd.args[1] != "" {
+		folder = sql.NullString{String: cmd.args[1], Valid: true}
+	}
+
+	updated, err := s.db.SetFeedFollowFolder(
+		context.Background(),
+		database.SetFeedFollowFolderParams{
+			UserID:    user.ID,
+			FeedID:    feed.ID,
+			Folder:    folder,
+			UpdatedAt: time.Now(),
+		})
+	if err != nil {
+		return fmt.Errorf("Problem setting folder for '%s' for user '%s': %v", feedURL, user.Name, err)
+	}
+	if updated == 0 {
+		return fmt.Errorf("User '%s' is not following '%s'", user.Name, feedURL)
+	}
+
+	if folder.Valid {
+		fmt.Printf("Moved '%s' to folder '%s'\n", feed.Name, folder.String)
+	} else {
+		fmt.Printf("Removed '%s' from its folder\n", feed.Name)
+	}
+
+	return nil
+}
@@ -2,46 +2,336 @@ package main
 
 import (
 	"context"
+	"database/sql"
+	"encoding/base64"
 	"fmt"
 	"math"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/venzy/gator/internal/database"
 )
 
 func handlerBrowse(s *state, cmd command, user database.User) error {
-	if len(cmd.args) > 1 {
-		return fmt.Errorf("browse requires at most one argument, the max number of posts to see")
+	filters := browseFilters{
+		Feed:    cmd.stringFlag("feed"),
+		Folder:  cmd.stringFlag("folder"),
+		Since:   cmd.stringFlag("since"),
+		Until:   cmd.stringFlag("until"),
+		Unread:  cmd.boolFlag("unread"),
+		Starred: cmd.boolFlag("starred"),
+		Tag:     cmd.stringFlag("tag"),
+		Search:  cmd.stringFlag("search"),
+		Sort:    cmd.stringFlag("sort"),
+		After:   cmd.stringFlag("after"),
+		// Default
+		Limit: 2,
 	}
-	var limit int32
-	if len(cmd.args) == 1 {
-		parsedLimit, err := strconv.ParseInt(cmd.args[0], 0, 32)
+	args := cmd.args
+
+	if len(args) == 1 {
+		parsedLimit, err := strconv.ParseInt(args[0], 0, 32)
 		if err != nil {
-			return fmt.Errorf("Problem parsing max posts argument '%s': %v", cmd.args[0], err)
+			return fmt.Errorf("Problem parsing max posts argument '%s': %v", args[0], err)
 		}
 		if parsedLimit < 1 || parsedLimit > math.MaxInt32 {
-			return fmt.Errorf("Out of range max posts argument '%s': must be from 1 to %v", cmd.args[0], math.MaxInt32)
+			return fmt.Errorf("Out of range max posts argument '%s': must be from 1 to %v", args[0], math.MaxInt32)
 		}
-		limit = int32(parsedLimit)
-	} else {
-		// Default
-		limit = 2
+		filters.Limit = int32(parsedLimit)
+	}
+
+	params, err := filters.params(user.ID, time.Now())
+	if err != nil {
+		return err
 	}
-	
+
 	// Get posts
-	posts, err := s.db.GetPostsForUser(
-		context.Background(),
-		database.GetPostsForUserParams{
-			UserID: user.ID,
-			Limit: limit,
-		})
+	posts, err := s.db.BrowsePostsForUser(context.Background(), params)
 	if err != nil {
 		return fmt.Errorf("Problem fetching posts for user '%s': %v", s.cfg.CurrentUserName, err)
 	}
 
+	records := make([]postRecord, 0, len(posts))
 	for _, post := range posts {
-		fmt.Printf("%s | %s | %s\n", post.PublishedAt.Local().Format("2006-01-02 15:04:05 MST"), post.FeedName, post.Title)
+		marker := ""
+		if post.IsStarred {
+			marker = "*"
+		} else if !post.IsRead {
+			marker = "+"
+		}
+		records = append(records, postRecord{
+			Marker:    marker,
+			Published: post.PublishedAt,
+			Feed:      post.FeedName,
+			Title:     post.Title,
+			Url:       post.Url,
+			Read:      post.IsRead,
+			Starred:   post.IsStarred,
+			Fetched:   post.CreatedAt,
+			Text:      post.DescriptionText.String,
+			Cursor:    encodeBrowseCursor(post.SortTime, post.ID, filters.Sort),
+		})
+	}
+
+	if err := printRecords(s, records); err != nil {
+		return err
+	}
+
+	// A full page means there may be more to see. Other formats carry a
+	// cursor on every post for scripts to pick up instead.
+	if s.output.kind == outputTable && len(records) == int(filters.Limit) {
+		fmt.Printf("More posts: browse --after %s\n", records[len(records)-1].Cursor)
 	}
 
 	return nil
-}
\ No newline at end of file
+}
+
+// How to narrow down and page through posts, for the browse command and the
+// API alike
+type browseFilters struct {
+	Feed    string
+	Folder  string
+	Since   string
+	Until   string
+	Unread  bool
+	Starred bool
+	Tag     string
+	// Words to look for in titles and text
+	Search string
+	// "published" or "fetched"
+	Sort  string
+	After string
+	Limit int32
+}
+
+func (f browseFilters) params(userID uuid.UUID, now time.Time) (database.BrowsePostsForUserParams, error) {
+	params := database.BrowsePostsForUserParams{
+		UserID:      userID,
+		UnreadOnly:  f.Unread,
+		StarredOnly: f.Starred,
+		RowLimit:    f.Limit,
+	}
+
+	switch f.Sort {
+	case "published":
+	case "fetched":
+		params.SortByFetched = true
+	default:
+		return params, fmt.Errorf("Invalid sort order '%s': must be 'published' or 'fetched'", f.Sort)
+	}
+
+	if f.Feed != "" {
+		params.FeedUrl = sql.NullString{String: f.Feed, Valid: true}
+	}
+	if f.Folder != "" {
+		params.Folder = sql.NullString{String: f.Folder, Valid: true}
+	}
+	if f.Tag != "" {
+		tag, err := normaliseTag(f.Tag)
+		if err != nil {
+			return params, err
+		}
+		params.Tag = sql.NullString{String: tag, Valid: true}
+	}
+	if search := strings.TrimSpace(f.Search); search != "" {
+		params.Search = sql.NullString{String: "%" + escapeLike(search) + "%", Valid: true}
+	}
+
+	if f.Since != "" {
+		since, err := parseTimeFilter(f.Since, now)
+		if err != nil {
+			return params, fmt.Errorf("Problem parsing since '%s': %v", f.Since, err)
+		}
+		params.Since = sql.NullTime{Time: since, Valid: true}
+	}
+	if f.Until != "" {
+		until, err := parseTimeFilter(f.Until, now)
+		if err != nil {
+			return params, fmt.Errorf("Problem parsing until '%s': %v", f.Until, err)
+		}
+		params.Until = sql.NullTime{Time: until, Valid: true}
+	}
+
+	if f.After != "" {
+		afterTime, afterID, err := decodeBrowseCursor(f.After, f.Sort)
+		if err != nil {
+			return params, err
+		}
+		params.AfterTime = sql.NullTime{Time: afterTime, Valid: true}
+		params.AfterID = uuid.NullUUID{UUID: afterID, Valid: true}
+	}
+
+	return params, nil
+}
+
+// Searches are for text, so LIKE's wildcards in them are taken literally
+func escapeLike(text string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(text)
+}
+
+type postRecord struct {
+	// + for unread or * for starred, in place of the two in tables
+	Marker    string    `json:"-" table:" "`
+	Published time.Time `json:"published"`
+	Feed      string    `json:"feed"`
+	Title     string    `json:"title"`
+	Url       string    `json:"url" table:"-"`
+	Read      bool      `json:"read" table:"-"`
+	Starred   bool      `json:"starred" table:"-"`
+	Fetched   time.Time `json:"fetched" table:"-"`
+	// Plain text of the post, too long for a table
+	Text   string `json:"text" table:"-"`
+	Cursor string `json:"cursor" table:"-"`
+}
+
+func handlerRead(s *state, cmd command, user database.User) error {
+	return forEachPostURL(s, cmd, func(post database.Post) error {
+		err := s.db.MarkPostRead(
+			context.Background(),
+			database.MarkPostReadParams{
+				ID:        uuid.New(),
+				CreatedAt: time.Now(),
+				UserID:    user.ID,
+				PostID:    post.ID,
+			})
+		if err != nil {
+			return fmt.Errorf("Problem marking post '%s' read: %v", post.Title, err)
+		}
+		fmt.Printf("Marked '%s' read\n", post.Title)
+		return nil
+	})
+}
+
+func handlerUnread(s *state, cmd command, user database.User) error {
+	return forEachPostURL(s, cmd, func(post database.Post) error {
+		_, err := s.db.MarkPostUnread(
+			context.Background(),
+			database.MarkPostUnreadParams{
+				UserID: user.ID,
+				PostID: post.ID,
+			})
+		if err != nil {
+			return fmt.Errorf("Problem marking post '%s' unread: %v", post.Title, err)
+		}
+		fmt.Printf("Marked '%s' unread\n", post.Title)
+		return nil
+	})
+}
+
+func handlerStar(s *state, cmd command, user database.User) error {
+	return forEachPostURL(s, cmd, func(post database.Post) error {
+		err := s.db.StarPost(
+			context.Background(),
+			database.StarPostParams{
+				ID:        uuid.New(),
+				CreatedAt: time.Now(),
+				UserID:    user.ID,
+				PostID:    post.ID,
+			})
+		if err != nil {
+			return fmt.Errorf("Problem starring post '%s': %v", post.Title, err)
+		}
+		fmt.Printf("Starred '%s'\n", post.Title)
+		return nil
+	})
+}
+
+func handlerUnstar(s *state, cmd command, user database.User) error {
+	return forEachPostURL(s, cmd, func(post database.Post) error {
+		_, err := s.db.UnstarPost(
+			context.Background(),
+			database.UnstarPostParams{
+				UserID: user.ID,
+				PostID: post.ID,
+			})
+		if err != nil {
+			return fmt.Errorf("Problem unstarring post '%s': %v", post.Title, err)
+		}
+		fmt.Printf("Unstarred '%s'\n", post.Title)
+		return nil
+	})
+}
+
+// Shared argument handling for commands taking one or more post URLs
+func forEachPostURL(s *state, cmd command, f func(post database.Post) error) error {
+	for _, postURL := range cmd.args {
+		post, err := s.db.GetPostByURL(context.Background(), postURL)
+		if err != nil {
+			return fmt.Errorf("Post URL '%s' not in database!", postURL)
+		}
+		if err := f(post); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Accepts a date, a date and time, or a duration meaning that long ago
+// (e.g. 36h, or 7d since days are handy here but not understood by
+// time.ParseDuration)
+func parseTimeFilter(input string, now time.Time) (time.Time, error) {
+	formats := []string{
+		time.RFC3339,
+		"2006-01-02 15:04:05",
+		"2006-01-02 15:04",
+		"2006-01-02",
+	}
+	for _, format := range formats {
+		parsed, err := time.ParseInLocation(format, input, time.Local)
+		if err == nil {
+			return parsed, nil
+		}
+	}
+
+	if days, ok := strings.CutSuffix(input, "d"); ok {
+		numDays, err := strconv.Atoi(days)
+		if err == nil && numDays >= 0 {
+			return now.AddDate(0, 0, -numDays), nil
+		}
+	}
+
+	ago, err := time.ParseDuration(input)
+	if err != nil || ago < 0 {
+		return time.Time{}, fmt.Errorf("expected a date (2006-01-02), date and time (2006-01-02 15:04) or duration (36h, 7d)")
+	}
+
+	return now.Add(-ago), nil
+}
+
+// Cursors record the sort order they were made for, as the time they hold
+// means something different under each
+func encodeBrowseCursor(sortTime time.Time, id uuid.UUID, sortBy string) string {
+	raw := fmt.Sprintf("%s|%s|%s", sortBy, sortTime.Format(time.RFC3339Nano), id)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeBrowseCursor(cursor string, sortBy string) (time.Time, uuid.UUID, error) {
+	badCursor := fmt.Errorf("Invalid browse cursor '%s'", cursor)
+
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, uuid.Nil, badCursor
+	}
+
+	parts := strings.Split(string(raw), "|")
+	if len(parts) != 3 {
+		return time.Time{}, uuid.Nil, badCursor
+	}
+	if parts[0] != sortBy {
+		return time.Time{}, uuid.Nil, fmt.Errorf("Browse cursor '%s' is for sort order '%s', not '%s'", cursor, parts[0], sortBy)
+	}
+
+	sortTime, err := time.Parse(time.RFC3339Nano, parts[1])
+	if err != nil {
+		return time.Time{}, uuid.Nil, badCursor
+	}
+	id, err := uuid.Parse(parts[2])
+	if err != nil {
+		return time.Time{}, uuid.Nil, badCursor
+	}
+
+	return sortTime, id, nil
+}
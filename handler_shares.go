@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/venzy/gator/internal/database"
+)
+
+func handlerShare(s *state, cmd command, user database.User) error {
+	action := cmd.args[0]
+	var name string
+	if len(cmd.args) > 1 {
+		name = cmd.args[1]
+	}
+	if name == "" && action != "list" {
+		return fmt.Errorf("'share %s' needs the name of a share", action)
+	}
+
+	switch action {
+	case "create":
+		params := database.CreateShareParams{
+			ID:        uuid.New(),
+			CreatedAt: time.Now(),
+			UserID:    user.ID,
+			Name:      name,
+			Starred:   cmd.boolFlag("starred"),
+		}
+		if folder := cmd.stringFlag("folder"); folder != "" {
+			params.Folder = sql.NullString{String: folder, Valid: true}
+		}
+		if tag := cmd.stringFlag("tag"); tag != "" {
+			normalised, err := normaliseTag(tag)
+			if err != nil {
+				return err
+			}
+			params.Tag = sql.NullString{String: normalised, Valid: true}
+		}
+
+		token, err := newToken()
+		if err != nil {
+			return err
+		}
+		params.TokenHash = hashToken(token)
+		if _, err := s.db.CreateShare(context.Background(), params); err != nil {
+			return fmt.Errorf("Problem creating share '%s' for user '%s': %v", name, user.Name, err)
+		}
+
+		fmt.Printf("Created share '%s' for %s. Its URLs can't be shown again, so keep them somewhere safe:\n", name, user.Name)
+		fmt.Printf("RSS:  /shared/%s/rss\n", token)
+		fmt.Printf("Atom: /shared/%s/atom\n", token)
+		fmt.Println("They're served by 'serve', after its address, e.g. http://localhost:8080/shared/...")
+
+	case "list":
+		shares, err := s.db.GetSharesForUser(context.Background(), user.ID)
+		if err != nil {
+			return fmt.Errorf("Problem fetching shares for user '%s': %v", user.Name, err)
+		}
+
+		records := make([]shareRecord, 0, len(shares))
+		for _, share := range shares {
+			records = append(records, shareRecord{
+				Name:      share.Name,
+				Folder:    share.Folder.String,
+				Tag:       share.Tag.String,
+				Starred:   share.Starred,
+				CreatedAt: share.CreatedAt,
+			})
+		}
+		return printRecords(s, records)
+
+	case "revoke":
+		deleted, err := s.db.DeleteShare(
+			context.Background(),
+			database.DeleteShareParams{
+				UserID: user.ID,
+				Name:   name,
+			})
+		if err != nil {
+			return fmt.Errorf("Problem revoking share '%s' for user '%s': %v", name, user.Name, err)
+		}
+		if deleted == 0 {
+			return fmt.Errorf("User '%s' has no share called '%s'", user.Name, name)
+		}
+		fmt.Printf("Revoked share '%s'\n", name)
+
+	default:
+		return fmt.Errorf("Unknown share action '%s': must be create, list or revoke", action)
+	}
+
+	return nil
+}
+
+type shareRecord struct {
+	Name      string    `json:"name"`
+	Folder    string    `json:"folder"`
+	Tag       string    `json:"tag"`
+	Starred   bool      `json:"starred"`
+	CreatedAt time.Time `json:"created_at"`
+}
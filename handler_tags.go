@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/venzy/gator/internal/database"
+)
+
+func handlerTag(s *state, cmd command, user database.User) error {
+	post, err := followedPostByURL(s, user, cmd.args[0])
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, arg := range cmd.args[1:] {
+		tag, err := normaliseTag(arg)
+		if err != nil {
+			return err
+		}
+
+		err = s.db.CreatePostTag(
+			context.Background(),
+			database.CreatePostTagParams{
+				ID:        uuid.New(),
+				CreatedAt: now,
+				UserID:    user.ID,
+				PostID:    post.ID,
+				Tag:       tag,
+			})
+		if err != nil {
+			return fmt.Errorf("Problem tagging post '%s' with '%s': %v", post.Title, tag, err)
+		}
+
+		fmt.Printf("Tagged '%s' with '%s'\n", post.Title, tag)
+	}
+
+	return nil
+}
+
+func handlerUntag(s *state, cmd command, user database.User) error {
+	post, err := followedPostByURL(s, user, cmd.args[0])
+	if err != nil {
+		return err
+	}
+
+	for _, arg := range cmd.args[1:] {
+		tag, err := normaliseTag(arg)
+		if err != nil {
+			return err
+		}
+
+		removed, err := s.db.DeletePostTag(
+			context.Background(),
+			database.DeletePostTagParams{
+				UserID: user.ID,
+				PostID: post.ID,
+				Tag:    tag,
+			})
+		if err != nil {
+			return fmt.Errorf("Problem removing tag '%s' from post '%s': %v", tag, post.Title, err)
+		}
+
+		if removed == 0 {
+			fmt.Printf("Post '%s' was not tagged with '%s'\n", post.Title, tag)
+		} else {
+			fmt.Printf("Removed tag '%s' from '%s'\n", tag, post.Title)
+		}
+	}
+
+	return nil
+}
+
+func handlerTags(s *state, cmd command, user database.User) error {
+	// Tags on a single post
+	if len(cmd.args) == 1 {
+		post, err := followedPostByURL(s, user, cmd.args[0])
+		if err != nil {
+			return err
+		}
+
+		tags, err := s.db.GetTagsForPost(
+			context.Background(),
+			database.GetTagsForPostParams{
+				UserID: user.ID,
+				PostID: post.ID,
+			})
+		if err != nil {
+			return fmt.Errorf("Problem fetching tags for post '%s': %v", post.Title, err)
+		}
+
+		records := make([]tagRecord, 0, len(tags))
+		for _, tag := range tags {
+			records = append(records, tagRecord{Tag: tag})
+		}
+
+		return printRecords(s, records)
+	}
+
+	// All of the user's tags, with the number of posts carrying each
+	counts, err := s.db.GetTagCountsForUser(context.Background(), user.ID)
+	if err != nil {
+		return fmt.Errorf("Problem fetching tags for user '%s': %v", user.Name, err)
+	}
+
+	records := make([]tagCountRecord, 0, len(counts))
+	for _, count := range counts {
+		records = append(records, tagCountRecord{Tag: count.Tag, Posts: count.PostCount})
+	}
+
+	return printRecords(s, records)
+}
+
+type tagRecord struct {
+	Tag string `json:"tag"`
+}
+
+type tagCountRecord struct {
+	Tag   string `json:"tag"`
+	Posts int64  `json:"posts"`
+}
+
+// Tags are case-insensitive and can't contain whitespace, so "To-Review" and
+// "to-review" are the same tag
+func normaliseTag(input string) (string, error) {
+	tag := strings.ToLower(strings.TrimSpace(input))
+	if tag == "" || strings.ContainsAny(tag, " \t\r\n") {
+		return "", fmt.Errorf("Invalid tag '%s': tags must be non-empty and contain no whitespace", input)
+	}
+
+	return tag, nil
+}
+
+// A post by its URL, as long as it's from a feed the user follows, so tags
+// only go on posts the user can see
+func followedPostByURL(s *state, user database.User, postURL string) (database.Post, error) {
+	post, err := s.db.GetPostByURL(context.Background(), postURL)
+	if err == nil {
+		_, err = s.db.GetPostForUser(
+			context.Background(),
+			database.GetPostForUserParams{
+				UserID: user.ID,
+				ID:     post.ID,
+			})
+	}
+	if err != nil {
+		return database.Post{}, fmt.Errorf("Post URL '%s' not in feeds followed by '%s'", postURL, user.Name)
+	}
+	return post, nil
+}
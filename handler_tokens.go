@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/venzy/gator/internal/database"
+)
+
+// API token scopes. Read tokens can only look, with GET requests.
+const (
+	scopeRead  = "read"
+	scopeWrite = "write"
+)
+
+// Tokens are prefixed so they're recognisable in config files and logs
+const tokenPrefix = "gator_"
+
+func handlerToken(s *state, cmd command, user database.User) error {
+	action := cmd.args[0]
+	var name string
+	if len(cmd.args) > 1 {
+		name = cmd.args[1]
+	}
+	if name == "" && action != "list" {
+		return fmt.Errorf("'token %s' needs the name of a token", action)
+	}
+
+	switch action {
+	case "create":
+		scope := cmd.stringFlag("scope")
+		if scope != scopeRead && scope != scopeWrite {
+			return fmt.Errorf("Invalid token scope '%s': must be '%s' or '%s'", scope, scopeRead, scopeWrite)
+		}
+
+		token, err := newToken()
+		if err != nil {
+			return err
+		}
+		_, err = s.db.CreateAPIToken(
+			context.Background(),
+			database.CreateAPITokenParams{
+				ID:        uuid.New(),
+				CreatedAt: time.Now(),
+				UserID:    user.ID,
+				Name:      name,
+				TokenHash: hashToken(token),
+				Scope:     scope,
+				FeverKey:  sql.NullString{String: hashToken(feverKey(user.Name, token)), Valid: true},
+			})
+		if err != nil {
+			return fmt.Errorf("Problem creating token '%s' for user '%s': %v", name, user.Name, err)
+		}
+
+		fmt.Printf("Created %s token '%s' for %s. It can't be shown again, so keep it somewhere safe:\n", scope, name, user.Name)
+		fmt.Println(token)
+
+	case "list":
+		tokens, err := s.db.GetAPITokensForUser(context.Background(), user.ID)
+		if err != nil {
+			return fmt.Errorf("Problem fetching tokens for user '%s': %v", user.Name, err)
+		}
+
+		records := make([]tokenRecord, 0, len(tokens))
+		for _, token := range tokens {
+			record := tokenRecord{
+				Name:      token.Name,
+				Scope:     token.Scope,
+				CreatedAt: token.CreatedAt,
+				LastUsed:  "never",
+			}
+			if token.LastUsedAt.Valid {
+				record.LastUsed = token.LastUsedAt.Time.Local().Format(time.RFC3339)
+			}
+			records = append(records, record)
+		}
+		return printRecords(s, records)
+
+	case "revoke":
+		deleted, err := s.db.DeleteAPIToken(
+			context.Background(),
+			database.DeleteAPITokenParams{
+				UserID: user.ID,
+				Name:   name,
+			})
+		if err != nil {
+			return fmt.Errorf("Problem revoking token '%s' for user '%s': %v", name, user.Name, err)
+		}
+		if deleted == 0 {
+			return fmt.Errorf("User '%s' has no token called '%s'", user.Name, name)
+		}
+		fmt.Printf("Revoked token '%s'\n", name)
+
+	default:
+		return fmt.Errorf("Unknown token action '%s': must be create, list or revoke", action)
+	}
+
+	return nil
+}
+
+type tokenRecord struct {
+	Name      string    `json:"name"`
+	Scope     string    `json:"scope"`
+	CreatedAt time.Time `json:"created_at"`
+	LastUsed  string    `json:"last_used"`
+}
+
+// A random token, for the API and for login sessions
+func newToken() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", fmt.Errorf("Problem generating token: %v", err)
+	}
+	return tokenPrefix + base64.RawURLEncoding.EncodeToString(secret), nil
+}
+
+// Only the hash is stored, so a leaked database doesn't leak usable tokens.
+// Tokens are random enough that a plain SHA-256 will do.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -1,25 +1,39 @@
 package main
 
 import (
+	"bufio"
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"github.com/google/uuid"
 	"github.com/venzy/gator/internal/database"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/term"
+	"io"
+	"os"
+	"strings"
 	"time"
 )
 
 func handlerLogin(s *state, cmd command) error {
-	if len(cmd.args) != 1 {
-		return fmt.Errorf("login requires a single argument, the username")
-	}
-
 	username := cmd.args[0]
-	_, err := s.db.GetUserByName(context.Background(), username)
+	user, err := s.db.GetUserByName(context.Background(), username)
 	if err != nil {
 		return fmt.Errorf("Could not login user '%s'", username)
 	}
 
-	if err := s.cfg.SetUser(username); err != nil {
+	if user.PasswordHash.Valid {
+		password, err := readPassword(fmt.Sprintf("Password for %s: ", username))
+		if err != nil {
+			return err
+		}
+		if !checkPassword(user, password) {
+			return fmt.Errorf("Wrong password for user '%s'", username)
+		}
+	}
+
+	if err := startSession(s, user); err != nil {
 		return err
 	}
 
@@ -29,35 +43,42 @@ func handlerLogin(s *state, cmd command) error {
 }
 
 func handlerRegister(s *state, cmd command) error {
-	if len(cmd.args) != 1 {
-		return fmt.Errorf("register requires a single argument, the username")
-	}
-
 	username := cmd.args[0]
 	user, err := s.db.GetUserByName(context.Background(), username)
 	if err == nil && user.Name == username {
 		return fmt.Errorf("User '%s' already exists!", username)
 	}
 
+	// Asked for first, so a mistyped password doesn't leave a user without one
+	var passwordHash sql.NullString
+	if cmd.boolFlag("password") {
+		hash, err := readNewPassword(username)
+		if err != nil {
+			return err
+		}
+		passwordHash = sql.NullString{String: hash, Valid: true}
+	}
+
 	now := time.Now()
 	user, err = s.db.CreateUser(
 		context.Background(),
 		database.CreateUserParams{
-			ID:        uuid.New(),
-			CreatedAt: now,
-			UpdatedAt: now,
-			Name:      username,
+			ID:           uuid.New(),
+			CreatedAt:    now,
+			UpdatedAt:    now,
+			Name:         username,
+			PasswordHash: passwordHash,
 		})
 	if err != nil {
 		return err
 	}
 
-	err = s.cfg.SetUser(user.Name)
+	err = startSession(s, user)
 	if err != nil {
 		return err
 	}
 
-	fmt.Printf("Registered new user: %v\n", user)
+	fmt.Printf("Registered new user: %s (%s)\n", user.Name, user.ID)
 
 	return nil
 }
@@ -77,25 +98,194 @@ func handlerUsers(s *state, _ command) error {
 		return err
 	}
 
+	records := make([]userRecord, 0, len(users))
 	for _, user := range users {
-		fmt.Printf("* %s", user.Name)
-		if user.Name == s.cfg.CurrentUserName {
-			fmt.Printf(" (current)")
-		}
-		fmt.Printf("\n")
+		records = append(records, userRecord{
+			Name:      user.Name,
+			Current:   user.Name == s.cfg.CurrentUserName,
+			CreatedAt: user.CreatedAt,
+		})
 	}
 
-	return nil
+	return printRecords(s, records)
+}
+
+type userRecord struct {
+	Name      string    `json:"name"`
+	Current   bool      `json:"current"`
+	CreatedAt time.Time `json:"created_at"`
 }
 
 // 'middleware' as boot.dev likes to call it
 func withLoggedInUser(handler func(s *state, cmd command, user database.User) error) func(*state, command) error {
 	return func(s *state, cmd command) error {
-		user, err := s.db.GetUserByName(context.Background(), s.cfg.CurrentUserName)
+		user, err := currentUser(s)
 		if err != nil {
-			return fmt.Errorf("User '%s' not in database!", s.cfg.CurrentUserName)
+			return err
 		}
 
 		return handler(s, cmd, user)
 	}
+}
+
+// The logged-in user from the config. Users with passwords also need the
+// session token from logging in, so editing the name in someone else's config
+// (or your own) isn't enough to become them.
+func currentUser(s *state) (database.User, error) {
+	if s.cfg.SessionToken != "" {
+		user, err := s.db.GetUserBySession(context.Background(), hashToken(s.cfg.SessionToken))
+		if err == nil && user.Name == s.cfg.CurrentUserName {
+			return user, nil
+		}
+	}
+
+	user, err := s.db.GetUserByName(context.Background(), s.cfg.CurrentUserName)
+	if err != nil {
+		return database.User{}, fmt.Errorf("User '%s' not in database!", s.cfg.CurrentUserName)
+	}
+	if user.PasswordHash.Valid {
+		return database.User{}, fmt.Errorf("User '%s' has a password - log in with '%s login %s' first", user.Name, os.Args[0], user.Name)
+	}
+	return user, nil
+}
+
+// Starts a new session as the user, kept in the config
+func startSession(s *state, user database.User) error {
+	token, err := newToken()
+	if err != nil {
+		return err
+	}
+	_, err = s.db.CreateUserSession(
+		context.Background(),
+		database.CreateUserSessionParams{
+			ID:        uuid.New(),
+			CreatedAt: time.Now(),
+			UserID:    user.ID,
+			TokenHash: hashToken(token),
+		})
+	if err != nil {
+		return fmt.Errorf("Problem starting session for user '%s': %v", user.Name, err)
+	}
+
+	return s.cfg.SetUser(user.Name, token)
+}
+
+func handlerPasswd(s *state, cmd command, user database.User) error {
+	remove := cmd.boolFlag("remove")
+	if remove && !user.PasswordHash.Valid {
+		return fmt.Errorf("User '%s' has no password to remove", user.Name)
+	}
+
+	if user.PasswordHash.Valid {
+		password, err := readPassword("Current password: ")
+		if err != nil {
+			return err
+		}
+		if !checkPassword(user, password) {
+			return fmt.Errorf("Wrong password for user '%s'", user.Name)
+		}
+	}
+
+	if remove {
+		err := s.db.SetUserPassword(
+			context.Background(),
+			database.SetUserPasswordParams{
+				ID:        user.ID,
+				UpdatedAt: time.Now(),
+			})
+		if err != nil {
+			return fmt.Errorf("Problem removing password for user '%s': %v", user.Name, err)
+		}
+	} else if err := setPassword(s, user); err != nil {
+		return err
+	}
+
+	// Anyone else logged in, perhaps with the old password, has to log in again
+	if err := s.db.DeleteUserSessions(context.Background(), user.ID); err != nil {
+		return fmt.Errorf("Problem ending sessions for user '%s': %v", user.Name, err)
+	}
+	if err := startSession(s, user); err != nil {
+		return err
+	}
+
+	if remove {
+		fmt.Printf("Removed password for %s\n", user.Name)
+	} else {
+		fmt.Printf("Changed password for %s\n", user.Name)
+	}
+	return nil
+}
+
+// Asks for a new password twice, and stores its hash
+func setPassword(s *state, user database.User) error {
+	hash, err := readNewPassword(user.Name)
+	if err != nil {
+		return err
+	}
+	err = s.db.SetUserPassword(
+		context.Background(),
+		database.SetUserPasswordParams{
+			ID:           user.ID,
+			PasswordHash: sql.NullString{String: hash, Valid: true},
+			UpdatedAt:    time.Now(),
+		})
+	if err != nil {
+		return fmt.Errorf("Problem setting password for user '%s': %v", user.Name, err)
+	}
+	return nil
+}
+
+// Prompts for a new password twice, returning its bcrypt hash
+func readNewPassword(username string) (string, error) {
+	password, err := readPassword(fmt.Sprintf("New password for %s: ", username))
+	if err != nil {
+		return "", err
+	}
+	if password == "" {
+		return "", fmt.Errorf("Password can't be empty")
+	}
+	again, err := readPassword("Same again: ")
+	if err != nil {
+		return "", err
+	}
+	if again != password {
+		return "", fmt.Errorf("Passwords don't match")
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("Problem hashing password: %v", err)
+	}
+	return string(hash), nil
+}
+
+func checkPassword(user database.User, password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(user.PasswordHash.String), []byte(password)) == nil
+}
+
+// Passwords are read a line at a time from stdin when it isn't a terminal,
+// for scripts, through one reader so lines buffered for one prompt aren't
+// lost to the next
+var stdinLines = bufio.NewReader(os.Stdin)
+
+// Prompts for a password without echoing it. A variable so tests can answer.
+var readPassword = func(prompt string) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		line, err := stdinLines.ReadString('\n')
+		if errors.Is(err, io.EOF) && line == "" {
+			return "", fmt.Errorf("No password given on stdin")
+		} else if err != nil && !errors.Is(err, io.EOF) {
+			return "", fmt.Errorf("Problem reading password: %v", err)
+		}
+		return strings.TrimRight(line, "\r\n"), nil
+	}
+
+	fmt.Fprint(os.Stderr, prompt)
+	password, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", fmt.Errorf("Problem reading password: %v", err)
+	}
+	return string(password), nil
 }
\ No newline at end of file
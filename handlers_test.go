@@ -0,0 +1,339 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func postTitles(posts []postRecord) []string {
+	var titles []string
+	for _, post := range posts {
+		titles = append(titles, post.Title)
+	}
+	return titles
+}
+
+func TestUsers(t *testing.T) {
+	for name, newState := range testStates {
+		t.Run(name, func(t *testing.T) {
+			s := newState(t)
+			mustRun(t, s, "register", "alice")
+			mustRun(t, s, "register", "bob")
+
+			if _, err := runCommand(t, s, "register", "alice"); err == nil {
+				t.Error("expected an error registering an existing user")
+			}
+			if _, err := runCommand(t, s, "login", "carol"); err == nil {
+				t.Error("expected an error logging in as an unknown user")
+			}
+
+			mustRun(t, s, "login", "alice")
+			users := runListing[userRecord](t, s, "users")
+			if len(users) != 2 || users[0].Name != "alice" || !users[0].Current || users[1].Current {
+				t.Errorf("got users %+v, want alice (current) and bob", users)
+			}
+
+			mustRun(t, s, "reset")
+			if users := runListing[userRecord](t, s, "users"); len(users) != 0 {
+				t.Errorf("got users %+v after reset, want none", users)
+			}
+		})
+	}
+}
+
+// Answers password prompts in turn, failing the test if there are more
+// prompts than answers
+func answerPasswords(t *testing.T, answers ...string) {
+	t.Helper()
+
+	previous := readPassword
+	t.Cleanup(func() { readPassword = previous })
+	readPassword = func(prompt string) (string, error) {
+		if len(answers) == 0 {
+			t.Fatalf("unexpected password prompt %q", prompt)
+		}
+		answer := answers[0]
+		answers = answers[1:]
+		return answer, nil
+	}
+}
+
+func TestPasswords(t *testing.T) {
+	for name, newState := range testStates {
+		t.Run(name, func(t *testing.T) {
+			s := newState(t)
+			answerPasswords(t, "hunter2", "hunter2")
+			mustRun(t, s, "register", "--password", "alice")
+			mustRun(t, s, "register", "bob")
+			if s.cfg.SessionToken == "" {
+				t.Fatal("no session token in the config")
+			}
+
+			// Just naming alice in the config isn't enough
+			s.cfg.CurrentUserName = "alice"
+			if _, err := runCommand(t, s, "following"); err == nil || !strings.Contains(err.Error(), "has a password") {
+				t.Errorf("got error %v impersonating alice, want a password error", err)
+			}
+
+			answerPasswords(t, "wrong")
+			if _, err := runCommand(t, s, "login", "alice"); err == nil {
+				t.Error("expected an error logging in with the wrong password")
+			}
+			answerPasswords(t, "hunter2")
+			mustRun(t, s, "login", "alice")
+			mustRun(t, s, "following")
+
+			// Changing the password logs out alice's other sessions
+			oldSession := s.cfg.SessionToken
+			answerPasswords(t, "hunter2", "correct horse", "correct horse")
+			mustRun(t, s, "passwd")
+			mustRun(t, s, "following")
+			s.cfg.SessionToken = oldSession
+			if _, err := runCommand(t, s, "following"); err == nil {
+				t.Error("expected an error using a session from before the password changed")
+			}
+
+			answerPasswords(t, "correct horse")
+			mustRun(t, s, "login", "alice")
+			answerPasswords(t, "one", "two")
+			if _, err := runCommand(t, s, "passwd"); err == nil {
+				t.Error("expected an error when the new passwords don't match")
+			}
+
+			// Without a password, alice is back to just being named
+			answerPasswords(t, "correct horse")
+			mustRun(t, s, "passwd", "--remove")
+			s.cfg.SessionToken = ""
+			mustRun(t, s, "following")
+
+			// bob never had a password, so never gets asked for one
+			answerPasswords(t)
+			mustRun(t, s, "login", "bob")
+
+			// A mistyped password doesn't leave a user without one
+			answerPasswords(t, "secret", "other")
+			if _, err := runCommand(t, s, "register", "--password", "carol"); err == nil {
+				t.Error("expected an error when the passwords don't match")
+			}
+			if _, err := s.db.GetUserByName(context.Background(), "carol"); err == nil {
+				t.Error("registered a user whose passwords didn't match")
+			}
+		})
+	}
+}
+
+func TestFollows(t *testing.T) {
+	server := newFeedServer(t)
+	feedURL := server.URL + "/feeds/rss.xml"
+
+	for name, newState := range testStates {
+		t.Run(name, func(t *testing.T) {
+			s := newState(t)
+			mustRun(t, s, "register", "alice")
+			mustRun(t, s, "addfeed", "Test", feedURL)
+			captureStdout(t, func() { scapeFeeds(context.Background(), s) })
+
+			if _, err := runCommand(t, s, "addfeed", "Again", feedURL); err == nil {
+				t.Error("expected an error adding a feed twice")
+			}
+
+			// Bob follows alice's feed, and sees its posts
+			mustRun(t, s, "register", "bob")
+			if _, err := runCommand(t, s, "follow", server.URL+"/feeds/unknown.xml"); err == nil {
+				t.Error("expected an error following an unknown feed")
+			}
+			mustRun(t, s, "sub", feedURL)
+			follows := runListing[followRecord](t, s, "following")
+			if len(follows) != 1 || follows[0].Url != feedURL {
+				t.Errorf("got follows %+v, want just %s", follows, feedURL)
+			}
+			if posts := runListing[postRecord](t, s, "browse", "10"); len(posts) != 3 {
+				t.Errorf("got %d posts, want 3", len(posts))
+			}
+
+			mustRun(t, s, "folder", feedURL, "news")
+			if posts := runListing[postRecord](t, s, "browse", "--folder", "news", "10"); len(posts) != 3 {
+				t.Errorf("got %d posts in folder, want 3", len(posts))
+			}
+			if posts := runListing[postRecord](t, s, "browse", "--folder", "other", "10"); len(posts) != 0 {
+				t.Errorf("got %d posts in another folder, want none", len(posts))
+			}
+
+			mustRun(t, s, "unfollow", feedURL)
+			if follows := runListing[followRecord](t, s, "following"); len(follows) != 0 {
+				t.Errorf("got follows %+v after unfollowing, want none", follows)
+			}
+			if posts := runListing[postRecord](t, s, "browse", "10"); len(posts) != 0 {
+				t.Errorf("got %d posts after unfollowing, want none", len(posts))
+			}
+
+			// Alice still follows it
+			mustRun(t, s, "login", "alice")
+			if posts := runListing[postRecord](t, s, "browse", "10"); len(posts) != 3 {
+				t.Errorf("got %d posts for alice, want 3", len(posts))
+			}
+		})
+	}
+}
+
+func TestPostState(t *testing.T) {
+	server := newFeedServer(t)
+
+	for name, newState := range testStates {
+		t.Run(name, func(t *testing.T) {
+			s := newState(t)
+			mustRun(t, s, "register", "alice")
+			mustRun(t, s, "addfeed", "Test", server.URL+"/feeds/rss.xml")
+			captureStdout(t, func() { scapeFeeds(context.Background(), s) })
+
+			first := "https://example.com/posts/first"
+			second := "https://example.com/posts/second"
+
+			mustRun(t, s, "read", first)
+			mustRun(t, s, "star", second)
+			mustRun(t, s, "tag", first, "Work", "later")
+			mustRun(t, s, "tag", second, "work")
+
+			if got := postTitles(runListing[postRecord](t, s, "browse", "--unread", "10")); !slices.Equal(got, []string{"Undated Post", "Second Post"}) {
+				t.Errorf("unread: got %v", got)
+			}
+			if got := postTitles(runListing[postRecord](t, s, "browse", "--starred", "10")); !slices.Equal(got, []string{"Second Post"}) {
+				t.Errorf("starred: got %v", got)
+			}
+			if got := postTitles(runListing[postRecord](t, s, "browse", "--tag", "later", "10")); !slices.Equal(got, []string{"First Post"}) {
+				t.Errorf("tagged: got %v", got)
+			}
+
+			counts := runListing[tagCountRecord](t, s, "tags")
+			if want := []tagCountRecord{{Tag: "later", Posts: 1}, {Tag: "work", Posts: 2}}; !slices.Equal(counts, want) {
+				t.Errorf("got tag counts %+v, want %+v", counts, want)
+			}
+
+			// Another user's view is unaffected
+			mustRun(t, s, "register", "bob")
+			mustRun(t, s, "follow", server.URL+"/feeds/rss.xml")
+			if posts := runListing[postRecord](t, s, "browse", "--unread", "10"); len(posts) != 3 {
+				t.Errorf("got %d unread posts for bob, want 3", len(posts))
+			}
+			mustRun(t, s, "login", "alice")
+
+			mustRun(t, s, "unread", first)
+			mustRun(t, s, "unstar", second)
+			mustRun(t, s, "untag", first, "later")
+			if posts := runListing[postRecord](t, s, "browse", "--unread", "10"); len(posts) != 3 {
+				t.Errorf("got %d unread posts after marking unread, want 3", len(posts))
+			}
+			if posts := runListing[postRecord](t, s, "browse", "--starred", "10"); len(posts) != 0 {
+				t.Errorf("got %d starred posts after unstarring, want none", len(posts))
+			}
+			if tags := runListing[tagRecord](t, s, "tags", first); !slices.Equal(tags, []tagRecord{{Tag: "work"}}) {
+				t.Errorf("got tags %+v, want just work", tags)
+			}
+
+			// Posts from feeds carol doesn't follow are out of reach
+			mustRun(t, s, "register", "carol")
+			if _, err := runCommand(t, s, "tag", first, "mine"); err == nil {
+				t.Error("expected an error tagging a post from a feed carol doesn't follow")
+			}
+			if _, err := runCommand(t, s, "tags", first); err == nil {
+				t.Error("expected an error listing tags on a post from a feed carol doesn't follow")
+			}
+			mustRun(t, s, "login", "alice")
+
+			if _, err := runCommand(t, s, "read", "https://example.com/posts/unknown"); err == nil {
+				t.Error("expected an error marking an unknown post read")
+			}
+		})
+	}
+}
+
+func TestBrowsePagination(t *testing.T) {
+	server := newFeedServer(t)
+
+	for name, newState := range testStates {
+		t.Run(name, func(t *testing.T) {
+			s := newState(t)
+			mustRun(t, s, "register", "alice")
+			mustRun(t, s, "addfeed", "Test", server.URL+"/feeds/rss.xml")
+			captureStdout(t, func() { scapeFeeds(context.Background(), s) })
+
+			// Table output points at the next page
+			output := mustRun(t, s, "browse")
+			_, cursor, found := strings.Cut(strings.TrimSpace(output), "More posts: browse --after ")
+			if !found {
+				t.Fatalf("no next page cursor in output:\n%s", output)
+			}
+
+			firstPage := runListing[postRecord](t, s, "browse")
+			secondPage := runListing[postRecord](t, s, "browse", "--after", cursor)
+			got := append(postTitles(firstPage), postTitles(secondPage)...)
+			if want := []string{"Undated Post", "Second Post", "First Post"}; !slices.Equal(got, want) {
+				t.Errorf("got pages %v, want %v", got, want)
+			}
+
+			if _, err := runCommand(t, s, "browse", "--after", "nonsense"); err == nil {
+				t.Error("expected an error for a bad cursor")
+			}
+			if _, err := runCommand(t, s, "browse", "--sort", "fetched", "--after", cursor); err == nil {
+				t.Error("expected an error using a cursor with a different sort order")
+			}
+		})
+	}
+}
+
+func TestBrowseSearch(t *testing.T) {
+	server := newFeedServer(t)
+
+	for name, newState := range testStates {
+		t.Run(name, func(t *testing.T) {
+			s := newState(t)
+			mustRun(t, s, "register", "alice")
+			mustRun(t, s, "addfeed", "Test", server.URL+"/feeds/rss.xml")
+			captureStdout(t, func() { scapeFeeds(context.Background(), s) })
+
+			for search, want := range map[string][]string{
+				"SECOND":       {"Second Post"},
+				"when fetched": {"Undated Post"},
+				"post":         {"Undated Post", "Second Post", "First Post"},
+				// Wildcards are taken literally
+				"f_rst": nil,
+				"%":     nil,
+			} {
+				got := postTitles(runListing[postRecord](t, s, "browse", "--search", search, "10"))
+				if !slices.Equal(got, want) {
+					t.Errorf("search %q: got %v, want %v", search, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestWarnings(t *testing.T) {
+	server := newFeedServer(t)
+
+	for name, newState := range testStates {
+		t.Run(name, func(t *testing.T) {
+			s := newState(t)
+			mustRun(t, s, "register", "alice")
+			mustRun(t, s, "addfeed", "Sloppy", server.URL+"/feeds/sloppy.xml")
+			mustRun(t, s, "addfeed", "Test", server.URL+"/feeds/rss.xml")
+			runListing[refreshRecord](t, s, "refresh")
+
+			warnings := runListing[warningRecord](t, s, "warnings")
+			if len(warnings) != 2 || warnings[0].Feed != "Sloppy" || warnings[1].Message != "Skipped item 'No link here' with no link" {
+				t.Errorf("got warnings %+v, want 2 for Sloppy", warnings)
+			}
+			if warnings := runListing[warningRecord](t, s, "warnings", server.URL+"/feeds/rss.xml"); len(warnings) != 0 {
+				t.Errorf("got warnings %+v for a clean feed, want none", warnings)
+			}
+
+			// Each fetch replaces the last one's warnings
+			runListing[refreshRecord](t, s, "refresh", server.URL+"/feeds/sloppy.xml")
+			if warnings := runListing[warningRecord](t, s, "warnings"); len(warnings) != 2 {
+				t.Errorf("got %d warnings after refetching, want 2", len(warnings))
+			}
+		})
+	}
+}
@@ -2,7 +2,9 @@ package config
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
+	"strings"
 )
 
 const configFileName = ".gatorconfig.json"
@@ -10,9 +12,33 @@ const configFileName = ".gatorconfig.json"
 type Config struct {
 	DbUrl string `json:"db_url"`
 	CurrentUserName string `json:"current_user_name"`
+	// Proves the login as CurrentUserName, so someone sharing the database
+	// can't just edit the name to become another user
+	SessionToken string `json:"session_token,omitempty"`
+	// Extra command names, each expanding to a command line
+	Aliases map[string]string `json:"aliases,omitempty"`
+	// Migrate an out of date database schema without asking first
+	AutoMigrate bool `json:"auto_migrate,omitempty"`
+	// How feeds are fetched - anything left out gets a sensible default
+	Fetch FetchConfig `json:"fetch,omitzero"`
 }
 
-var badConfig Config = Config{"",""}
+type FetchConfig struct {
+	// Durations like "10s". Connecting includes the TLS handshake, and the
+	// response timeout covers the whole request including reading the body.
+	ConnectTimeout  string `json:"connect_timeout,omitempty"`
+	ResponseTimeout string `json:"response_timeout,omitempty"`
+	// In bytes, after decompression
+	MaxBodySize int64 `json:"max_body_size,omitempty"`
+	// A pointer so an explicit 0, turning redirects off, isn't the default
+	MaxRedirects *int `json:"max_redirects,omitempty"`
+	// Replaces the default "gator/<version> (+<contact url>)"
+	UserAgent string `json:"user_agent,omitempty"`
+	// Where feed owners can find out about gator, for the default User-Agent
+	ContactURL string `json:"contact_url,omitempty"`
+}
+
+var badConfig Config = Config{}
 
 func Read() (Config, error) {
 	// Open default config file for read
@@ -32,14 +58,42 @@ func Read() (Config, error) {
     if err := decoder.Decode(&cfg); err != nil {
         return badConfig, err
     }
+    if err := normaliseAliases(&cfg); err != nil {
+        return badConfig, err
+    }
     return cfg, nil
 }
 
-func (cfg *Config) SetUser(user string) error {
+// Command names are case-insensitive, so aliases are lowercased, and two
+// differing only in case would be ambiguous
+func normaliseAliases(cfg *Config) error {
+	if len(cfg.Aliases) == 0 {
+		return nil
+	}
+
+	aliases := make(map[string]string, len(cfg.Aliases))
+	for name, expansion := range cfg.Aliases {
+		lower := strings.ToLower(name)
+		if _, exists := aliases[lower]; exists {
+			return fmt.Errorf("Aliases in config file differ only in case: '%s'", lower)
+		}
+		aliases[lower] = expansion
+	}
+	cfg.Aliases = aliases
+	return nil
+}
+
+func (cfg *Config) SetUser(user string, sessionToken string) error {
 	cfg.CurrentUserName = user
+	cfg.SessionToken = sessionToken
 	return write(*cfg)
 }
 
+// The config file's location, for things kept alongside it
+func FilePath() (string, error) {
+	return getConfigFilePath()
+}
+
 func getConfigFilePath() (string, error) {
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
@@ -54,11 +108,15 @@ func write(cfg Config) error {
 	if err != nil {
 		return err
 	}
-	file, err := os.OpenFile(configPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	// Only readable by us, as it holds the session token
+	file, err := os.OpenFile(configPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
 	if err != nil {
 		return err
 	}
 	defer file.Close()
+	if err := file.Chmod(0600); err != nil {
+		return err
+	}
 
 	encoder := json.NewEncoder(file)
 	err = encoder.Encode(cfg)
@@ -0,0 +1,171 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.28.0
+// source: api_tokens.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const createAPIToken = `-- name: CreateAPIToken :one
+INSERT INTO api_tokens (id, created_at, user_id, name, token_hash, scope, fever_key)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7
+)
+RETURNING id, created_at, user_id, name, token_hash, scope, last_used_at, fever_key
+`
+
+type CreateAPITokenParams struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UserID    uuid.UUID
+	Name      string
+	TokenHash string
+	Scope     string
+	FeverKey  sql.NullString
+}
+
+func (q *Queries) CreateAPIToken(ctx context.Context, arg CreateAPITokenParams) (ApiToken, error) {
+	row := q.db.QueryRowContext(ctx, createAPIToken,
+		arg.ID,
+		arg.CreatedAt,
+		arg.UserID,
+		arg.Name,
+		arg.TokenHash,
+		arg.Scope,
+		arg.FeverKey,
+	)
+	var i ApiToken
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UserID,
+		&i.Name,
+		&i.TokenHash,
+		&i.Scope,
+		&i.LastUsedAt,
+		&i.FeverKey,
+	)
+	return i, err
+}
+
+const deleteAPIToken = `-- name: DeleteAPIToken :execrows
+DELETE FROM api_tokens WHERE user_id = $1 AND name = $2
+`
+
+type DeleteAPITokenParams struct {
+	UserID uuid.UUID
+	Name   string
+}
+
+func (q *Queries) DeleteAPIToken(ctx context.Context, arg DeleteAPITokenParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteAPIToken, arg.UserID, arg.Name)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const getAPITokenByFeverKey = `-- name: GetAPITokenByFeverKey :one
+SELECT id, created_at, user_id, name, token_hash, scope, last_used_at, fever_key FROM api_tokens WHERE fever_key = $1
+`
+
+func (q *Queries) GetAPITokenByFeverKey(ctx context.Context, feverKey sql.NullString) (ApiToken, error) {
+	row := q.db.QueryRowContext(ctx, getAPITokenByFeverKey, feverKey)
+	var i ApiToken
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UserID,
+		&i.Name,
+		&i.TokenHash,
+		&i.Scope,
+		&i.LastUsedAt,
+		&i.FeverKey,
+	)
+	return i, err
+}
+
+const getAPITokenByHash = `-- name: GetAPITokenByHash :one
+SELECT id, created_at, user_id, name, token_hash, scope, last_used_at, fever_key FROM api_tokens WHERE token_hash = $1
+`
+
+func (q *Queries) GetAPITokenByHash(ctx context.Context, tokenHash string) (ApiToken, error) {
+	row := q.db.QueryRowContext(ctx, getAPITokenByHash, tokenHash)
+	var i ApiToken
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UserID,
+		&i.Name,
+		&i.TokenHash,
+		&i.Scope,
+		&i.LastUsedAt,
+		&i.FeverKey,
+	)
+	return i, err
+}
+
+const getAPITokensForUser = `-- name: GetAPITokensForUser :many
+SELECT id, created_at, user_id, name, token_hash, scope, last_used_at, fever_key FROM api_tokens
+    WHERE user_id = $1
+    ORDER BY name
+`
+
+func (q *Queries) GetAPITokensForUser(ctx context.Context, userID uuid.UUID) ([]ApiToken, error) {
+	rows, err := q.db.QueryContext(ctx, getAPITokensForUser, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ApiToken
+	for rows.Next() {
+		var i ApiToken
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UserID,
+			&i.Name,
+			&i.TokenHash,
+			&i.Scope,
+			&i.LastUsedAt,
+			&i.FeverKey,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const markAPITokenUsed = `-- name: MarkAPITokenUsed :exec
+UPDATE api_tokens SET last_used_at = $2 WHERE id = $1
+`
+
+type MarkAPITokenUsedParams struct {
+	ID         uuid.UUID
+	LastUsedAt sql.NullTime
+}
+
+func (q *Queries) MarkAPITokenUsed(ctx context.Context, arg MarkAPITokenUsedParams) error {
+	_, err := q.db.ExecContext(ctx, markAPITokenUsed, arg.ID, arg.LastUsedAt)
+	return err
+}
@@ -0,0 +1,96 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.28.0
+// source: feed_warnings.sql
+
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const createFeedWarning = `-- name: CreateFeedWarning :exec
+INSERT INTO feed_warnings (id, created_at, feed_id, message)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4
+)
+`
+
+type CreateFeedWarningParams struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	FeedID    uuid.UUID
+	Message   string
+}
+
+func (q *Queries) CreateFeedWarning(ctx context.Context, arg CreateFeedWarningParams) error {
+	_, err := q.db.ExecContext(ctx, createFeedWarning,
+		arg.ID,
+		arg.CreatedAt,
+		arg.FeedID,
+		arg.Message,
+	)
+	return err
+}
+
+const deleteFeedWarnings = `-- name: DeleteFeedWarnings :exec
+DELETE FROM feed_warnings WHERE feed_id = $1
+`
+
+func (q *Queries) DeleteFeedWarnings(ctx context.Context, feedID uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteFeedWarnings, feedID)
+	return err
+}
+
+const getFeedWarningsForUser = `-- name: GetFeedWarningsForUser :many
+SELECT feed_warnings.id, feed_warnings.created_at, feed_warnings.feed_id, feed_warnings.message, feeds.name AS feed_name, feeds.url AS feed_url FROM feed_warnings
+    INNER JOIN feeds ON feeds.id = feed_warnings.feed_id
+    INNER JOIN feed_follows ON feed_follows.feed_id = feed_warnings.feed_id
+    WHERE feed_follows.user_id = $1
+    ORDER BY feeds.name, feed_warnings.created_at
+`
+
+type GetFeedWarningsForUserRow struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	FeedID    uuid.UUID
+	Message   string
+	FeedName  string
+	FeedUrl   string
+}
+
+func (q *Queries) GetFeedWarningsForUser(ctx context.Context, userID uuid.UUID) ([]GetFeedWarningsForUserRow, error) {
+	rows, err := q.db.QueryContext(ctx, getFeedWarningsForUser, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetFeedWarningsForUserRow
+	for rows.Next() {
+		var i GetFeedWarningsForUserRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.FeedID,
+			&i.Message,
+			&i.FeedName,
+			&i.FeedUrl,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
@@ -23,7 +23,7 @@ VALUES (
     $5,
     $6
 )
-RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at
+RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at, hub_url, topic_url
 `
 
 type CreateFeedParams struct {
@@ -53,12 +53,35 @@ func (q *Queries) CreateFeed(ctx context.Context, arg CreateFeedParams) (Feed, e
 		&i.Url,
 		&i.UserID,
 		&i.LastFetchedAt,
+		&i.HubUrl,
+		&i.TopicUrl,
+	)
+	return i, err
+}
+
+const getFeedByID = `-- name: GetFeedByID :one
+SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at, hub_url, topic_url FROM feeds WHERE id = $1
+`
+
+func (q *Queries) GetFeedByID(ctx context.Context, id uuid.UUID) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, getFeedByID, id)
+	var i Feed
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.Url,
+		&i.UserID,
+		&i.LastFetchedAt,
+		&i.HubUrl,
+		&i.TopicUrl,
 	)
 	return i, err
 }
 
 const getFeedByURL = `-- name: GetFeedByURL :one
-SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at FROM feeds WHERE url = $1
+SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at, hub_url, topic_url FROM feeds WHERE url = $1
 `
 
 func (q *Queries) GetFeedByURL(ctx context.Context, url string) (Feed, error) {
@@ -72,12 +95,14 @@ func (q *Queries) GetFeedByURL(ctx context.Context, url string) (Feed, error) {
 		&i.Url,
 		&i.UserID,
 		&i.LastFetchedAt,
+		&i.HubUrl,
+		&i.TopicUrl,
 	)
 	return i, err
 }
 
 const getFeeds = `-- name: GetFeeds :many
-SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at FROM feeds
+SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at, hub_url, topic_url FROM feeds
 `
 
 func (q *Queries) GetFeeds(ctx context.Context) ([]Feed, error) {
@@ -97,6 +122,93 @@ func (q *Queries) GetFeeds(ctx context.Context) ([]Feed, error) {
 			&i.Url,
 			&i.UserID,
 			&i.LastFetchedAt,
+			&i.HubUrl,
+			&i.TopicUrl,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getFeedsDue = `-- name: GetFeedsDue :many
+SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at, hub_url, topic_url FROM feeds
+    WHERE last_fetched_at IS NULL OR last_fetched_at < $1
+    ORDER BY last_fetched_at ASC NULLS FIRST, created_at ASC
+`
+
+func (q *Queries) GetFeedsDue(ctx context.Context, fetchedBefore sql.NullTime) ([]Feed, error) {
+	rows, err := q.db.QueryContext(ctx, getFeedsDue, fetchedBefore)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Feed
+	for rows.Next() {
+		var i Feed
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Name,
+			&i.Url,
+			&i.UserID,
+			&i.LastFetchedAt,
+			&i.HubUrl,
+			&i.TopicUrl,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getFeedsDueForUser = `-- name: GetFeedsDueForUser :many
+SELECT feeds.id, feeds.created_at, feeds.updated_at, feeds.name, feeds.url, feeds.user_id, feeds.last_fetched_at, feeds.hub_url, feeds.topic_url FROM feeds
+    INNER JOIN feed_follows ON feed_follows.feed_id = feeds.id
+    WHERE feed_follows.user_id = $1
+        AND (feeds.last_fetched_at IS NULL OR feeds.last_fetched_at < $2)
+    ORDER BY feeds.last_fetched_at ASC NULLS FIRST, feeds.created_at ASC
+`
+
+type GetFeedsDueForUserParams struct {
+	UserID        uuid.UUID
+	FetchedBefore sql.NullTime
+}
+
+func (q *Queries) GetFeedsDueForUser(ctx context.Context, arg GetFeedsDueForUserParams) ([]Feed, error) {
+	rows, err := q.db.QueryContext(ctx, getFeedsDueForUser, arg.UserID, arg.FetchedBefore)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Feed
+	for rows.Next() {
+		var i Feed
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Name,
+			&i.Url,
+			&i.UserID,
+			&i.LastFetchedAt,
+			&i.HubUrl,
+			&i.TopicUrl,
 		); err != nil {
 			return nil, err
 		}
@@ -112,11 +224,17 @@ func (q *Queries) GetFeeds(ctx context.Context) ([]Feed, error) {
 }
 
 const getNextFeedToFetch = `-- name: GetNextFeedToFetch :one
-SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at FROM feeds ORDER BY last_fetched_at ASC NULLS FIRST, created_at ASC LIMIT 1
+SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at, hub_url, topic_url FROM feeds
+    WHERE NOT EXISTS (
+        SELECT 1 FROM websub_subscriptions
+            WHERE websub_subscriptions.feed_id = feeds.id
+                AND websub_subscriptions.expires_at > $1)
+    ORDER BY last_fetched_at ASC NULLS FIRST, created_at ASC LIMIT 1
 `
 
-func (q *Queries) GetNextFeedToFetch(ctx context.Context) (Feed, error) {
-	row := q.db.QueryRowContext(ctx, getNextFeedToFetch)
+// Feeds being pushed to us don't need polling
+func (q *Queries) GetNextFeedToFetch(ctx context.Context, now sql.NullTime) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, getNextFeedToFetch, now)
 	var i Feed
 	err := row.Scan(
 		&i.ID,
@@ -126,6 +244,8 @@ func (q *Queries) GetNextFeedToFetch(ctx context.Context) (Feed, error) {
 		&i.Url,
 		&i.UserID,
 		&i.LastFetchedAt,
+		&i.HubUrl,
+		&i.TopicUrl,
 	)
 	return i, err
 }
@@ -145,3 +265,20 @@ func (q *Queries) MarkFeedFetched(ctx context.Context, arg MarkFeedFetchedParams
 	_, err := q.db.ExecContext(ctx, markFeedFetched, arg.ID, arg.LastFetchedAt)
 	return err
 }
+
+const updateFeedURL = `-- name: UpdateFeedURL :exec
+UPDATE feeds
+    SET url = $2, updated_at = $3
+    WHERE id = $1
+`
+
+type UpdateFeedURLParams struct {
+	ID        uuid.UUID
+	Url       string
+	UpdatedAt time.Time
+}
+
+func (q *Queries) UpdateFeedURL(ctx context.Context, arg UpdateFeedURLParams) error {
+	_, err := q.db.ExecContext(ctx, updateFeedURL, arg.ID, arg.Url, arg.UpdatedAt)
+	return err
+}
@@ -0,0 +1,93 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.28.0
+// source: fever.sql
+
+package database
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+const countFeverItems = `-- name: CountFeverItems :one
+SELECT COUNT(*) FROM posts
+    INNER JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
+    INNER JOIN post_numbers ON post_numbers.post_id = posts.id
+    WHERE feed_follows.user_id = $1
+`
+
+func (q *Queries) CountFeverItems(ctx context.Context, userID uuid.UUID) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countFeverItems, userID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const getStarredPostNumbers = `-- name: GetStarredPostNumbers :many
+SELECT post_numbers.number FROM posts
+    INNER JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
+    INNER JOIN post_numbers ON post_numbers.post_id = posts.id
+    INNER JOIN post_stars ON post_stars.post_id = posts.id AND post_stars.user_id = feed_follows.user_id
+    WHERE feed_follows.user_id = $1
+    ORDER BY post_numbers.number
+`
+
+func (q *Queries) GetStarredPostNumbers(ctx context.Context, userID uuid.UUID) ([]int64, error) {
+	rows, err := q.db.QueryContext(ctx, getStarredPostNumbers, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []int64
+	for rows.Next() {
+		var number int64
+		if err := rows.Scan(&number); err != nil {
+			return nil, err
+		}
+		items = append(items, number)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getUnreadPostNumbers = `-- name: GetUnreadPostNumbers :many
+SELECT post_numbers.number FROM posts
+    INNER JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
+    INNER JOIN post_numbers ON post_numbers.post_id = posts.id
+    WHERE feed_follows.user_id = $1
+        AND NOT EXISTS (
+            SELECT 1 FROM post_reads
+                WHERE post_reads.user_id = feed_follows.user_id AND post_reads.post_id = posts.id
+        )
+    ORDER BY post_numbers.number
+`
+
+func (q *Queries) GetUnreadPostNumbers(ctx context.Context, userID uuid.UUID) ([]int64, error) {
+	rows, err := q.db.QueryContext(ctx, getUnreadPostNumbers, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []int64
+	for rows.Next() {
+		var number int64
+		if err := rows.Scan(&number); err != nil {
+			return nil, err
+		}
+		items = append(items, number)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
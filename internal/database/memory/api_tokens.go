@@ -0,0 +1,107 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/venzy/gator/internal/database"
+)
+
+func (s *Store) CreateAPIToken(ctx context.Context, arg database.CreateAPITokenParams) (database.ApiToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.apiTokens[arg.ID]; exists {
+		return database.ApiToken{}, uniqueViolation("api_tokens_pkey")
+	}
+	for _, token := range s.apiTokens {
+		if token.TokenHash == arg.TokenHash {
+			return database.ApiToken{}, uniqueViolation("api_tokens_token_hash_key")
+		}
+		if token.UserID == arg.UserID && token.Name == arg.Name {
+			return database.ApiToken{}, uniqueViolation("unique_user_token_name")
+		}
+		if arg.FeverKey.Valid && token.FeverKey == arg.FeverKey {
+			return database.ApiToken{}, uniqueViolation("api_tokens_fever_key")
+		}
+	}
+	if _, ok := s.users[arg.UserID]; !ok {
+		return database.ApiToken{}, foreignKeyViolation("api_tokens", "fk_user_id")
+	}
+
+	token := database.ApiToken{
+		ID:        arg.ID,
+		CreatedAt: arg.CreatedAt,
+		UserID:    arg.UserID,
+		Name:      arg.Name,
+		TokenHash: arg.TokenHash,
+		Scope:     arg.Scope,
+		FeverKey:  arg.FeverKey,
+	}
+	s.apiTokens[token.ID] = token
+	return token, nil
+}
+
+func (s *Store) GetAPITokensForUser(ctx context.Context, userID uuid.UUID) ([]database.ApiToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var tokens []database.ApiToken
+	for _, token := range sortedValues(s.apiTokens, func(a, b database.ApiToken) int { return strings.Compare(a.Name, b.Name) }) {
+		if token.UserID == userID {
+			tokens = append(tokens, token)
+		}
+	}
+	return tokens, nil
+}
+
+func (s *Store) DeleteAPIToken(ctx context.Context, arg database.DeleteAPITokenParams) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var deleted int64
+	for id, token := range s.apiTokens {
+		if token.UserID == arg.UserID && token.Name == arg.Name {
+			delete(s.apiTokens, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func (s *Store) GetAPITokenByHash(ctx context.Context, tokenHash string) (database.ApiToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, token := range s.apiTokens {
+		if token.TokenHash == tokenHash {
+			return token, nil
+		}
+	}
+	return database.ApiToken{}, sql.ErrNoRows
+}
+
+func (s *Store) GetAPITokenByFeverKey(ctx context.Context, feverKey sql.NullString) (database.ApiToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, token := range s.apiTokens {
+		if feverKey.Valid && token.FeverKey == feverKey {
+			return token, nil
+		}
+	}
+	return database.ApiToken{}, sql.ErrNoRows
+}
+
+func (s *Store) MarkAPITokenUsed(ctx context.Context, arg database.MarkAPITokenUsedParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if token, ok := s.apiTokens[arg.ID]; ok {
+		token.LastUsedAt = arg.LastUsedAt
+		s.apiTokens[arg.ID] = token
+	}
+	return nil
+}
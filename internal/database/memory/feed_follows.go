@@ -0,0 +1,165 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/venzy/gator/internal/database"
+)
+
+func (s *Store) CreateFeedFollow(ctx context.Context, arg database.CreateFeedFollowParams) (database.CreateFeedFollowRow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.follows[arg.ID]; exists {
+		return database.CreateFeedFollowRow{}, uniqueViolation("feed_follows_pkey")
+	}
+	for _, follow := range s.follows {
+		if follow.UserID == arg.UserID && follow.FeedID == arg.FeedID {
+			return database.CreateFeedFollowRow{}, uniqueViolation("unique_user_feed")
+		}
+	}
+	user, ok := s.users[arg.UserID]
+	if !ok {
+		return database.CreateFeedFollowRow{}, foreignKeyViolation("feed_follows", "fk_user_id")
+	}
+	feed, ok := s.feeds[arg.FeedID]
+	if !ok {
+		return database.CreateFeedFollowRow{}, foreignKeyViolation("feed_follows", "fk_feed_id")
+	}
+
+	follow := database.FeedFollow{
+		ID:        arg.ID,
+		CreatedAt: arg.CreatedAt,
+		UpdatedAt: arg.UpdatedAt,
+		UserID:    arg.UserID,
+		FeedID:    arg.FeedID,
+	}
+	s.follows[follow.ID] = follow
+
+	return database.CreateFeedFollowRow{
+		ID:        follow.ID,
+		CreatedAt: follow.CreatedAt,
+		UpdatedAt: follow.UpdatedAt,
+		UserID:    follow.UserID,
+		FeedID:    follow.FeedID,
+		Folder:    follow.Folder,
+		FeedName:  feed.Name,
+		UserName:  user.Name,
+	}, nil
+}
+
+func (s *Store) GetFeedFollowsForUser(ctx context.Context, userID uuid.UUID) ([]database.GetFeedFollowsForUserRow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var rows []database.GetFeedFollowsForUserRow
+	follows := sortedValues(s.follows, func(a, b database.FeedFollow) int { return a.CreatedAt.Compare(b.CreatedAt) })
+	for _, follow := range follows {
+		if follow.UserID != userID {
+			continue
+		}
+		feed := s.feeds[follow.FeedID]
+		rows = append(rows, database.GetFeedFollowsForUserRow{
+			ID:        follow.ID,
+			CreatedAt: follow.CreatedAt,
+			UpdatedAt: follow.UpdatedAt,
+			UserID:    follow.UserID,
+			FeedID:    follow.FeedID,
+			Folder:    follow.Folder,
+			FeedName:  feed.Name,
+			FeedUrl:   feed.Url,
+			UserName:  s.users[follow.UserID].Name,
+		})
+	}
+	return rows, nil
+}
+
+func (s *Store) DeleteFeedFollow(ctx context.Context, arg database.DeleteFeedFollowParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, follow := range s.follows {
+		if follow.UserID == arg.UserID && follow.FeedID == arg.FeedID {
+			delete(s.follows, id)
+		}
+	}
+	return nil
+}
+
+func (s *Store) SetFeedFollowFolder(ctx context.Context, arg database.SetFeedFollowFolderParams) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var updated int64
+	for id, follow := range s.follows {
+		if follow.UserID == arg.UserID && follow.FeedID == arg.FeedID {
+			follow.Folder = arg.Folder
+			follow.UpdatedAt = arg.UpdatedAt
+			s.follows[id] = follow
+			updated++
+		}
+	}
+	return updated, nil
+}
+
+func (s *Store) GetFoldersForUser(ctx context.Context, userID uuid.UUID) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var folders []string
+	for _, follow := range s.follows {
+		if follow.UserID == userID && follow.Folder.Valid && !slices.Contains(folders, follow.Folder.String) {
+			folders = append(folders, follow.Folder.String)
+		}
+	}
+	slices.SortFunc(folders, strings.Compare)
+	return folders, nil
+}
+
+func (s *Store) GetFollowedFeedsWithUnreadCounts(ctx context.Context, userID uuid.UUID) ([]database.GetFollowedFeedsWithUnreadCountsRow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var rows []database.GetFollowedFeedsWithUnreadCountsRow
+	for _, follow := range s.follows {
+		if follow.UserID != userID {
+			continue
+		}
+		feed := s.feeds[follow.FeedID]
+		var unread int64
+		for _, post := range s.posts {
+			if post.FeedID == feed.ID && !s.hasRead(userID, post) {
+				unread++
+			}
+		}
+		rows = append(rows, database.GetFollowedFeedsWithUnreadCountsRow{
+			ID:          feed.ID,
+			Name:        feed.Name,
+			Url:         feed.Url,
+			Folder:      follow.Folder,
+			UnreadCount: unread,
+		})
+	}
+
+	// ORDER BY COALESCE(folder, ''), name
+	slices.SortFunc(rows, func(a, b database.GetFollowedFeedsWithUnreadCountsRow) int {
+		if c := strings.Compare(a.Folder.String, b.Folder.String); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Name, b.Name)
+	})
+	return rows, nil
+}
+
+// Callers hold the lock
+func (s *Store) followFor(userID uuid.UUID, feedID uuid.UUID) (database.FeedFollow, bool) {
+	for _, follow := range s.follows {
+		if follow.UserID == userID && follow.FeedID == feedID {
+			return follow, true
+		}
+	}
+	return database.FeedFollow{}, false
+}
@@ -0,0 +1,72 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/venzy/gator/internal/database"
+)
+
+func (s *Store) CreateFeedWarning(ctx context.Context, arg database.CreateFeedWarningParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.warnings[arg.ID]; exists {
+		return uniqueViolation("feed_warnings_pkey")
+	}
+	if _, ok := s.feeds[arg.FeedID]; !ok {
+		return foreignKeyViolation("feed_warnings", "fk_feed_id")
+	}
+
+	s.warnings[arg.ID] = database.FeedWarning{
+		ID:        arg.ID,
+		CreatedAt: arg.CreatedAt,
+		FeedID:    arg.FeedID,
+		Message:   arg.Message,
+	}
+	return nil
+}
+
+func (s *Store) DeleteFeedWarnings(ctx context.Context, feedID uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, warning := range s.warnings {
+		if warning.FeedID == feedID {
+			delete(s.warnings, id)
+		}
+	}
+	return nil
+}
+
+func (s *Store) GetFeedWarningsForUser(ctx context.Context, userID uuid.UUID) ([]database.GetFeedWarningsForUserRow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var rows []database.GetFeedWarningsForUserRow
+	for _, warning := range s.warnings {
+		if _, following := s.followFor(userID, warning.FeedID); !following {
+			continue
+		}
+		feed := s.feeds[warning.FeedID]
+		rows = append(rows, database.GetFeedWarningsForUserRow{
+			ID:        warning.ID,
+			CreatedAt: warning.CreatedAt,
+			FeedID:    warning.FeedID,
+			Message:   warning.Message,
+			FeedName:  feed.Name,
+			FeedUrl:   feed.Url,
+		})
+	}
+
+	// ORDER BY feeds.name, feed_warnings.created_at
+	slices.SortFunc(rows, func(a, b database.GetFeedWarningsForUserRow) int {
+		if c := strings.Compare(a.FeedName, b.FeedName); c != 0 {
+			return c
+		}
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+	return rows, nil
+}
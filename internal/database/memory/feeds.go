@@ -0,0 +1,162 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/google/uuid"
+	"github.com/venzy/gator/internal/database"
+)
+
+func (s *Store) CreateFeed(ctx context.Context, arg database.CreateFeedParams) (database.Feed, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.feeds[arg.ID]; exists {
+		return database.Feed{}, uniqueViolation("feeds_pkey")
+	}
+	for _, feed := range s.feeds {
+		if feed.Url == arg.Url {
+			return database.Feed{}, uniqueViolation("feeds_url_key")
+		}
+	}
+	if _, ok := s.users[arg.UserID]; !ok {
+		return database.Feed{}, foreignKeyViolation("feeds", "fk_user_id")
+	}
+
+	feed := database.Feed{
+		ID:        arg.ID,
+		CreatedAt: arg.CreatedAt,
+		UpdatedAt: arg.UpdatedAt,
+		Name:      arg.Name,
+		Url:       arg.Url,
+		UserID:    arg.UserID,
+	}
+	s.feeds[feed.ID] = feed
+	return feed, nil
+}
+
+func (s *Store) GetFeeds(ctx context.Context) ([]database.Feed, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return sortedValues(s.feeds, func(a, b database.Feed) int { return a.CreatedAt.Compare(b.CreatedAt) }), nil
+}
+
+func (s *Store) GetFeedByURL(ctx context.Context, url string) (database.Feed, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, feed := range s.feeds {
+		if feed.Url == url {
+			return feed, nil
+		}
+	}
+	return database.Feed{}, sql.ErrNoRows
+}
+
+func (s *Store) GetFeedByID(ctx context.Context, id uuid.UUID) (database.Feed, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	feed, ok := s.feeds[id]
+	if !ok {
+		return database.Feed{}, sql.ErrNoRows
+	}
+	return feed, nil
+}
+
+func (s *Store) GetNextFeedToFetch(ctx context.Context, now sql.NullTime) (database.Feed, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, feed := range sortedValues(s.feeds, compareFetchOrder) {
+		if !s.isPushed(feed.ID, now) {
+			return feed, nil
+		}
+	}
+	return database.Feed{}, sql.ErrNoRows
+}
+
+func (s *Store) GetFeedsDue(ctx context.Context, fetchedBefore sql.NullTime) ([]database.Feed, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var feeds []database.Feed
+	for _, feed := range sortedValues(s.feeds, compareFetchOrder) {
+		if isDue(feed, fetchedBefore) {
+			feeds = append(feeds, feed)
+		}
+	}
+	return feeds, nil
+}
+
+func (s *Store) GetFeedsDueForUser(ctx context.Context, arg database.GetFeedsDueForUserParams) ([]database.Feed, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var feeds []database.Feed
+	for _, feed := range sortedValues(s.feeds, compareFetchOrder) {
+		if _, following := s.followFor(arg.UserID, feed.ID); following && isDue(feed, arg.FetchedBefore) {
+			feeds = append(feeds, feed)
+		}
+	}
+	return feeds, nil
+}
+
+// ORDER BY last_fetched_at ASC NULLS FIRST, created_at ASC
+func compareFetchOrder(a, b database.Feed) int {
+	switch {
+	case a.LastFetchedAt.Valid != b.LastFetchedAt.Valid:
+		if !a.LastFetchedAt.Valid {
+			return -1
+		}
+		return 1
+	case a.LastFetchedAt.Valid && !a.LastFetchedAt.Time.Equal(b.LastFetchedAt.Time):
+		return a.LastFetchedAt.Time.Compare(b.LastFetchedAt.Time)
+	default:
+		return a.CreatedAt.Compare(b.CreatedAt)
+	}
+}
+
+// last_fetched_at IS NULL OR last_fetched_at < fetchedBefore
+func isDue(feed database.Feed, fetchedBefore sql.NullTime) bool {
+	if !feed.LastFetchedAt.Valid {
+		return true
+	}
+	return fetchedBefore.Valid && feed.LastFetchedAt.Time.Before(fetchedBefore.Time)
+}
+
+func (s *Store) MarkFeedFetched(ctx context.Context, arg database.MarkFeedFetchedParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	feed, ok := s.feeds[arg.ID]
+	if !ok {
+		return nil
+	}
+	feed.LastFetchedAt = arg.LastFetchedAt
+	feed.UpdatedAt = arg.LastFetchedAt.Time
+	s.feeds[feed.ID] = feed
+	return nil
+}
+
+func (s *Store) UpdateFeedURL(ctx context.Context, arg database.UpdateFeedURLParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, feed := range s.feeds {
+		if feed.Url == arg.Url && id != arg.ID {
+			return uniqueViolation("feeds_url_key")
+		}
+	}
+
+	feed, ok := s.feeds[arg.ID]
+	if !ok {
+		return nil
+	}
+	feed.Url = arg.Url
+	feed.UpdatedAt = arg.UpdatedAt
+	s.feeds[feed.ID] = feed
+	return nil
+}
@@ -0,0 +1,40 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+func (s *Store) CountFeverItems(ctx context.Context, userID uuid.UUID) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return int64(len(s.numberedPostsFor(userID))), nil
+}
+
+func (s *Store) GetUnreadPostNumbers(ctx context.Context, userID uuid.UUID) ([]int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var numbers []int64
+	for _, post := range s.numberedPostsFor(userID) {
+		if !s.hasRead(userID, post) {
+			numbers = append(numbers, s.postNumbers[post.ID])
+		}
+	}
+	return numbers, nil
+}
+
+func (s *Store) GetStarredPostNumbers(ctx context.Context, userID uuid.UUID) ([]int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var numbers []int64
+	for _, post := range s.numberedPostsFor(userID) {
+		if s.hasStarred(userID, post) {
+			numbers = append(numbers, s.postNumbers[post.ID])
+		}
+	}
+	return numbers, nil
+}
@@ -0,0 +1,198 @@
+// Package memory is an in-memory implementation of database.Querier, for
+// tests. It follows the behaviour of the SQL queries closely enough that
+// handlers can't tell the difference - the same unique constraints, cascading
+// deletes, orderings and sql.ErrNoRows when a :one query finds nothing.
+package memory
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/venzy/gator/internal/database"
+)
+
+type Store struct {
+	mu        sync.Mutex
+	users     map[uuid.UUID]database.User
+	feeds     map[uuid.UUID]database.Feed
+	follows   map[uuid.UUID]database.FeedFollow
+	posts     map[uuid.UUID]database.Post
+	tags      map[uuid.UUID]database.PostTag
+	reads     map[uuid.UUID]database.PostRead
+	stars     map[uuid.UUID]database.PostStar
+	warnings  map[uuid.UUID]database.FeedWarning
+	apiTokens map[uuid.UUID]database.ApiToken
+	sessions  map[uuid.UUID]database.UserSession
+	shares    map[uuid.UUID]database.Share
+	websubs   map[uuid.UUID]database.WebsubSubscription
+	webhooks  map[uuid.UUID]database.Webhook
+	// webhook_deliveries
+	deliveries map[uuid.UUID]database.WebhookDelivery
+	// post_numbers and feed_numbers, keyed by post or feed ID, with the
+	// last number handed out for each
+	postNumbers    map[uuid.UUID]int64
+	feedNumbers    map[uuid.UUID]int64
+	lastPostNumber int64
+	lastFeedNumber int64
+}
+
+var _ database.Querier = (*Store)(nil)
+
+func New() *Store {
+	return &Store{
+		users:       make(map[uuid.UUID]database.User),
+		feeds:       make(map[uuid.UUID]database.Feed),
+		follows:     make(map[uuid.UUID]database.FeedFollow),
+		posts:       make(map[uuid.UUID]database.Post),
+		tags:        make(map[uuid.UUID]database.PostTag),
+		reads:       make(map[uuid.UUID]database.PostRead),
+		stars:       make(map[uuid.UUID]database.PostStar),
+		warnings:    make(map[uuid.UUID]database.FeedWarning),
+		apiTokens:   make(map[uuid.UUID]database.ApiToken),
+		sessions:    make(map[uuid.UUID]database.UserSession),
+		shares:      make(map[uuid.UUID]database.Share),
+		websubs:     make(map[uuid.UUID]database.WebsubSubscription),
+		webhooks:    make(map[uuid.UUID]database.Webhook),
+		deliveries:  make(map[uuid.UUID]database.WebhookDelivery),
+		postNumbers: make(map[uuid.UUID]int64),
+		feedNumbers: make(map[uuid.UUID]int64),
+	}
+}
+
+// Errors worded like Postgres', as handlers only ever print them
+func uniqueViolation(constraint string) error {
+	return fmt.Errorf("duplicate key value violates unique constraint \"%s\"", constraint)
+}
+
+func foreignKeyViolation(table string, constraint string) error {
+	return fmt.Errorf("insert or update on table \"%s\" violates foreign key constraint \"%s\"", table, constraint)
+}
+
+// Map values in a stable order, as rows without an ORDER BY come back in
+// whatever order the database likes but tests shouldn't have to care
+func sortedValues[T any](m map[uuid.UUID]T, cmp func(a, b T) int) []T {
+	values := make([]T, 0, len(m))
+	for _, value := range m {
+		values = append(values, value)
+	}
+	slices.SortFunc(values, cmp)
+	return values
+}
+
+// The cascading deletes from the schema's ON DELETE CASCADE foreign keys.
+// Callers hold the lock.
+
+func (s *Store) deleteUser(id uuid.UUID) {
+	delete(s.users, id)
+	for feedID, feed := range s.feeds {
+		if feed.UserID == id {
+			s.deleteFeed(feedID)
+		}
+	}
+	for followID, follow := range s.follows {
+		if follow.UserID == id {
+			delete(s.follows, followID)
+		}
+	}
+	for tagID, tag := range s.tags {
+		if tag.UserID == id {
+			delete(s.tags, tagID)
+		}
+	}
+	for readID, read := range s.reads {
+		if read.UserID == id {
+			delete(s.reads, readID)
+		}
+	}
+	for starID, star := range s.stars {
+		if star.UserID == id {
+			delete(s.stars, starID)
+		}
+	}
+	for tokenID, token := range s.apiTokens {
+		if token.UserID == id {
+			delete(s.apiTokens, tokenID)
+		}
+	}
+	for sessionID, session := range s.sessions {
+		if session.UserID == id {
+			delete(s.sessions, sessionID)
+		}
+	}
+	for shareID, share := range s.shares {
+		if share.UserID == id {
+			delete(s.shares, shareID)
+		}
+	}
+	for webhookID, webhook := range s.webhooks {
+		if webhook.UserID == id {
+			s.deleteWebhook(webhookID)
+		}
+	}
+}
+
+func (s *Store) deleteFeed(id uuid.UUID) {
+	delete(s.feeds, id)
+	delete(s.feedNumbers, id)
+	for followID, follow := range s.follows {
+		if follow.FeedID == id {
+			delete(s.follows, followID)
+		}
+	}
+	for postID, post := range s.posts {
+		if post.FeedID == id {
+			s.deletePost(postID)
+		}
+	}
+	for warningID, warning := range s.warnings {
+		if warning.FeedID == id {
+			delete(s.warnings, warningID)
+		}
+	}
+	for subscriptionID, subscription := range s.websubs {
+		if subscription.FeedID == id {
+			delete(s.websubs, subscriptionID)
+		}
+	}
+	for webhookID, webhook := range s.webhooks {
+		if webhook.FeedID.Valid && webhook.FeedID.UUID == id {
+			s.deleteWebhook(webhookID)
+		}
+	}
+}
+
+func (s *Store) deletePost(id uuid.UUID) {
+	delete(s.posts, id)
+	delete(s.postNumbers, id)
+	for tagID, tag := range s.tags {
+		if tag.PostID == id {
+			delete(s.tags, tagID)
+		}
+	}
+	for readID, read := range s.reads {
+		if read.PostID == id {
+			delete(s.reads, readID)
+		}
+	}
+	for starID, star := range s.stars {
+		if star.PostID == id {
+			delete(s.stars, starID)
+		}
+	}
+	for deliveryID, delivery := range s.deliveries {
+		if delivery.PostID.Valid && delivery.PostID.UUID == id {
+			delete(s.deliveries, deliveryID)
+		}
+	}
+}
+
+func (s *Store) deleteWebhook(id uuid.UUID) {
+	delete(s.webhooks, id)
+	for deliveryID, delivery := range s.deliveries {
+		if delivery.WebhookID == id {
+			delete(s.deliveries, deliveryID)
+		}
+	}
+}
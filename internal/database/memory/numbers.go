@@ -0,0 +1,181 @@
+package memory
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"database/sql"
+	"slices"
+
+	"github.com/google/uuid"
+	"github.com/venzy/gator/internal/database"
+)
+
+func (s *Store) NumberNewPosts(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, post := range sortedValues(s.posts, comparePostsByCreated) {
+		if _, numbered := s.postNumbers[post.ID]; !numbered {
+			s.lastPostNumber++
+			s.postNumbers[post.ID] = s.lastPostNumber
+		}
+	}
+	return nil
+}
+
+func (s *Store) NumberNewFeeds(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, feed := range sortedValues(s.feeds, compareFeedsByCreated) {
+		if _, numbered := s.feedNumbers[feed.ID]; !numbered {
+			s.lastFeedNumber++
+			s.feedNumbers[feed.ID] = s.lastFeedNumber
+		}
+	}
+	return nil
+}
+
+func (s *Store) GetPostIDByNumber(ctx context.Context, number int64) (uuid.UUID, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, postNumber := range s.postNumbers {
+		if postNumber == number {
+			return id, nil
+		}
+	}
+	return uuid.UUID{}, sql.ErrNoRows
+}
+
+func (s *Store) GetFeedIDByNumber(ctx context.Context, number int64) (uuid.UUID, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, feedNumber := range s.feedNumbers {
+		if feedNumber == number {
+			return id, nil
+		}
+	}
+	return uuid.UUID{}, sql.ErrNoRows
+}
+
+func (s *Store) GetNumberedFeedsForUser(ctx context.Context, userID uuid.UUID) ([]database.GetNumberedFeedsForUserRow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var rows []database.GetNumberedFeedsForUserRow
+	for _, follow := range s.follows {
+		number, numbered := s.feedNumbers[follow.FeedID]
+		if follow.UserID != userID || !numbered {
+			continue
+		}
+		feed := s.feeds[follow.FeedID]
+		rows = append(rows, database.GetNumberedFeedsForUserRow{
+			Number:        number,
+			Name:          feed.Name,
+			Url:           feed.Url,
+			LastFetchedAt: feed.LastFetchedAt,
+			Folder:        follow.Folder,
+		})
+	}
+	slices.SortFunc(rows, func(a, b database.GetNumberedFeedsForUserRow) int { return cmp.Compare(a.Number, b.Number) })
+	return rows, nil
+}
+
+func (s *Store) GetNumberedPostsForUser(ctx context.Context, arg database.GetNumberedPostsForUserParams) ([]database.GetNumberedPostsForUserRow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var rows []database.GetNumberedPostsForUserRow
+	for _, post := range s.numberedPostsFor(arg.UserID) {
+		follow, _ := s.followFor(arg.UserID, post.FeedID)
+		number := s.postNumbers[post.ID]
+		isRead := s.hasRead(arg.UserID, post)
+		isStarred := s.hasStarred(arg.UserID, post)
+
+		if arg.FeedID.Valid && post.FeedID != arg.FeedID.UUID {
+			continue
+		}
+		if arg.Folder.Valid && follow.Folder != arg.Folder {
+			continue
+		}
+		if arg.Tag.Valid && !s.hasTag(arg.UserID, post, arg.Tag.String) {
+			continue
+		}
+		if arg.Read.Valid && isRead != arg.Read.Bool {
+			continue
+		}
+		if arg.StarredOnly && !isStarred {
+			continue
+		}
+		if arg.PublishedSince.Valid && post.PublishedAt.Before(arg.PublishedSince.Time) {
+			continue
+		}
+		if arg.PublishedUntil.Valid && !post.PublishedAt.Before(arg.PublishedUntil.Time) {
+			continue
+		}
+		if arg.SinceNumber.Valid && number <= arg.SinceNumber.Int64 {
+			continue
+		}
+		if arg.MaxNumber.Valid && number >= arg.MaxNumber.Int64 {
+			continue
+		}
+		if arg.Number.Valid && number != arg.Number.Int64 {
+			continue
+		}
+
+		feed := s.feeds[post.FeedID]
+		rows = append(rows, database.GetNumberedPostsForUserRow{
+			ID:          post.ID,
+			Number:      number,
+			FeedNumber:  s.feedNumbers[post.FeedID],
+			FeedName:    feed.Name,
+			FeedUrl:     feed.Url,
+			Folder:      follow.Folder,
+			Title:       post.Title,
+			Url:         post.Url,
+			Description: post.Description,
+			PublishedAt: post.PublishedAt,
+			CreatedAt:   post.CreatedAt,
+			IsRead:      isRead,
+			IsStarred:   isStarred,
+		})
+	}
+
+	if !arg.Ascending {
+		slices.Reverse(rows)
+	}
+	if len(rows) > int(arg.RowLimit) {
+		rows = rows[:arg.RowLimit]
+	}
+	return rows, nil
+}
+
+func comparePostsByCreated(a, b database.Post) int {
+	if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+		return c
+	}
+	return bytes.Compare(a.ID[:], b.ID[:])
+}
+
+func compareFeedsByCreated(a, b database.Feed) int {
+	if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+		return c
+	}
+	return bytes.Compare(a.ID[:], b.ID[:])
+}
+
+// Numbered posts in feeds the user follows, by number. Callers hold the lock.
+func (s *Store) numberedPostsFor(userID uuid.UUID) []database.Post {
+	var posts []database.Post
+	for _, post := range s.posts {
+		_, numbered := s.postNumbers[post.ID]
+		if _, following := s.followFor(userID, post.FeedID); following && numbered {
+			posts = append(posts, post)
+		}
+	}
+	slices.SortFunc(posts, func(a, b database.Post) int { return cmp.Compare(s.postNumbers[a.ID], s.postNumbers[b.ID]) })
+	return posts
+}
@@ -0,0 +1,112 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/venzy/gator/internal/database"
+)
+
+func (s *Store) MarkPostRead(ctx context.Context, arg database.MarkPostReadParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.reads[arg.ID]; exists {
+		return uniqueViolation("post_reads_pkey")
+	}
+	if s.hasRead(arg.UserID, s.posts[arg.PostID]) {
+		return nil
+	}
+	if err := s.checkUserAndPost("post_reads", arg.UserID, arg.PostID); err != nil {
+		return err
+	}
+
+	s.reads[arg.ID] = database.PostRead{
+		ID:        arg.ID,
+		CreatedAt: arg.CreatedAt,
+		UserID:    arg.UserID,
+		PostID:    arg.PostID,
+	}
+	return nil
+}
+
+func (s *Store) MarkPostUnread(ctx context.Context, arg database.MarkPostUnreadParams) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var deleted int64
+	for id, read := range s.reads {
+		if read.UserID == arg.UserID && read.PostID == arg.PostID {
+			delete(s.reads, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func (s *Store) StarPost(ctx context.Context, arg database.StarPostParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.stars[arg.ID]; exists {
+		return uniqueViolation("post_stars_pkey")
+	}
+	if s.hasStarred(arg.UserID, s.posts[arg.PostID]) {
+		return nil
+	}
+	if err := s.checkUserAndPost("post_stars", arg.UserID, arg.PostID); err != nil {
+		return err
+	}
+
+	s.stars[arg.ID] = database.PostStar{
+		ID:        arg.ID,
+		CreatedAt: arg.CreatedAt,
+		UserID:    arg.UserID,
+		PostID:    arg.PostID,
+	}
+	return nil
+}
+
+func (s *Store) UnstarPost(ctx context.Context, arg database.UnstarPostParams) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var deleted int64
+	for id, star := range s.stars {
+		if star.UserID == arg.UserID && star.PostID == arg.PostID {
+			delete(s.stars, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// Callers hold the lock for these
+
+func (s *Store) checkUserAndPost(table string, userID uuid.UUID, postID uuid.UUID) error {
+	if _, ok := s.users[userID]; !ok {
+		return foreignKeyViolation(table, "fk_user_id")
+	}
+	if _, ok := s.posts[postID]; !ok {
+		return foreignKeyViolation(table, "fk_post_id")
+	}
+	return nil
+}
+
+func (s *Store) hasRead(userID uuid.UUID, post database.Post) bool {
+	for _, read := range s.reads {
+		if read.UserID == userID && read.PostID == post.ID {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Store) hasStarred(userID uuid.UUID, post database.Post) bool {
+	for _, star := range s.stars {
+		if star.UserID == userID && star.PostID == post.ID {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,97 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/venzy/gator/internal/database"
+)
+
+func (s *Store) CreatePostTag(ctx context.Context, arg database.CreatePostTagParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.tags[arg.ID]; exists {
+		return uniqueViolation("post_tags_pkey")
+	}
+	// ON CONFLICT (user_id, post_id, tag) DO NOTHING
+	for _, tag := range s.tags {
+		if tag.UserID == arg.UserID && tag.PostID == arg.PostID && tag.Tag == arg.Tag {
+			return nil
+		}
+	}
+	if _, ok := s.users[arg.UserID]; !ok {
+		return foreignKeyViolation("post_tags", "fk_user_id")
+	}
+	if _, ok := s.posts[arg.PostID]; !ok {
+		return foreignKeyViolation("post_tags", "fk_post_id")
+	}
+
+	s.tags[arg.ID] = database.PostTag{
+		ID:        arg.ID,
+		CreatedAt: arg.CreatedAt,
+		UserID:    arg.UserID,
+		PostID:    arg.PostID,
+		Tag:       arg.Tag,
+	}
+	return nil
+}
+
+func (s *Store) DeletePostTag(ctx context.Context, arg database.DeletePostTagParams) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var deleted int64
+	for id, tag := range s.tags {
+		if tag.UserID == arg.UserID && tag.PostID == arg.PostID && tag.Tag == arg.Tag {
+			delete(s.tags, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func (s *Store) GetTagsForPost(ctx context.Context, arg database.GetTagsForPostParams) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var tags []string
+	for _, tag := range s.tags {
+		if tag.UserID == arg.UserID && tag.PostID == arg.PostID {
+			tags = append(tags, tag.Tag)
+		}
+	}
+	slices.SortFunc(tags, strings.Compare)
+	return tags, nil
+}
+
+func (s *Store) GetTagCountsForUser(ctx context.Context, userID uuid.UUID) ([]database.GetTagCountsForUserRow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	counts := make(map[string]int64)
+	for _, tag := range s.tags {
+		if tag.UserID == userID {
+			counts[tag.Tag]++
+		}
+	}
+
+	var rows []database.GetTagCountsForUserRow
+	for tag, count := range counts {
+		rows = append(rows, database.GetTagCountsForUserRow{Tag: tag, PostCount: count})
+	}
+	slices.SortFunc(rows, func(a, b database.GetTagCountsForUserRow) int { return strings.Compare(a.Tag, b.Tag) })
+	return rows, nil
+}
+
+// Callers hold the lock
+func (s *Store) hasTag(userID uuid.UUID, post database.Post, name string) bool {
+	for _, tag := range s.tags {
+		if tag.UserID == userID && tag.PostID == post.ID && tag.Tag == name {
+			return true
+		}
+	}
+	return false
+}
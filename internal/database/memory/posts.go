@@ -0,0 +1,230 @@
+package memory
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/venzy/gator/internal/database"
+)
+
+func (s *Store) CreatePost(ctx context.Context, arg database.CreatePostParams) (database.Post, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.posts[arg.ID]; exists {
+		return database.Post{}, uniqueViolation("posts_pkey")
+	}
+	// ON CONFLICT (url) DO NOTHING returns no row
+	for _, post := range s.posts {
+		if post.Url == arg.Url {
+			return database.Post{}, sql.ErrNoRows
+		}
+	}
+	if _, ok := s.feeds[arg.FeedID]; !ok {
+		return database.Post{}, foreignKeyViolation("posts", "fk_feed_id")
+	}
+
+	post := database.Post{
+		ID:              arg.ID,
+		CreatedAt:       arg.CreatedAt,
+		UpdatedAt:       arg.UpdatedAt,
+		Title:           arg.Title,
+		Url:             arg.Url,
+		Description:     arg.Description,
+		PublishedAt:     arg.PublishedAt,
+		FeedID:          arg.FeedID,
+		DescriptionText: arg.DescriptionText,
+	}
+	s.posts[post.ID] = post
+	return post, nil
+}
+
+func (s *Store) GetPostsForUser(ctx context.Context, arg database.GetPostsForUserParams) ([]database.GetPostsForUserRow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var rows []database.GetPostsForUserRow
+	for _, post := range s.posts {
+		if _, following := s.followFor(arg.UserID, post.FeedID); !following {
+			continue
+		}
+		rows = append(rows, database.GetPostsForUserRow{
+			ID:              post.ID,
+			CreatedAt:       post.CreatedAt,
+			UpdatedAt:       post.UpdatedAt,
+			Title:           post.Title,
+			Url:             post.Url,
+			Description:     post.Description,
+			PublishedAt:     post.PublishedAt,
+			FeedID:          post.FeedID,
+			DescriptionText: post.DescriptionText,
+			FeedName:        s.feeds[post.FeedID].Name,
+		})
+	}
+
+	slices.SortFunc(rows, func(a, b database.GetPostsForUserRow) int {
+		return b.PublishedAt.Compare(a.PublishedAt)
+	})
+	return rows[:min(len(rows), int(arg.Limit))], nil
+}
+
+func (s *Store) GetPostByURL(ctx context.Context, url string) (database.Post, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, post := range s.posts {
+		if post.Url == url {
+			return post, nil
+		}
+	}
+	return database.Post{}, sql.ErrNoRows
+}
+
+func (s *Store) GetPostForUser(ctx context.Context, arg database.GetPostForUserParams) (database.GetPostForUserRow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	post, ok := s.posts[arg.ID]
+	if !ok {
+		return database.GetPostForUserRow{}, sql.ErrNoRows
+	}
+	follow, following := s.followFor(arg.UserID, post.FeedID)
+	if !following {
+		return database.GetPostForUserRow{}, sql.ErrNoRows
+	}
+
+	return database.GetPostForUserRow{
+		ID:              post.ID,
+		CreatedAt:       post.CreatedAt,
+		UpdatedAt:       post.UpdatedAt,
+		Title:           post.Title,
+		Url:             post.Url,
+		Description:     post.Description,
+		PublishedAt:     post.PublishedAt,
+		FeedID:          post.FeedID,
+		DescriptionText: post.DescriptionText,
+		FeedName:        s.feeds[post.FeedID].Name,
+		Folder:          follow.Folder,
+		IsRead:          s.hasRead(arg.UserID, post),
+		IsStarred:       s.hasStarred(arg.UserID, post),
+	}, nil
+}
+
+func (s *Store) BrowsePostsForUser(ctx context.Context, arg database.BrowsePostsForUserParams) ([]database.BrowsePostsForUserRow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var rows []database.BrowsePostsForUserRow
+	for _, post := range s.posts {
+		follow, following := s.followFor(arg.UserID, post.FeedID)
+		if !following {
+			continue
+		}
+		feed := s.feeds[post.FeedID]
+		isRead := s.hasRead(arg.UserID, post)
+		isStarred := s.hasStarred(arg.UserID, post)
+		sortTime := post.PublishedAt
+		if arg.SortByFetched {
+			sortTime = post.CreatedAt
+		}
+
+		switch {
+		case arg.FeedUrl.Valid && feed.Url != arg.FeedUrl.String:
+			continue
+		case arg.Folder.Valid && (!follow.Folder.Valid || follow.Folder.String != arg.Folder.String):
+			continue
+		case arg.Since.Valid && post.PublishedAt.Before(arg.Since.Time):
+			continue
+		case arg.Until.Valid && !post.PublishedAt.Before(arg.Until.Time):
+			continue
+		case arg.UnreadOnly && isRead:
+			continue
+		case arg.StarredOnly && !isStarred:
+			continue
+		case arg.Tag.Valid && !s.hasTag(arg.UserID, post, arg.Tag.String):
+			continue
+		case arg.Search.Valid && !matchLike(arg.Search.String, post.Title) && !matchLike(arg.Search.String, post.DescriptionText.String):
+			continue
+		case arg.AfterTime.Valid && compareSortKeys(sortTime, post.ID, arg) >= 0:
+			continue
+		}
+
+		rows = append(rows, database.BrowsePostsForUserRow{
+			ID:              post.ID,
+			CreatedAt:       post.CreatedAt,
+			UpdatedAt:       post.UpdatedAt,
+			Title:           post.Title,
+			Url:             post.Url,
+			Description:     post.Description,
+			PublishedAt:     post.PublishedAt,
+			FeedID:          post.FeedID,
+			DescriptionText: post.DescriptionText,
+			FeedName:        feed.Name,
+			Folder:          follow.Folder,
+			IsRead:          isRead,
+			IsStarred:       isStarred,
+			SortTime:        sortTime,
+		})
+	}
+
+	// ORDER BY sort_time DESC, posts.id DESC
+	slices.SortFunc(rows, func(a, b database.BrowsePostsForUserRow) int {
+		if c := b.SortTime.Compare(a.SortTime); c != 0 {
+			return c
+		}
+		return bytes.Compare(b.ID[:], a.ID[:])
+	})
+	return rows[:min(len(rows), int(arg.RowLimit))], nil
+}
+
+// Compares a post's (sort_time, id) with the keyset pagination cursor
+func compareSortKeys(sortTime time.Time, id uuid.UUID, arg database.BrowsePostsForUserParams) int {
+	if c := sortTime.Compare(arg.AfterTime.Time); c != 0 {
+		return c
+	}
+	return bytes.Compare(id[:], arg.AfterID.UUID[:])
+}
+
+// Case-insensitive LIKE with backslash as the escape character, as the
+// browse query's ILIKE ... ESCAPE '\'
+func matchLike(pattern string, value string) bool {
+	pat := []rune(strings.ToLower(pattern))
+	val := []rune(strings.ToLower(value))
+
+	var match func(p, v int) bool
+	match = func(p, v int) bool {
+		for p < len(pat) {
+			switch pat[p] {
+			case '%':
+				for skip := v; skip <= len(val); skip++ {
+					if match(p+1, skip) {
+						return true
+					}
+				}
+				return false
+			case '_':
+				if v == len(val) {
+					return false
+				}
+			case '\\':
+				p++
+				if p == len(pat) || v == len(val) || pat[p] != val[v] {
+					return false
+				}
+			default:
+				if v == len(val) || pat[p] != val[v] {
+					return false
+				}
+			}
+			p++
+			v++
+		}
+		return v == len(val)
+	}
+	return match(0, 0)
+}
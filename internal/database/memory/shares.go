@@ -0,0 +1,82 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/venzy/gator/internal/database"
+)
+
+func (s *Store) CreateShare(ctx context.Context, arg database.CreateShareParams) (database.Share, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.shares[arg.ID]; exists {
+		return database.Share{}, uniqueViolation("shares_pkey")
+	}
+	for _, share := range s.shares {
+		if share.TokenHash == arg.TokenHash {
+			return database.Share{}, uniqueViolation("shares_token_hash_key")
+		}
+		if share.UserID == arg.UserID && share.Name == arg.Name {
+			return database.Share{}, uniqueViolation("unique_user_share_name")
+		}
+	}
+	if _, ok := s.users[arg.UserID]; !ok {
+		return database.Share{}, foreignKeyViolation("shares", "fk_user_id")
+	}
+
+	share := database.Share{
+		ID:        arg.ID,
+		CreatedAt: arg.CreatedAt,
+		UserID:    arg.UserID,
+		Name:      arg.Name,
+		TokenHash: arg.TokenHash,
+		Folder:    arg.Folder,
+		Tag:       arg.Tag,
+		Starred:   arg.Starred,
+	}
+	s.shares[share.ID] = share
+	return share, nil
+}
+
+func (s *Store) GetSharesForUser(ctx context.Context, userID uuid.UUID) ([]database.Share, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var shares []database.Share
+	for _, share := range sortedValues(s.shares, func(a, b database.Share) int { return strings.Compare(a.Name, b.Name) }) {
+		if share.UserID == userID {
+			shares = append(shares, share)
+		}
+	}
+	return shares, nil
+}
+
+func (s *Store) DeleteShare(ctx context.Context, arg database.DeleteShareParams) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var deleted int64
+	for id, share := range s.shares {
+		if share.UserID == arg.UserID && share.Name == arg.Name {
+			delete(s.shares, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func (s *Store) GetShareByHash(ctx context.Context, tokenHash string) (database.Share, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, share := range s.shares {
+		if share.TokenHash == tokenHash {
+			return share, nil
+		}
+	}
+	return database.Share{}, sql.ErrNoRows
+}
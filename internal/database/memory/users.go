@@ -0,0 +1,132 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/google/uuid"
+	"github.com/venzy/gator/internal/database"
+)
+
+func (s *Store) CreateUser(ctx context.Context, arg database.CreateUserParams) (database.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.users[arg.ID]; exists {
+		return database.User{}, uniqueViolation("users_pkey")
+	}
+
+	user := database.User{
+		ID:           arg.ID,
+		CreatedAt:    arg.CreatedAt,
+		UpdatedAt:    arg.UpdatedAt,
+		Name:         arg.Name,
+		PasswordHash: arg.PasswordHash,
+	}
+	s.users[user.ID] = user
+	return user, nil
+}
+
+func (s *Store) GetUserByName(ctx context.Context, name string) (database.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, user := range s.users {
+		if user.Name == name {
+			return user, nil
+		}
+	}
+	return database.User{}, sql.ErrNoRows
+}
+
+func (s *Store) GetUserByID(ctx context.Context, id uuid.UUID) (database.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[id]
+	if !ok {
+		return database.User{}, sql.ErrNoRows
+	}
+	return user, nil
+}
+
+func (s *Store) GetUsers(ctx context.Context) ([]database.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return sortedValues(s.users, func(a, b database.User) int { return a.CreatedAt.Compare(b.CreatedAt) }), nil
+}
+
+func (s *Store) ResetUsers(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id := range s.users {
+		s.deleteUser(id)
+	}
+	return nil
+}
+
+func (s *Store) SetUserPassword(ctx context.Context, arg database.SetUserPasswordParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if user, ok := s.users[arg.ID]; ok {
+		user.PasswordHash = arg.PasswordHash
+		user.UpdatedAt = arg.UpdatedAt
+		s.users[arg.ID] = user
+	}
+	return nil
+}
+
+func (s *Store) CreateUserSession(ctx context.Context, arg database.CreateUserSessionParams) (database.UserSession, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.sessions[arg.ID]; exists {
+		return database.UserSession{}, uniqueViolation("user_sessions_pkey")
+	}
+	for _, session := range s.sessions {
+		if session.TokenHash == arg.TokenHash {
+			return database.UserSession{}, uniqueViolation("user_sessions_token_hash_key")
+		}
+	}
+	if _, ok := s.users[arg.UserID]; !ok {
+		return database.UserSession{}, foreignKeyViolation("user_sessions", "fk_user_id")
+	}
+
+	session := database.UserSession{
+		ID:        arg.ID,
+		CreatedAt: arg.CreatedAt,
+		UserID:    arg.UserID,
+		TokenHash: arg.TokenHash,
+	}
+	s.sessions[session.ID] = session
+	return session, nil
+}
+
+func (s *Store) GetUserBySession(ctx context.Context, tokenHash string) (database.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, session := range s.sessions {
+		if session.TokenHash == tokenHash {
+			if user, ok := s.users[session.UserID]; ok {
+				return user, nil
+			}
+		}
+	}
+	return database.User{}, sql.ErrNoRows
+}
+
+func (s *Store) DeleteUserSessions(ctx context.Context, userID uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, session := range s.sessions {
+		if session.UserID == userID {
+			delete(s.sessions, id)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,224 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/venzy/gator/internal/database"
+)
+
+func (s *Store) CreateWebhook(ctx context.Context, arg database.CreateWebhookParams) (database.Webhook, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.webhooks[arg.ID]; exists {
+		return database.Webhook{}, uniqueViolation("webhooks_pkey")
+	}
+	for _, webhook := range s.webhooks {
+		if webhook.UserID == arg.UserID && webhook.Name == arg.Name {
+			return database.Webhook{}, uniqueViolation("unique_user_webhook_name")
+		}
+	}
+	if _, ok := s.users[arg.UserID]; !ok {
+		return database.Webhook{}, foreignKeyViolation("webhooks", "fk_user_id")
+	}
+	if _, ok := s.feeds[arg.FeedID.UUID]; arg.FeedID.Valid && !ok {
+		return database.Webhook{}, foreignKeyViolation("webhooks", "fk_feed_id")
+	}
+
+	webhook := database.Webhook{
+		ID:        arg.ID,
+		CreatedAt: arg.CreatedAt,
+		UserID:    arg.UserID,
+		Name:      arg.Name,
+		Url:       arg.Url,
+		FeedID:    arg.FeedID,
+		Folder:    arg.Folder,
+		Match:     arg.Match,
+		Template:  arg.Template,
+		Secret:    arg.Secret,
+	}
+	s.webhooks[webhook.ID] = webhook
+	return webhook, nil
+}
+
+func (s *Store) GetWebhooksForUser(ctx context.Context, userID uuid.UUID) ([]database.Webhook, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var webhooks []database.Webhook
+	for _, webhook := range sortedValues(s.webhooks, func(a, b database.Webhook) int { return strings.Compare(a.Name, b.Name) }) {
+		if webhook.UserID == userID {
+			webhooks = append(webhooks, webhook)
+		}
+	}
+	return webhooks, nil
+}
+
+func (s *Store) GetWebhookByName(ctx context.Context, arg database.GetWebhookByNameParams) (database.Webhook, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, webhook := range s.webhooks {
+		if webhook.UserID == arg.UserID && webhook.Name == arg.Name {
+			return webhook, nil
+		}
+	}
+	return database.Webhook{}, sql.ErrNoRows
+}
+
+func (s *Store) DeleteWebhook(ctx context.Context, arg database.DeleteWebhookParams) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var deleted int64
+	for id, webhook := range s.webhooks {
+		if webhook.UserID == arg.UserID && webhook.Name == arg.Name {
+			s.deleteWebhook(id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func (s *Store) GetWebhooksForFeed(ctx context.Context, feedID uuid.UUID) ([]database.Webhook, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var webhooks []database.Webhook
+	for _, webhook := range sortedValues(s.webhooks, func(a, b database.Webhook) int { return a.CreatedAt.Compare(b.CreatedAt) }) {
+		if webhook.FeedID.Valid && webhook.FeedID.UUID != feedID {
+			continue
+		}
+		for _, follow := range s.follows {
+			if follow.UserID == webhook.UserID && follow.FeedID == feedID &&
+				(!webhook.Folder.Valid || (follow.Folder.Valid && follow.Folder.String == webhook.Folder.String)) {
+				webhooks = append(webhooks, webhook)
+				break
+			}
+		}
+	}
+	return webhooks, nil
+}
+
+func (s *Store) CreateWebhookDelivery(ctx context.Context, arg database.CreateWebhookDeliveryParams) (database.WebhookDelivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.deliveries[arg.ID]; exists {
+		return database.WebhookDelivery{}, uniqueViolation("webhook_deliveries_pkey")
+	}
+	if _, ok := s.webhooks[arg.WebhookID]; !ok {
+		return database.WebhookDelivery{}, foreignKeyViolation("webhook_deliveries", "fk_webhook_id")
+	}
+	if _, ok := s.posts[arg.PostID.UUID]; arg.PostID.Valid && !ok {
+		return database.WebhookDelivery{}, foreignKeyViolation("webhook_deliveries", "fk_post_id")
+	}
+
+	delivery := database.WebhookDelivery{
+		ID:            arg.ID,
+		CreatedAt:     arg.CreatedAt,
+		WebhookID:     arg.WebhookID,
+		PostID:        arg.PostID,
+		Payload:       arg.Payload,
+		Attempts:      arg.Attempts,
+		NextAttemptAt: arg.NextAttemptAt,
+	}
+	s.deliveries[delivery.ID] = delivery
+	return delivery, nil
+}
+
+func (s *Store) UpdateWebhookDelivery(ctx context.Context, arg database.UpdateWebhookDeliveryParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if delivery, ok := s.deliveries[arg.ID]; ok {
+		delivery.Attempts = arg.Attempts
+		delivery.LastStatus = arg.LastStatus
+		delivery.LastError = arg.LastError
+		delivery.DeliveredAt = arg.DeliveredAt
+		delivery.NextAttemptAt = arg.NextAttemptAt
+		s.deliveries[arg.ID] = delivery
+	}
+	return nil
+}
+
+func (s *Store) ClaimWebhookDelivery(ctx context.Context, arg database.ClaimWebhookDeliveryParams) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delivery, ok := s.deliveries[arg.ID]
+	if !ok || !delivery.NextAttemptAt.Valid || !arg.Now.Valid || delivery.NextAttemptAt.Time.After(arg.Now.Time) {
+		return 0, nil
+	}
+	delivery.NextAttemptAt = arg.ClaimedUntil
+	s.deliveries[arg.ID] = delivery
+	return 1, nil
+}
+
+func (s *Store) GetDueWebhookDeliveries(ctx context.Context, arg database.GetDueWebhookDeliveriesParams) ([]database.GetDueWebhookDeliveriesRow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var rows []database.GetDueWebhookDeliveriesRow
+	byNextAttempt := func(a, b database.WebhookDelivery) int { return a.NextAttemptAt.Time.Compare(b.NextAttemptAt.Time) }
+	for _, delivery := range sortedValues(s.deliveries, byNextAttempt) {
+		if !delivery.NextAttemptAt.Valid || !arg.Now.Valid || delivery.NextAttemptAt.Time.After(arg.Now.Time) {
+			continue
+		}
+		if len(rows) == int(arg.RowLimit) {
+			break
+		}
+		webhook := s.webhooks[delivery.WebhookID]
+		rows = append(rows, database.GetDueWebhookDeliveriesRow{
+			ID:            delivery.ID,
+			CreatedAt:     delivery.CreatedAt,
+			WebhookID:     delivery.WebhookID,
+			PostID:        delivery.PostID,
+			Payload:       delivery.Payload,
+			Attempts:      delivery.Attempts,
+			LastStatus:    delivery.LastStatus,
+			LastError:     delivery.LastError,
+			DeliveredAt:   delivery.DeliveredAt,
+			NextAttemptAt: delivery.NextAttemptAt,
+			WebhookName:   webhook.Name,
+			Url:           webhook.Url,
+			Secret:        webhook.Secret,
+		})
+	}
+	return rows, nil
+}
+
+func (s *Store) GetWebhookDeliveriesForUser(ctx context.Context, arg database.GetWebhookDeliveriesForUserParams) ([]database.GetWebhookDeliveriesForUserRow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var rows []database.GetWebhookDeliveriesForUserRow
+	newestFirst := func(a, b database.WebhookDelivery) int { return b.CreatedAt.Compare(a.CreatedAt) }
+	for _, delivery := range sortedValues(s.deliveries, newestFirst) {
+		webhook := s.webhooks[delivery.WebhookID]
+		if webhook.UserID != arg.UserID || (arg.WebhookName != "" && webhook.Name != arg.WebhookName) {
+			continue
+		}
+		if len(rows) == int(arg.RowLimit) {
+			break
+		}
+		row := database.GetWebhookDeliveriesForUserRow{
+			ID:            delivery.ID,
+			CreatedAt:     delivery.CreatedAt,
+			WebhookName:   webhook.Name,
+			Attempts:      delivery.Attempts,
+			LastStatus:    delivery.LastStatus,
+			LastError:     delivery.LastError,
+			DeliveredAt:   delivery.DeliveredAt,
+			NextAttemptAt: delivery.NextAttemptAt,
+		}
+		if post, ok := s.posts[delivery.PostID.UUID]; delivery.PostID.Valid && ok {
+			row.PostTitle = sql.NullString{String: post.Title, Valid: true}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
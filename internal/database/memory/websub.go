@@ -0,0 +1,121 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/google/uuid"
+	"github.com/venzy/gator/internal/database"
+)
+
+func (s *Store) SetFeedHub(ctx context.Context, arg database.SetFeedHubParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if feed, ok := s.feeds[arg.ID]; ok {
+		feed.HubUrl = arg.HubUrl
+		feed.TopicUrl = arg.TopicUrl
+		s.feeds[arg.ID] = feed
+	}
+	return nil
+}
+
+func (s *Store) GetFeedsWithHubs(ctx context.Context) ([]database.Feed, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var feeds []database.Feed
+	for _, feed := range sortedValues(s.feeds, func(a, b database.Feed) int { return a.CreatedAt.Compare(b.CreatedAt) }) {
+		if feed.HubUrl.Valid && feed.TopicUrl.Valid {
+			feeds = append(feeds, feed)
+		}
+	}
+	return feeds, nil
+}
+
+func (s *Store) CreateWebSubSubscription(ctx context.Context, arg database.CreateWebSubSubscriptionParams) (database.WebsubSubscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.websubs[arg.ID]; exists {
+		return database.WebsubSubscription{}, uniqueViolation("websub_subscriptions_pkey")
+	}
+	for _, subscription := range s.websubs {
+		if subscription.FeedID == arg.FeedID {
+			return database.WebsubSubscription{}, uniqueViolation("websub_subscriptions_feed_id_key")
+		}
+	}
+	if _, ok := s.feeds[arg.FeedID]; !ok {
+		return database.WebsubSubscription{}, foreignKeyViolation("websub_subscriptions", "fk_feed_id")
+	}
+
+	subscription := database.WebsubSubscription{
+		ID:        arg.ID,
+		CreatedAt: arg.CreatedAt,
+		UpdatedAt: arg.UpdatedAt,
+		FeedID:    arg.FeedID,
+		HubUrl:    arg.HubUrl,
+		TopicUrl:  arg.TopicUrl,
+		Secret:    arg.Secret,
+		State:     arg.State,
+	}
+	s.websubs[subscription.ID] = subscription
+	return subscription, nil
+}
+
+func (s *Store) GetWebSubSubscription(ctx context.Context, id uuid.UUID) (database.WebsubSubscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	subscription, ok := s.websubs[id]
+	if !ok {
+		return database.WebsubSubscription{}, sql.ErrNoRows
+	}
+	return subscription, nil
+}
+
+func (s *Store) GetWebSubSubscriptionForFeed(ctx context.Context, feedID uuid.UUID) (database.WebsubSubscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, subscription := range s.websubs {
+		if subscription.FeedID == feedID {
+			return subscription, nil
+		}
+	}
+	return database.WebsubSubscription{}, sql.ErrNoRows
+}
+
+func (s *Store) UpdateWebSubSubscription(ctx context.Context, arg database.UpdateWebSubSubscriptionParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if subscription, ok := s.websubs[arg.ID]; ok {
+		subscription.HubUrl = arg.HubUrl
+		subscription.TopicUrl = arg.TopicUrl
+		subscription.State = arg.State
+		subscription.ExpiresAt = arg.ExpiresAt
+		subscription.UpdatedAt = arg.UpdatedAt
+		s.websubs[arg.ID] = subscription
+	}
+	return nil
+}
+
+func (s *Store) DeleteWebSubSubscription(ctx context.Context, id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.websubs, id)
+	return nil
+}
+
+// Whether a feed's being pushed to us, so doesn't need polling. Callers hold
+// the lock.
+func (s *Store) isPushed(feedID uuid.UUID, now sql.NullTime) bool {
+	for _, subscription := range s.websubs {
+		if subscription.FeedID == feedID && subscription.ExpiresAt.Valid && now.Valid && subscription.ExpiresAt.Time.After(now.Time) {
+			return true
+		}
+	}
+	return false
+}
@@ -11,6 +11,17 @@ import (
 	"github.com/google/uuid"
 )
 
+type ApiToken struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	UserID     uuid.UUID
+	Name       string
+	TokenHash  string
+	Scope      string
+	LastUsedAt sql.NullTime
+	FeverKey   sql.NullString
+}
+
 type Feed struct {
 	ID            uuid.UUID
 	CreatedAt     time.Time
@@ -19,6 +30,8 @@ type Feed struct {
 	Url           string
 	UserID        uuid.UUID
 	LastFetchedAt sql.NullTime
+	HubUrl        sql.NullString
+	TopicUrl      sql.NullString
 }
 
 type FeedFollow struct {
@@ -27,22 +40,120 @@ type FeedFollow struct {
 	UpdatedAt time.Time
 	UserID    uuid.UUID
 	FeedID    uuid.UUID
+	Folder    sql.NullString
+}
+
+type FeedNumber struct {
+	Number int64
+	FeedID uuid.UUID
+}
+
+type FeedWarning struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	FeedID    uuid.UUID
+	Message   string
 }
 
 type Post struct {
-	ID          uuid.UUID
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
-	Title       string
-	Url         string
-	Description sql.NullString
-	PublishedAt time.Time
-	FeedID      uuid.UUID
+	ID              uuid.UUID
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	Title           string
+	Url             string
+	Description     sql.NullString
+	PublishedAt     time.Time
+	FeedID          uuid.UUID
+	DescriptionText sql.NullString
+}
+
+type PostNumber struct {
+	Number int64
+	PostID uuid.UUID
+}
+
+type PostRead struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UserID    uuid.UUID
+	PostID    uuid.UUID
+}
+
+type PostStar struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UserID    uuid.UUID
+	PostID    uuid.UUID
+}
+
+type PostTag struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UserID    uuid.UUID
+	PostID    uuid.UUID
+	Tag       string
+}
+
+type Share struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UserID    uuid.UUID
+	Name      string
+	TokenHash string
+	Folder    sql.NullString
+	Tag       sql.NullString
+	Starred   bool
 }
 
 type User struct {
+	ID           uuid.UUID
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	Name         string
+	PasswordHash sql.NullString
+}
+
+type UserSession struct {
 	ID        uuid.UUID
 	CreatedAt time.Time
-	UpdatedAt time.Time
+	UserID    uuid.UUID
+	TokenHash string
+}
+
+type Webhook struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UserID    uuid.UUID
 	Name      string
+	Url       string
+	FeedID    uuid.NullUUID
+	Folder    sql.NullString
+	Match     sql.NullString
+	Template  sql.NullString
+	Secret    string
+}
+
+type WebhookDelivery struct {
+	ID            uuid.UUID
+	CreatedAt     time.Time
+	WebhookID     uuid.UUID
+	PostID        uuid.NullUUID
+	Payload       string
+	Attempts      int32
+	LastStatus    sql.NullInt32
+	LastError     sql.NullString
+	DeliveredAt   sql.NullTime
+	NextAttemptAt sql.NullTime
+}
+
+type WebsubSubscription struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	FeedID    uuid.UUID
+	HubUrl    string
+	TopicUrl  string
+	Secret    string
+	State     string
+	ExpiresAt sql.NullTime
 }
@@ -0,0 +1,233 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.28.0
+// source: numbers.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const getFeedIDByNumber = `-- name: GetFeedIDByNumber :one
+SELECT feed_id FROM feed_numbers WHERE number = $1
+`
+
+func (q *Queries) GetFeedIDByNumber(ctx context.Context, number int64) (uuid.UUID, error) {
+	row := q.db.QueryRowContext(ctx, getFeedIDByNumber, number)
+	var feed_id uuid.UUID
+	err := row.Scan(&feed_id)
+	return feed_id, err
+}
+
+const getNumberedFeedsForUser = `-- name: GetNumberedFeedsForUser :many
+SELECT feed_numbers.number, feeds.name, feeds.url, feeds.last_fetched_at, feed_follows.folder
+FROM feed_follows
+    INNER JOIN feeds ON feeds.id = feed_follows.feed_id
+    INNER JOIN feed_numbers ON feed_numbers.feed_id = feeds.id
+    WHERE feed_follows.user_id = $1
+    ORDER BY feed_numbers.number
+`
+
+type GetNumberedFeedsForUserRow struct {
+	Number        int64
+	Name          string
+	Url           string
+	LastFetchedAt sql.NullTime
+	Folder        sql.NullString
+}
+
+func (q *Queries) GetNumberedFeedsForUser(ctx context.Context, userID uuid.UUID) ([]GetNumberedFeedsForUserRow, error) {
+	rows, err := q.db.QueryContext(ctx, getNumberedFeedsForUser, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetNumberedFeedsForUserRow
+	for rows.Next() {
+		var i GetNumberedFeedsForUserRow
+		if err := rows.Scan(
+			&i.Number,
+			&i.Name,
+			&i.Url,
+			&i.LastFetchedAt,
+			&i.Folder,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getNumberedPostsForUser = `-- name: GetNumberedPostsForUser :many
+SELECT posts.id, post_numbers.number, feed_numbers.number AS feed_number,
+    feeds.name AS feed_name, feeds.url AS feed_url, feed_follows.folder,
+    posts.title, posts.url, posts.description, posts.published_at, posts.created_at,
+    EXISTS (
+        SELECT 1 FROM post_reads
+            WHERE post_reads.user_id = feed_follows.user_id AND post_reads.post_id = posts.id
+    ) AS is_read,
+    EXISTS (
+        SELECT 1 FROM post_stars
+            WHERE post_stars.user_id = feed_follows.user_id AND post_stars.post_id = posts.id
+    ) AS is_starred
+FROM posts
+    INNER JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
+    INNER JOIN feeds ON feeds.id = posts.feed_id
+    INNER JOIN post_numbers ON post_numbers.post_id = posts.id
+    INNER JOIN feed_numbers ON feed_numbers.feed_id = posts.feed_id
+    WHERE feed_follows.user_id = $1
+        AND ($2::uuid IS NULL OR posts.feed_id = $2)
+        AND ($3::varchar IS NULL OR feed_follows.folder = $3)
+        AND ($4::varchar IS NULL OR EXISTS (
+            SELECT 1 FROM post_tags
+                WHERE post_tags.post_id = posts.id
+                    AND post_tags.user_id = feed_follows.user_id
+                    AND post_tags.tag = $4))
+        AND ($5::boolean IS NULL OR $5 = EXISTS (
+            SELECT 1 FROM post_reads
+                WHERE post_reads.user_id = feed_follows.user_id AND post_reads.post_id = posts.id))
+        AND (NOT $6::boolean OR EXISTS (
+            SELECT 1 FROM post_stars
+                WHERE post_stars.user_id = feed_follows.user_id AND post_stars.post_id = posts.id))
+        AND ($7::timestamp IS NULL OR posts.published_at >= $7)
+        AND ($8::timestamp IS NULL OR posts.published_at < $8)
+        AND ($9::bigint IS NULL OR post_numbers.number > $9)
+        AND ($10::bigint IS NULL OR post_numbers.number < $10)
+        AND ($11::bigint IS NULL OR post_numbers.number = $11)
+    ORDER BY CASE WHEN $12::boolean THEN post_numbers.number ELSE -post_numbers.number END
+    LIMIT $13
+`
+
+type GetNumberedPostsForUserParams struct {
+	UserID         uuid.UUID
+	FeedID         uuid.NullUUID
+	Folder         sql.NullString
+	Tag            sql.NullString
+	Read           sql.NullBool
+	StarredOnly    bool
+	PublishedSince sql.NullTime
+	PublishedUntil sql.NullTime
+	SinceNumber    sql.NullInt64
+	MaxNumber      sql.NullInt64
+	Number         sql.NullInt64
+	Ascending      bool
+	RowLimit       int32
+}
+
+type GetNumberedPostsForUserRow struct {
+	ID          uuid.UUID
+	Number      int64
+	FeedNumber  int64
+	FeedName    string
+	FeedUrl     string
+	Folder      sql.NullString
+	Title       string
+	Url         string
+	Description sql.NullString
+	PublishedAt time.Time
+	CreatedAt   time.Time
+	IsRead      bool
+	IsStarred   bool
+}
+
+// Posts with their numbers, for the APIs that use them, filtered the ways
+// those APIs ask for and ordered by number
+func (q *Queries) GetNumberedPostsForUser(ctx context.Context, arg GetNumberedPostsForUserParams) ([]GetNumberedPostsForUserRow, error) {
+	rows, err := q.db.QueryContext(ctx, getNumberedPostsForUser,
+		arg.UserID,
+		arg.FeedID,
+		arg.Folder,
+		arg.Tag,
+		arg.Read,
+		arg.StarredOnly,
+		arg.PublishedSince,
+		arg.PublishedUntil,
+		arg.SinceNumber,
+		arg.MaxNumber,
+		arg.Number,
+		arg.Ascending,
+		arg.RowLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetNumberedPostsForUserRow
+	for rows.Next() {
+		var i GetNumberedPostsForUserRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Number,
+			&i.FeedNumber,
+			&i.FeedName,
+			&i.FeedUrl,
+			&i.Folder,
+			&i.Title,
+			&i.Url,
+			&i.Description,
+			&i.PublishedAt,
+			&i.CreatedAt,
+			&i.IsRead,
+			&i.IsStarred,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getPostIDByNumber = `-- name: GetPostIDByNumber :one
+SELECT post_id FROM post_numbers WHERE number = $1
+`
+
+func (q *Queries) GetPostIDByNumber(ctx context.Context, number int64) (uuid.UUID, error) {
+	row := q.db.QueryRowContext(ctx, getPostIDByNumber, number)
+	var post_id uuid.UUID
+	err := row.Scan(&post_id)
+	return post_id, err
+}
+
+const numberNewFeeds = `-- name: NumberNewFeeds :exec
+INSERT INTO feed_numbers (feed_id)
+SELECT feeds.id FROM feeds
+    WHERE NOT EXISTS (SELECT 1 FROM feed_numbers WHERE feed_numbers.feed_id = feeds.id)
+    ORDER BY feeds.created_at, feeds.id
+ON CONFLICT (feed_id) DO NOTHING
+`
+
+func (q *Queries) NumberNewFeeds(ctx context.Context) error {
+	_, err := q.db.ExecContext(ctx, numberNewFeeds)
+	return err
+}
+
+const numberNewPosts = `-- name: NumberNewPosts :exec
+INSERT INTO post_numbers (post_id)
+SELECT posts.id FROM posts
+    WHERE NOT EXISTS (SELECT 1 FROM post_numbers WHERE post_numbers.post_id = posts.id)
+    ORDER BY posts.created_at, posts.id
+ON CONFLICT (post_id) DO NOTHING
+`
+
+func (q *Queries) NumberNewPosts(ctx context.Context) error {
+	_, err := q.db.ExecContext(ctx, numberNewPosts)
+	return err
+}
@@ -0,0 +1,103 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.28.0
+// source: post_state.sql
+
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const markPostRead = `-- name: MarkPostRead :exec
+INSERT INTO post_reads (id, created_at, user_id, post_id)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4
+)
+ON CONFLICT (user_id, post_id) DO NOTHING
+`
+
+type MarkPostReadParams struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UserID    uuid.UUID
+	PostID    uuid.UUID
+}
+
+func (q *Queries) MarkPostRead(ctx context.Context, arg MarkPostReadParams) error {
+	_, err := q.db.ExecContext(ctx, markPostRead,
+		arg.ID,
+		arg.CreatedAt,
+		arg.UserID,
+		arg.PostID,
+	)
+	return err
+}
+
+const markPostUnread = `-- name: MarkPostUnread :execrows
+DELETE FROM post_reads WHERE user_id = $1 AND post_id = $2
+`
+
+type MarkPostUnreadParams struct {
+	UserID uuid.UUID
+	PostID uuid.UUID
+}
+
+func (q *Queries) MarkPostUnread(ctx context.Context, arg MarkPostUnreadParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, markPostUnread, arg.UserID, arg.PostID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const starPost = `-- name: StarPost :exec
+INSERT INTO post_stars (id, created_at, user_id, post_id)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4
+)
+ON CONFLICT (user_id, post_id) DO NOTHING
+`
+
+type StarPostParams struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UserID    uuid.UUID
+	PostID    uuid.UUID
+}
+
+func (q *Queries) StarPost(ctx context.Context, arg StarPostParams) error {
+	_, err := q.db.ExecContext(ctx, starPost,
+		arg.ID,
+		arg.CreatedAt,
+		arg.UserID,
+		arg.PostID,
+	)
+	return err
+}
+
+const unstarPost = `-- name: UnstarPost :execrows
+DELETE FROM post_stars WHERE user_id = $1 AND post_id = $2
+`
+
+type UnstarPostParams struct {
+	UserID uuid.UUID
+	PostID uuid.UUID
+}
+
+func (q *Queries) UnstarPost(ctx context.Context, arg UnstarPostParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, unstarPost, arg.UserID, arg.PostID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,131 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.28.0
+// source: post_tags.sql
+
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const createPostTag = `-- name: CreatePostTag :exec
+INSERT INTO post_tags (id, created_at, user_id, post_id, tag)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5
+)
+ON CONFLICT (user_id, post_id, tag) DO NOTHING
+`
+
+type CreatePostTagParams struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UserID    uuid.UUID
+	PostID    uuid.UUID
+	Tag       string
+}
+
+func (q *Queries) CreatePostTag(ctx context.Context, arg CreatePostTagParams) error {
+	_, err := q.db.ExecContext(ctx, createPostTag,
+		arg.ID,
+		arg.CreatedAt,
+		arg.UserID,
+		arg.PostID,
+		arg.Tag,
+	)
+	return err
+}
+
+const deletePostTag = `-- name: DeletePostTag :execrows
+DELETE FROM post_tags WHERE user_id = $1 AND post_id = $2 AND tag = $3
+`
+
+type DeletePostTagParams struct {
+	UserID uuid.UUID
+	PostID uuid.UUID
+	Tag    string
+}
+
+func (q *Queries) DeletePostTag(ctx context.Context, arg DeletePostTagParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deletePostTag, arg.UserID, arg.PostID, arg.Tag)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const getTagCountsForUser = `-- name: GetTagCountsForUser :many
+SELECT tag, COUNT(*) AS post_count FROM post_tags
+    WHERE user_id = $1
+    GROUP BY tag
+    ORDER BY tag
+`
+
+type GetTagCountsForUserRow struct {
+	Tag       string
+	PostCount int64
+}
+
+func (q *Queries) GetTagCountsForUser(ctx context.Context, userID uuid.UUID) ([]GetTagCountsForUserRow, error) {
+	rows, err := q.db.QueryContext(ctx, getTagCountsForUser, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetTagCountsForUserRow
+	for rows.Next() {
+		var i GetTagCountsForUserRow
+		if err := rows.Scan(&i.Tag, &i.PostCount); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getTagsForPost = `-- name: GetTagsForPost :many
+SELECT tag FROM post_tags
+    WHERE user_id = $1 AND post_id = $2
+    ORDER BY tag
+`
+
+type GetTagsForPostParams struct {
+	UserID uuid.UUID
+	PostID uuid.UUID
+}
+
+func (q *Queries) GetTagsForPost(ctx context.Context, arg GetTagsForPostParams) ([]string, error) {
+	rows, err := q.db.QueryContext(ctx, getTagsForPost, arg.UserID, arg.PostID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []string
+	for rows.Next() {
+		var tag string
+		if err := rows.Scan(&tag); err != nil {
+			return nil, err
+		}
+		items = append(items, tag)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
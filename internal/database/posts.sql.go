@@ -13,8 +13,130 @@ import (
 	"github.com/google/uuid"
 )
 
+const browsePostsForUser = `-- name: BrowsePostsForUser :many
+SELECT
+    posts.id, posts.created_at, posts.updated_at, posts.title, posts.url, posts.description, posts.published_at, posts.feed_id, posts.description_text,
+    feeds.name AS feed_name,
+    feed_follows.folder,
+    (post_reads.id IS NOT NULL)::boolean AS is_read,
+    (post_stars.id IS NOT NULL)::boolean AS is_starred,
+    (CASE WHEN $1::boolean THEN posts.created_at ELSE posts.published_at END)::timestamp AS sort_time
+FROM posts
+    INNER JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
+    INNER JOIN feeds ON feeds.id = posts.feed_id
+    LEFT JOIN post_reads ON post_reads.post_id = posts.id AND post_reads.user_id = feed_follows.user_id
+    LEFT JOIN post_stars ON post_stars.post_id = posts.id AND post_stars.user_id = feed_follows.user_id
+WHERE feed_follows.user_id = $2
+    AND ($3::varchar IS NULL OR feeds.url = $3)
+    AND ($4::varchar IS NULL OR feed_follows.folder = $4)
+    AND ($5::timestamp IS NULL OR posts.published_at >= $5)
+    AND ($6::timestamp IS NULL OR posts.published_at < $6)
+    AND (NOT $7::boolean OR post_reads.id IS NULL)
+    AND (NOT $8::boolean OR post_stars.id IS NOT NULL)
+    AND ($9::varchar IS NULL OR EXISTS (
+        SELECT 1 FROM post_tags
+        WHERE post_tags.post_id = posts.id
+            AND post_tags.user_id = feed_follows.user_id
+            AND post_tags.tag = $9))
+    -- A LIKE pattern, matched against the title and plain text
+    AND ($10::varchar IS NULL
+        OR posts.title ILIKE $10 ESCAPE '\'
+        OR posts.description_text ILIKE $10 ESCAPE '\')
+    -- Keyset pagination: strictly after the (sort_time, id) of the last row of the previous page
+    AND ($11::timestamp IS NULL
+        OR (CASE WHEN $1::boolean THEN posts.created_at ELSE posts.published_at END, posts.id)
+            < ($11::timestamp, $12::uuid))
+ORDER BY sort_time DESC, posts.id DESC
+LIMIT $13
+`
+
+type BrowsePostsForUserParams struct {
+	SortByFetched bool
+	UserID        uuid.UUID
+	FeedUrl       sql.NullString
+	Folder        sql.NullString
+	Since         sql.NullTime
+	Until         sql.NullTime
+	UnreadOnly    bool
+	StarredOnly   bool
+	Tag           sql.NullString
+	Search        sql.NullString
+	AfterTime     sql.NullTime
+	AfterID       uuid.NullUUID
+	RowLimit      int32
+}
+
+type BrowsePostsForUserRow struct {
+	ID              uuid.UUID
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	Title           string
+	Url             string
+	Description     sql.NullString
+	PublishedAt     time.Time
+	FeedID          uuid.UUID
+	DescriptionText sql.NullString
+	FeedName        string
+	Folder          sql.NullString
+	IsRead          bool
+	IsStarred       bool
+	SortTime        time.Time
+}
+
+func (q *Queries) BrowsePostsForUser(ctx context.Context, arg BrowsePostsForUserParams) ([]BrowsePostsForUserRow, error) {
+	rows, err := q.db.QueryContext(ctx, browsePostsForUser,
+		arg.SortByFetched,
+		arg.UserID,
+		arg.FeedUrl,
+		arg.Folder,
+		arg.Since,
+		arg.Until,
+		arg.UnreadOnly,
+		arg.StarredOnly,
+		arg.Tag,
+		arg.Search,
+		arg.AfterTime,
+		arg.AfterID,
+		arg.RowLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []BrowsePostsForUserRow
+	for rows.Next() {
+		var i BrowsePostsForUserRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Title,
+			&i.Url,
+			&i.Description,
+			&i.PublishedAt,
+			&i.FeedID,
+			&i.DescriptionText,
+			&i.FeedName,
+			&i.Folder,
+			&i.IsRead,
+			&i.IsStarred,
+			&i.SortTime,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const createPost = `-- name: CreatePost :one
-INSERT INTO posts (id, created_at, updated_at, title, url, description, published_at, feed_id)
+INSERT INTO posts (id, created_at, updated_at, title, url, description, published_at, feed_id, description_text)
 VALUES (
     $1,
     $2,
@@ -23,20 +145,23 @@ VALUES (
     $5,
     $6,
     $7,
-    $8
+    $8,
+    $9
 )
-RETURNING id, created_at, updated_at, title, url, description, published_at, feed_id
+ON CONFLICT (url) DO NOTHING
+RETURNING id, created_at, updated_at, title, url, description, published_at, feed_id, description_text
 `
 
 type CreatePostParams struct {
-	ID          uuid.UUID
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
-	Title       string
-	Url         string
-	Description sql.NullString
-	PublishedAt time.Time
-	FeedID      uuid.UUID
+	ID              uuid.UUID
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	Title           string
+	Url             string
+	Description     sql.NullString
+	PublishedAt     time.Time
+	FeedID          uuid.UUID
+	DescriptionText sql.NullString
 }
 
 func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) (Post, error) {
@@ -49,6 +174,7 @@ func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) (Post, e
 		arg.Description,
 		arg.PublishedAt,
 		arg.FeedID,
+		arg.DescriptionText,
 	)
 	var i Post
 	err := row.Scan(
@@ -60,12 +186,89 @@ func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) (Post, e
 		&i.Description,
 		&i.PublishedAt,
 		&i.FeedID,
+		&i.DescriptionText,
+	)
+	return i, err
+}
+
+const getPostByURL = `-- name: GetPostByURL :one
+SELECT id, created_at, updated_at, title, url, description, published_at, feed_id, description_text FROM posts WHERE url = $1
+`
+
+func (q *Queries) GetPostByURL(ctx context.Context, url string) (Post, error) {
+	row := q.db.QueryRowContext(ctx, getPostByURL, url)
+	var i Post
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Title,
+		&i.Url,
+		&i.Description,
+		&i.PublishedAt,
+		&i.FeedID,
+		&i.DescriptionText,
+	)
+	return i, err
+}
+
+const getPostForUser = `-- name: GetPostForUser :one
+SELECT
+    posts.id, posts.created_at, posts.updated_at, posts.title, posts.url, posts.description, posts.published_at, posts.feed_id, posts.description_text,
+    feeds.name AS feed_name,
+    feed_follows.folder,
+    EXISTS (SELECT 1 FROM post_reads WHERE post_reads.post_id = posts.id AND post_reads.user_id = feed_follows.user_id) AS is_read,
+    EXISTS (SELECT 1 FROM post_stars WHERE post_stars.post_id = posts.id AND post_stars.user_id = feed_follows.user_id) AS is_starred
+FROM posts
+    INNER JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
+    INNER JOIN feeds ON feeds.id = posts.feed_id
+WHERE feed_follows.user_id = $1 AND posts.id = $2
+`
+
+type GetPostForUserParams struct {
+	UserID uuid.UUID
+	ID     uuid.UUID
+}
+
+type GetPostForUserRow struct {
+	ID              uuid.UUID
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	Title           string
+	Url             string
+	Description     sql.NullString
+	PublishedAt     time.Time
+	FeedID          uuid.UUID
+	DescriptionText sql.NullString
+	FeedName        string
+	Folder          sql.NullString
+	IsRead          bool
+	IsStarred       bool
+}
+
+func (q *Queries) GetPostForUser(ctx context.Context, arg GetPostForUserParams) (GetPostForUserRow, error) {
+	row := q.db.QueryRowContext(ctx, getPostForUser, arg.UserID, arg.ID)
+	var i GetPostForUserRow
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Title,
+		&i.Url,
+		&i.Description,
+		&i.PublishedAt,
+		&i.FeedID,
+		&i.DescriptionText,
+		&i.FeedName,
+		&i.Folder,
+		&i.IsRead,
+		&i.IsStarred,
 	)
 	return i, err
 }
 
 const getPostsForUser = `-- name: GetPostsForUser :many
-SELECT posts.id, posts.created_at, posts.updated_at, posts.title, posts.url, posts.description, posts.published_at, posts.feed_id, feeds.name AS feed_name FROM posts 
+SELECT posts.id, posts.created_at, posts.updated_at, posts.title, posts.url, posts.description, posts.published_at, posts.feed_id, posts.description_text, feeds.name AS feed_name FROM posts 
     INNER JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
     INNER JOIN feeds ON feeds.id = posts.feed_id
     WHERE feed_follows.user_id = $1
@@ -78,15 +281,16 @@ type GetPostsForUserParams struct {
 }
 
 type GetPostsForUserRow struct {
-	ID          uuid.UUID
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
-	Title       string
-	Url         string
-	Description sql.NullString
-	PublishedAt time.Time
-	FeedID      uuid.UUID
-	FeedName    string
+	ID              uuid.UUID
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	Title           string
+	Url             string
+	Description     sql.NullString
+	PublishedAt     time.Time
+	FeedID          uuid.UUID
+	DescriptionText sql.NullString
+	FeedName        string
 }
 
 func (q *Queries) GetPostsForUser(ctx context.Context, arg GetPostsForUserParams) ([]GetPostsForUserRow, error) {
@@ -107,6 +311,7 @@ func (q *Queries) GetPostsForUser(ctx context.Context, arg GetPostsForUserParams
 			&i.Description,
 			&i.PublishedAt,
 			&i.FeedID,
+			&i.DescriptionText,
 			&i.FeedName,
 		); err != nil {
 			return nil, err
@@ -0,0 +1,100 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.28.0
+
+package database
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/google/uuid"
+)
+
+type Querier interface {
+	BrowsePostsForUser(ctx context.Context, arg BrowsePostsForUserParams) ([]BrowsePostsForUserRow, error)
+	// Puts off a due delivery's next attempt while it's being sent, so nothing
+	// else sending due deliveries sends it too
+	ClaimWebhookDelivery(ctx context.Context, arg ClaimWebhookDeliveryParams) (int64, error)
+	CountFeverItems(ctx context.Context, userID uuid.UUID) (int64, error)
+	CreateAPIToken(ctx context.Context, arg CreateAPITokenParams) (ApiToken, error)
+	CreateFeed(ctx context.Context, arg CreateFeedParams) (Feed, error)
+	CreateFeedFollow(ctx context.Context, arg CreateFeedFollowParams) (CreateFeedFollowRow, error)
+	CreateFeedWarning(ctx context.Context, arg CreateFeedWarningParams) error
+	CreatePost(ctx context.Context, arg CreatePostParams) (Post, error)
+	CreatePostTag(ctx context.Context, arg CreatePostTagParams) error
+	CreateShare(ctx context.Context, arg CreateShareParams) (Share, error)
+	CreateUser(ctx context.Context, arg CreateUserParams) (User, error)
+	CreateUserSession(ctx context.Context, arg CreateUserSessionParams) (UserSession, error)
+	CreateWebSubSubscription(ctx context.Context, arg CreateWebSubSubscriptionParams) (WebsubSubscription, error)
+	CreateWebhook(ctx context.Context, arg CreateWebhookParams) (Webhook, error)
+	CreateWebhookDelivery(ctx context.Context, arg CreateWebhookDeliveryParams) (WebhookDelivery, error)
+	DeleteAPIToken(ctx context.Context, arg DeleteAPITokenParams) (int64, error)
+	DeleteFeedFollow(ctx context.Context, arg DeleteFeedFollowParams) error
+	DeleteFeedWarnings(ctx context.Context, feedID uuid.UUID) error
+	DeletePostTag(ctx context.Context, arg DeletePostTagParams) (int64, error)
+	DeleteShare(ctx context.Context, arg DeleteShareParams) (int64, error)
+	DeleteUserSessions(ctx context.Context, userID uuid.UUID) error
+	DeleteWebSubSubscription(ctx context.Context, id uuid.UUID) error
+	DeleteWebhook(ctx context.Context, arg DeleteWebhookParams) (int64, error)
+	GetAPITokenByFeverKey(ctx context.Context, feverKey sql.NullString) (ApiToken, error)
+	GetAPITokenByHash(ctx context.Context, tokenHash string) (ApiToken, error)
+	GetAPITokensForUser(ctx context.Context, userID uuid.UUID) ([]ApiToken, error)
+	GetDueWebhookDeliveries(ctx context.Context, arg GetDueWebhookDeliveriesParams) ([]GetDueWebhookDeliveriesRow, error)
+	GetFeedByID(ctx context.Context, id uuid.UUID) (Feed, error)
+	GetFeedByURL(ctx context.Context, url string) (Feed, error)
+	GetFeedFollowsForUser(ctx context.Context, userID uuid.UUID) ([]GetFeedFollowsForUserRow, error)
+	GetFeedIDByNumber(ctx context.Context, number int64) (uuid.UUID, error)
+	GetFeedWarningsForUser(ctx context.Context, userID uuid.UUID) ([]GetFeedWarningsForUserRow, error)
+	GetFeeds(ctx context.Context) ([]Feed, error)
+	GetFeedsDue(ctx context.Context, fetchedBefore sql.NullTime) ([]Feed, error)
+	GetFeedsDueForUser(ctx context.Context, arg GetFeedsDueForUserParams) ([]Feed, error)
+	GetFeedsWithHubs(ctx context.Context) ([]Feed, error)
+	GetFoldersForUser(ctx context.Context, userID uuid.UUID) ([]string, error)
+	GetFollowedFeedsWithUnreadCounts(ctx context.Context, userID uuid.UUID) ([]GetFollowedFeedsWithUnreadCountsRow, error)
+	// Feeds being pushed to us don't need polling
+	GetNextFeedToFetch(ctx context.Context, now sql.NullTime) (Feed, error)
+	GetNumberedFeedsForUser(ctx context.Context, userID uuid.UUID) ([]GetNumberedFeedsForUserRow, error)
+	// Posts with their numbers, for the APIs that use them, filtered the ways
+	// those APIs ask for and ordered by number
+	GetNumberedPostsForUser(ctx context.Context, arg GetNumberedPostsForUserParams) ([]GetNumberedPostsForUserRow, error)
+	GetPostByURL(ctx context.Context, url string) (Post, error)
+	GetPostForUser(ctx context.Context, arg GetPostForUserParams) (GetPostForUserRow, error)
+	GetPostIDByNumber(ctx context.Context, number int64) (uuid.UUID, error)
+	GetPostsForUser(ctx context.Context, arg GetPostsForUserParams) ([]GetPostsForUserRow, error)
+	GetShareByHash(ctx context.Context, tokenHash string) (Share, error)
+	GetSharesForUser(ctx context.Context, userID uuid.UUID) ([]Share, error)
+	GetStarredPostNumbers(ctx context.Context, userID uuid.UUID) ([]int64, error)
+	GetTagCountsForUser(ctx context.Context, userID uuid.UUID) ([]GetTagCountsForUserRow, error)
+	GetTagsForPost(ctx context.Context, arg GetTagsForPostParams) ([]string, error)
+	GetUnreadPostNumbers(ctx context.Context, userID uuid.UUID) ([]int64, error)
+	GetUserByID(ctx context.Context, id uuid.UUID) (User, error)
+	GetUserByName(ctx context.Context, name string) (User, error)
+	GetUserBySession(ctx context.Context, tokenHash string) (User, error)
+	GetUsers(ctx context.Context) ([]User, error)
+	GetWebSubSubscription(ctx context.Context, id uuid.UUID) (WebsubSubscription, error)
+	GetWebSubSubscriptionForFeed(ctx context.Context, feedID uuid.UUID) (WebsubSubscription, error)
+	GetWebhookByName(ctx context.Context, arg GetWebhookByNameParams) (Webhook, error)
+	// The newest first, optionally for just one webhook
+	GetWebhookDeliveriesForUser(ctx context.Context, arg GetWebhookDeliveriesForUserParams) ([]GetWebhookDeliveriesForUserRow, error)
+	// Those of users following the feed, whose filters let its posts through
+	GetWebhooksForFeed(ctx context.Context, feedID uuid.UUID) ([]Webhook, error)
+	GetWebhooksForUser(ctx context.Context, userID uuid.UUID) ([]Webhook, error)
+	MarkAPITokenUsed(ctx context.Context, arg MarkAPITokenUsedParams) error
+	MarkFeedFetched(ctx context.Context, arg MarkFeedFetchedParams) error
+	MarkPostRead(ctx context.Context, arg MarkPostReadParams) error
+	MarkPostUnread(ctx context.Context, arg MarkPostUnreadParams) (int64, error)
+	NumberNewFeeds(ctx context.Context) error
+	NumberNewPosts(ctx context.Context) error
+	ResetUsers(ctx context.Context) error
+	SetFeedFollowFolder(ctx context.Context, arg SetFeedFollowFolderParams) (int64, error)
+	SetFeedHub(ctx context.Context, arg SetFeedHubParams) error
+	SetUserPassword(ctx context.Context, arg SetUserPasswordParams) error
+	StarPost(ctx context.Context, arg StarPostParams) error
+	UnstarPost(ctx context.Context, arg UnstarPostParams) (int64, error)
+	UpdateFeedURL(ctx context.Context, arg UpdateFeedURLParams) error
+	UpdateWebSubSubscription(ctx context.Context, arg UpdateWebSubSubscriptionParams) error
+	UpdateWebhookDelivery(ctx context.Context, arg UpdateWebhookDeliveryParams) error
+}
+
+var _ Querier = (*Queries)(nil)
@@ -0,0 +1,140 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.28.0
+// source: shares.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const createShare = `-- name: CreateShare :one
+INSERT INTO shares (id, created_at, user_id, name, token_hash, folder, tag, starred)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7,
+    $8
+)
+RETURNING id, created_at, user_id, name, token_hash, folder, tag, starred
+`
+
+type CreateShareParams struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UserID    uuid.UUID
+	Name      string
+	TokenHash string
+	Folder    sql.NullString
+	Tag       sql.NullString
+	Starred   bool
+}
+
+func (q *Queries) CreateShare(ctx context.Context, arg CreateShareParams) (Share, error) {
+	row := q.db.QueryRowContext(ctx, createShare,
+		arg.ID,
+		arg.CreatedAt,
+		arg.UserID,
+		arg.Name,
+		arg.TokenHash,
+		arg.Folder,
+		arg.Tag,
+		arg.Starred,
+	)
+	var i Share
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UserID,
+		&i.Name,
+		&i.TokenHash,
+		&i.Folder,
+		&i.Tag,
+		&i.Starred,
+	)
+	return i, err
+}
+
+const deleteShare = `-- name: DeleteShare :execrows
+DELETE FROM shares WHERE user_id = $1 AND name = $2
+`
+
+type DeleteShareParams struct {
+	UserID uuid.UUID
+	Name   string
+}
+
+func (q *Queries) DeleteShare(ctx context.Context, arg DeleteShareParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteShare, arg.UserID, arg.Name)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const getShareByHash = `-- name: GetShareByHash :one
+SELECT id, created_at, user_id, name, token_hash, folder, tag, starred FROM shares WHERE token_hash = $1
+`
+
+func (q *Queries) GetShareByHash(ctx context.Context, tokenHash string) (Share, error) {
+	row := q.db.QueryRowContext(ctx, getShareByHash, tokenHash)
+	var i Share
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UserID,
+		&i.Name,
+		&i.TokenHash,
+		&i.Folder,
+		&i.Tag,
+		&i.Starred,
+	)
+	return i, err
+}
+
+const getSharesForUser = `-- name: GetSharesForUser :many
+SELECT id, created_at, user_id, name, token_hash, folder, tag, starred FROM shares
+    WHERE user_id = $1
+    ORDER BY name
+`
+
+func (q *Queries) GetSharesForUser(ctx context.Context, userID uuid.UUID) ([]Share, error) {
+	rows, err := q.db.QueryContext(ctx, getSharesForUser, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Share
+	for rows.Next() {
+		var i Share
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UserID,
+			&i.Name,
+			&i.TokenHash,
+			&i.Folder,
+			&i.Tag,
+			&i.Starred,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
@@ -0,0 +1,110 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"strings"
+	"time"
+)
+
+// SQLite support for the sqlc-generated Queries, which are written for
+// Postgres. Most queries run on SQLite as they are, since the driver binds
+// $N placeholders by position. The rest have SQLite versions, matched up with
+// the originals by their "-- name:" line.
+type sqliteDB struct {
+	db        DBTX
+	overrides map[string]string
+}
+
+// Creates Queries for a SQLite database, using the SQLite versions of any
+// queries found in .sql files in overrides
+func NewSQLite(db DBTX, overrides fs.FS) (*Queries, error) {
+	files, err := fs.Glob(overrides, "*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	sqlite := &sqliteDB{db: db, overrides: make(map[string]string)}
+	for _, file := range files {
+		contents, err := fs.ReadFile(overrides, file)
+		if err != nil {
+			return nil, err
+		}
+
+		for name, query := range splitQueries(string(contents)) {
+			if _, exists := sqlite.overrides[name]; exists {
+				return nil, fmt.Errorf("SQLite query '%s' defined twice", name)
+			}
+			sqlite.overrides[name] = query
+		}
+	}
+
+	return New(sqlite), nil
+}
+
+// Splits a sqlc query file into its queries, keyed by name
+func splitQueries(contents string) map[string]string {
+	queries := make(map[string]string)
+	parts := strings.Split(contents, "-- name: ")
+	for _, part := range parts[1:] {
+		name := strings.Fields(part)[0]
+		queries[name] = "-- name: " + strings.TrimSuffix(strings.TrimSpace(part), ";")
+	}
+	return queries
+}
+
+func queryName(query string) string {
+	rest, ok := strings.CutPrefix(query, "-- name: ")
+	if !ok {
+		return ""
+	}
+	fields := strings.Fields(rest)
+	if len(fields) == 0 {
+		return ""
+	}
+	return fields[0]
+}
+
+func (s *sqliteDB) rewrite(query string, args []interface{}) (string, []interface{}) {
+	if override, ok := s.overrides[queryName(query)]; ok {
+		query = override
+	}
+
+	// SQLite stores times as text, so they only compare and sort correctly
+	// when they're all in the same zone
+	converted := make([]interface{}, len(args))
+	for i, arg := range args {
+		switch value := arg.(type) {
+		case time.Time:
+			converted[i] = value.UTC()
+		case sql.NullTime:
+			converted[i] = sql.NullTime{Time: value.Time.UTC(), Valid: value.Valid}
+		default:
+			converted[i] = arg
+		}
+	}
+
+	return query, converted
+}
+
+func (s *sqliteDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	query, args = s.rewrite(query, args)
+	return s.db.ExecContext(ctx, query, args...)
+}
+
+func (s *sqliteDB) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	query, _ = s.rewrite(query, nil)
+	return s.db.PrepareContext(ctx, query)
+}
+
+func (s *sqliteDB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	query, args = s.rewrite(query, args)
+	return s.db.QueryContext(ctx, query, args...)
+}
+
+func (s *sqliteDB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	query, args = s.rewrite(query, args)
+	return s.db.QueryRowContext(ctx, query, args...)
+}
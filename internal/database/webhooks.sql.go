@@ -0,0 +1,432 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.28.0
+// source: webhooks.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const claimWebhookDelivery = `-- name: ClaimWebhookDelivery :execrows
+UPDATE webhook_deliveries
+    SET next_attempt_at = $1
+    WHERE id = $2 AND next_attempt_at <= $3
+`
+
+type ClaimWebhookDeliveryParams struct {
+	ClaimedUntil sql.NullTime
+	ID           uuid.UUID
+	Now          sql.NullTime
+}
+
+// Puts off a due delivery's next attempt while it's being sent, so nothing
+// else sending due deliveries sends it too
+func (q *Queries) ClaimWebhookDelivery(ctx context.Context, arg ClaimWebhookDeliveryParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, claimWebhookDelivery, arg.ClaimedUntil, arg.ID, arg.Now)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const createWebhook = `-- name: CreateWebhook :one
+INSERT INTO webhooks (id, created_at, user_id, name, url, feed_id, folder, match, template, secret)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7,
+    $8,
+    $9,
+    $10
+)
+RETURNING id, created_at, user_id, name, url, feed_id, folder, match, template, secret
+`
+
+type CreateWebhookParams struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UserID    uuid.UUID
+	Name      string
+	Url       string
+	FeedID    uuid.NullUUID
+	Folder    sql.NullString
+	Match     sql.NullString
+	Template  sql.NullString
+	Secret    string
+}
+
+func (q *Queries) CreateWebhook(ctx context.Context, arg CreateWebhookParams) (Webhook, error) {
+	row := q.db.QueryRowContext(ctx, createWebhook,
+		arg.ID,
+		arg.CreatedAt,
+		arg.UserID,
+		arg.Name,
+		arg.Url,
+		arg.FeedID,
+		arg.Folder,
+		arg.Match,
+		arg.Template,
+		arg.Secret,
+	)
+	var i Webhook
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UserID,
+		&i.Name,
+		&i.Url,
+		&i.FeedID,
+		&i.Folder,
+		&i.Match,
+		&i.Template,
+		&i.Secret,
+	)
+	return i, err
+}
+
+const createWebhookDelivery = `-- name: CreateWebhookDelivery :one
+INSERT INTO webhook_deliveries (id, created_at, webhook_id, post_id, payload, attempts, next_attempt_at)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7
+)
+RETURNING id, created_at, webhook_id, post_id, payload, attempts, last_status, last_error, delivered_at, next_attempt_at
+`
+
+type CreateWebhookDeliveryParams struct {
+	ID            uuid.UUID
+	CreatedAt     time.Time
+	WebhookID     uuid.UUID
+	PostID        uuid.NullUUID
+	Payload       string
+	Attempts      int32
+	NextAttemptAt sql.NullTime
+}
+
+func (q *Queries) CreateWebhookDelivery(ctx context.Context, arg CreateWebhookDeliveryParams) (WebhookDelivery, error) {
+	row := q.db.QueryRowContext(ctx, createWebhookDelivery,
+		arg.ID,
+		arg.CreatedAt,
+		arg.WebhookID,
+		arg.PostID,
+		arg.Payload,
+		arg.Attempts,
+		arg.NextAttemptAt,
+	)
+	var i WebhookDelivery
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.WebhookID,
+		&i.PostID,
+		&i.Payload,
+		&i.Attempts,
+		&i.LastStatus,
+		&i.LastError,
+		&i.DeliveredAt,
+		&i.NextAttemptAt,
+	)
+	return i, err
+}
+
+const deleteWebhook = `-- name: DeleteWebhook :execrows
+DELETE FROM webhooks WHERE user_id = $1 AND name = $2
+`
+
+type DeleteWebhookParams struct {
+	UserID uuid.UUID
+	Name   string
+}
+
+func (q *Queries) DeleteWebhook(ctx context.Context, arg DeleteWebhookParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteWebhook, arg.UserID, arg.Name)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const getDueWebhookDeliveries = `-- name: GetDueWebhookDeliveries :many
+SELECT webhook_deliveries.id, webhook_deliveries.created_at, webhook_deliveries.webhook_id, webhook_deliveries.post_id, webhook_deliveries.payload, webhook_deliveries.attempts, webhook_deliveries.last_status, webhook_deliveries.last_error, webhook_deliveries.delivered_at, webhook_deliveries.next_attempt_at, webhooks.name AS webhook_name, webhooks.url, webhooks.secret
+FROM webhook_deliveries
+    INNER JOIN webhooks ON webhooks.id = webhook_deliveries.webhook_id
+    WHERE webhook_deliveries.next_attempt_at <= $1
+    ORDER BY webhook_deliveries.next_attempt_at
+    LIMIT $2
+`
+
+type GetDueWebhookDeliveriesParams struct {
+	Now      sql.NullTime
+	RowLimit int32
+}
+
+type GetDueWebhookDeliveriesRow struct {
+	ID            uuid.UUID
+	CreatedAt     time.Time
+	WebhookID     uuid.UUID
+	PostID        uuid.NullUUID
+	Payload       string
+	Attempts      int32
+	LastStatus    sql.NullInt32
+	LastError     sql.NullString
+	DeliveredAt   sql.NullTime
+	NextAttemptAt sql.NullTime
+	WebhookName   string
+	Url           string
+	Secret        string
+}
+
+func (q *Queries) GetDueWebhookDeliveries(ctx context.Context, arg GetDueWebhookDeliveriesParams) ([]GetDueWebhookDeliveriesRow, error) {
+	rows, err := q.db.QueryContext(ctx, getDueWebhookDeliveries, arg.Now, arg.RowLimit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetDueWebhookDeliveriesRow
+	for rows.Next() {
+		var i GetDueWebhookDeliveriesRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.WebhookID,
+			&i.PostID,
+			&i.Payload,
+			&i.Attempts,
+			&i.LastStatus,
+			&i.LastError,
+			&i.DeliveredAt,
+			&i.NextAttemptAt,
+			&i.WebhookName,
+			&i.Url,
+			&i.Secret,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getWebhookByName = `-- name: GetWebhookByName :one
+SELECT id, created_at, user_id, name, url, feed_id, folder, match, template, secret FROM webhooks WHERE user_id = $1 AND name = $2
+`
+
+type GetWebhookByNameParams struct {
+	UserID uuid.UUID
+	Name   string
+}
+
+func (q *Queries) GetWebhookByName(ctx context.Context, arg GetWebhookByNameParams) (Webhook, error) {
+	row := q.db.QueryRowContext(ctx, getWebhookByName, arg.UserID, arg.Name)
+	var i Webhook
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UserID,
+		&i.Name,
+		&i.Url,
+		&i.FeedID,
+		&i.Folder,
+		&i.Match,
+		&i.Template,
+		&i.Secret,
+	)
+	return i, err
+}
+
+const getWebhookDeliveriesForUser = `-- name: GetWebhookDeliveriesForUser :many
+SELECT webhook_deliveries.id, webhook_deliveries.created_at, webhooks.name AS webhook_name,
+    posts.title AS post_title, webhook_deliveries.attempts, webhook_deliveries.last_status,
+    webhook_deliveries.last_error, webhook_deliveries.delivered_at, webhook_deliveries.next_attempt_at
+FROM webhook_deliveries
+    INNER JOIN webhooks ON webhooks.id = webhook_deliveries.webhook_id
+    LEFT JOIN posts ON posts.id = webhook_deliveries.post_id
+    WHERE webhooks.user_id = $1
+        AND (webhooks.name = $2 OR $2 = '')
+    ORDER BY webhook_deliveries.created_at DESC
+    LIMIT $3
+`
+
+type GetWebhookDeliveriesForUserParams struct {
+	UserID      uuid.UUID
+	WebhookName string
+	RowLimit    int32
+}
+
+type GetWebhookDeliveriesForUserRow struct {
+	ID            uuid.UUID
+	CreatedAt     time.Time
+	WebhookName   string
+	PostTitle     sql.NullString
+	Attempts      int32
+	LastStatus    sql.NullInt32
+	LastError     sql.NullString
+	DeliveredAt   sql.NullTime
+	NextAttemptAt sql.NullTime
+}
+
+// The newest first, optionally for just one webhook
+func (q *Queries) GetWebhookDeliveriesForUser(ctx context.Context, arg GetWebhookDeliveriesForUserParams) ([]GetWebhookDeliveriesForUserRow, error) {
+	rows, err := q.db.QueryContext(ctx, getWebhookDeliveriesForUser, arg.UserID, arg.WebhookName, arg.RowLimit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetWebhookDeliveriesForUserRow
+	for rows.Next() {
+		var i GetWebhookDeliveriesForUserRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.WebhookName,
+			&i.PostTitle,
+			&i.Attempts,
+			&i.LastStatus,
+			&i.LastError,
+			&i.DeliveredAt,
+			&i.NextAttemptAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getWebhooksForFeed = `-- name: GetWebhooksForFeed :many
+SELECT webhooks.id, webhooks.created_at, webhooks.user_id, webhooks.name, webhooks.url, webhooks.feed_id, webhooks.folder, webhooks.match, webhooks.template, webhooks.secret FROM webhooks
+    INNER JOIN feed_follows ON feed_follows.user_id = webhooks.user_id
+    WHERE feed_follows.feed_id = $1
+        AND (webhooks.feed_id IS NULL OR webhooks.feed_id = $1)
+        AND (webhooks.folder IS NULL OR webhooks.folder = feed_follows.folder)
+    ORDER BY webhooks.created_at
+`
+
+// Those of users following the feed, whose filters let its posts through
+func (q *Queries) GetWebhooksForFeed(ctx context.Context, feedID uuid.UUID) ([]Webhook, error) {
+	rows, err := q.db.QueryContext(ctx, getWebhooksForFeed, feedID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Webhook
+	for rows.Next() {
+		var i Webhook
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UserID,
+			&i.Name,
+			&i.Url,
+			&i.FeedID,
+			&i.Folder,
+			&i.Match,
+			&i.Template,
+			&i.Secret,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getWebhooksForUser = `-- name: GetWebhooksForUser :many
+SELECT id, created_at, user_id, name, url, feed_id, folder, match, template, secret FROM webhooks
+    WHERE user_id = $1
+    ORDER BY name
+`
+
+func (q *Queries) GetWebhooksForUser(ctx context.Context, userID uuid.UUID) ([]Webhook, error) {
+	rows, err := q.db.QueryContext(ctx, getWebhooksForUser, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Webhook
+	for rows.Next() {
+		var i Webhook
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UserID,
+			&i.Name,
+			&i.Url,
+			&i.FeedID,
+			&i.Folder,
+			&i.Match,
+			&i.Template,
+			&i.Secret,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const updateWebhookDelivery = `-- name: UpdateWebhookDelivery :exec
+UPDATE webhook_deliveries
+    SET attempts = $2, last_status = $3, last_error = $4, delivered_at = $5, next_attempt_at = $6
+    WHERE id = $1
+`
+
+type UpdateWebhookDeliveryParams struct {
+	ID            uuid.UUID
+	Attempts      int32
+	LastStatus    sql.NullInt32
+	LastError     sql.NullString
+	DeliveredAt   sql.NullTime
+	NextAttemptAt sql.NullTime
+}
+
+func (q *Queries) UpdateWebhookDelivery(ctx context.Context, arg UpdateWebhookDeliveryParams) error {
+	_, err := q.db.ExecContext(ctx, updateWebhookDelivery,
+		arg.ID,
+		arg.Attempts,
+		arg.LastStatus,
+		arg.LastError,
+		arg.DeliveredAt,
+		arg.NextAttemptAt,
+	)
+	return err
+}
@@ -0,0 +1,200 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.28.0
+// source: websub.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const createWebSubSubscription = `-- name: CreateWebSubSubscription :one
+INSERT INTO websub_subscriptions (id, created_at, updated_at, feed_id, hub_url, topic_url, secret, state)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7,
+    $8
+)
+RETURNING id, created_at, updated_at, feed_id, hub_url, topic_url, secret, state, expires_at
+`
+
+type CreateWebSubSubscriptionParams struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	FeedID    uuid.UUID
+	HubUrl    string
+	TopicUrl  string
+	Secret    string
+	State     string
+}
+
+func (q *Queries) CreateWebSubSubscription(ctx context.Context, arg CreateWebSubSubscriptionParams) (WebsubSubscription, error) {
+	row := q.db.QueryRowContext(ctx, createWebSubSubscription,
+		arg.ID,
+		arg.CreatedAt,
+		arg.UpdatedAt,
+		arg.FeedID,
+		arg.HubUrl,
+		arg.TopicUrl,
+		arg.Secret,
+		arg.State,
+	)
+	var i WebsubSubscription
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.FeedID,
+		&i.HubUrl,
+		&i.TopicUrl,
+		&i.Secret,
+		&i.State,
+		&i.ExpiresAt,
+	)
+	return i, err
+}
+
+const deleteWebSubSubscription = `-- name: DeleteWebSubSubscription :exec
+DELETE FROM websub_subscriptions WHERE id = $1
+`
+
+func (q *Queries) DeleteWebSubSubscription(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteWebSubSubscription, id)
+	return err
+}
+
+const getFeedsWithHubs = `-- name: GetFeedsWithHubs :many
+SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at, hub_url, topic_url FROM feeds
+    WHERE hub_url IS NOT NULL AND topic_url IS NOT NULL
+    ORDER BY created_at
+`
+
+func (q *Queries) GetFeedsWithHubs(ctx context.Context) ([]Feed, error) {
+	rows, err := q.db.QueryContext(ctx, getFeedsWithHubs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Feed
+	for rows.Next() {
+		var i Feed
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Name,
+			&i.Url,
+			&i.UserID,
+			&i.LastFetchedAt,
+			&i.HubUrl,
+			&i.TopicUrl,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getWebSubSubscription = `-- name: GetWebSubSubscription :one
+SELECT id, created_at, updated_at, feed_id, hub_url, topic_url, secret, state, expires_at FROM websub_subscriptions WHERE id = $1
+`
+
+func (q *Queries) GetWebSubSubscription(ctx context.Context, id uuid.UUID) (WebsubSubscription, error) {
+	row := q.db.QueryRowContext(ctx, getWebSubSubscription, id)
+	var i WebsubSubscription
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.FeedID,
+		&i.HubUrl,
+		&i.TopicUrl,
+		&i.Secret,
+		&i.State,
+		&i.ExpiresAt,
+	)
+	return i, err
+}
+
+const getWebSubSubscriptionForFeed = `-- name: GetWebSubSubscriptionForFeed :one
+SELECT id, created_at, updated_at, feed_id, hub_url, topic_url, secret, state, expires_at FROM websub_subscriptions WHERE feed_id = $1
+`
+
+func (q *Queries) GetWebSubSubscriptionForFeed(ctx context.Context, feedID uuid.UUID) (WebsubSubscription, error) {
+	row := q.db.QueryRowContext(ctx, getWebSubSubscriptionForFeed, feedID)
+	var i WebsubSubscription
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.FeedID,
+		&i.HubUrl,
+		&i.TopicUrl,
+		&i.Secret,
+		&i.State,
+		&i.ExpiresAt,
+	)
+	return i, err
+}
+
+const setFeedHub = `-- name: SetFeedHub :exec
+UPDATE feeds
+    SET hub_url = $2, topic_url = $3
+    WHERE id = $1
+`
+
+type SetFeedHubParams struct {
+	ID       uuid.UUID
+	HubUrl   sql.NullString
+	TopicUrl sql.NullString
+}
+
+func (q *Queries) SetFeedHub(ctx context.Context, arg SetFeedHubParams) error {
+	_, err := q.db.ExecContext(ctx, setFeedHub, arg.ID, arg.HubUrl, arg.TopicUrl)
+	return err
+}
+
+const updateWebSubSubscription = `-- name: UpdateWebSubSubscription :exec
+UPDATE websub_subscriptions
+    SET hub_url = $2, topic_url = $3, state = $4, expires_at = $5, updated_at = $6
+    WHERE id = $1
+`
+
+type UpdateWebSubSubscriptionParams struct {
+	ID        uuid.UUID
+	HubUrl    string
+	TopicUrl  string
+	State     string
+	ExpiresAt sql.NullTime
+	UpdatedAt time.Time
+}
+
+func (q *Queries) UpdateWebSubSubscription(ctx context.Context, arg UpdateWebSubSubscriptionParams) error {
+	_, err := q.db.ExecContext(ctx, updateWebSubSubscription,
+		arg.ID,
+		arg.HubUrl,
+		arg.TopicUrl,
+		arg.State,
+		arg.ExpiresAt,
+		arg.UpdatedAt,
+	)
+	return err
+}
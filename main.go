@@ -2,18 +2,27 @@ package main
 
 import (
 	"database/sql"
+	"errors"
+	"flag"
+	"io"
 	"log"
 	"os"
+	"time"
 
 	"github.com/venzy/gator/internal/config"
 	"github.com/venzy/gator/internal/database"
-
-	_ "github.com/lib/pq"
 )
 
+// Set when building a release, with -ldflags "-X main.version=v1.2.3"
+var version = "dev"
+
 type state struct {
-	db *database.Queries
-	cfg *config.Config
+	// Queries for Postgres or SQLite, or an in-memory store in tests
+	db      database.Querier
+	conn    *sql.DB
+	dialect string
+	cfg     *config.Config
+	output  outputFormat
 }
 
 func main() {
@@ -26,36 +35,309 @@ func main() {
 	// Setup for CLI operation
 	appState := state{db: nil, cfg: &cfg}
 	cliCommands := NewCommands()
-	cliCommands.register("login", handlerLogin)
-	cliCommands.register("register", handlerRegister)
-	cliCommands.register("reset", handlerReset)
-	cliCommands.register("users", handlerUsers)
-	cliCommands.register("agg", handlerAgg)
-	cliCommands.register("addfeed", withLoggedInUser(handlerAddFeed))
-	cliCommands.register("feeds", handlerFeeds)
-	cliCommands.register("follow", withLoggedInUser(handlerFollow))
-	cliCommands.register("following", withLoggedInUser(handlerFollowing))
-	cliCommands.register("unfollow", withLoggedInUser(handlerUnfollow))
-	cliCommands.register("browse", withLoggedInUser(handlerBrowse))
+	registerCommands(cliCommands)
+
+	// Global options come before the command
+	globalFlags := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+	globalFlags.SetOutput(io.Discard)
+	outputSpec := globalFlags.String("output", outputTable, "listing format: table, json, jsonl, csv or a Go template")
+	globalFlags.StringVar(outputSpec, "o", outputTable, "shorthand for --output")
+	if err := globalFlags.Parse(os.Args[1:]); errors.Is(err, flag.ErrHelp) {
+		cliCommands.printUsage(os.Stdout)
+		return
+	} else if err != nil {
+		log.Fatalf("%s: %s", os.Args[0], err)
+	}
+
+	appState.output, err = parseOutputFormat(*outputSpec)
+	if err != nil {
+		log.Fatalf("%s: %s", os.Args[0], err)
+	}
 
 	// Get command line args
-	if len(os.Args) < 2 {
-		log.Fatalf("%s: CLI command required as first argument", os.Args[0])
+	if globalFlags.NArg() < 1 {
+		cliCommands.printUsage(os.Stderr)
+		os.Exit(1)
 	}
 
-	cmd := command{os.Args[1], os.Args[2:]}
+	cmd := command{name: globalFlags.Arg(0), args: globalFlags.Args()[1:]}
 
 	// Open DB connection
-	db, err := sql.Open("postgres", cfg.DbUrl)
+	db, dbQueries, dialect, err := openDatabase(cfg.DbUrl)
 	if err != nil {
 		log.Fatalf("Cannot connect to database: %s", err)
 	}
+	defer db.Close()
 
-	dbQueries := database.New(db)
 	appState.db = dbQueries
+	appState.conn = db
+	appState.dialect = dialect
 
 	// Run command
 	if err := cliCommands.run(&appState, cmd); err != nil {
 		log.Fatalf("ERROR: %s", err)
 	}
-}
\ No newline at end of file
+}
+
+func registerCommands(cliCommands *commands) {
+	postURLs := []argSpec{{name: "post-url", usage: "URL of a post, as shown by browse", variadic: true}}
+
+	cliCommands.register(commandSpec{
+		name:            "help",
+		description:     "Show the list of commands, or details of one command",
+		args:            []argSpec{{name: "command", usage: "command to show details of", optional: true, complete: cliCommands.completeCommandNames}},
+		handler:         cliCommands.handlerHelp,
+		skipSchemaCheck: true,
+	})
+	cliCommands.register(commandSpec{
+		name:            "completion",
+		description:     "Print a shell completion script",
+		args:            []argSpec{{name: "shell", usage: "bash, zsh or fish", complete: completeValues("bash", "zsh", "fish")}},
+		handler:         cliCommands.handlerCompletion,
+		skipSchemaCheck: true,
+	})
+	cliCommands.register(commandSpec{
+		name:        "__complete",
+		description: "Print completions for a partial command line, for the completion scripts",
+		args:        []argSpec{{name: "word", usage: "words of the command line so far", optional: true, variadic: true}},
+		handler:     cliCommands.handlerComplete,
+		hidden:      true,
+		// Completion mustn't stop to ask questions - queries just fail quietly
+		skipSchemaCheck: true,
+	})
+	cliCommands.register(commandSpec{
+		name:        "migrate",
+		description: "Manage the database schema",
+		args: []argSpec{{
+			name:     "action",
+			usage:    "up (apply all new migrations), down (roll back the last one), status or version",
+			complete: completeValues("up", "down", "status", "version"),
+		}},
+		handler:         handlerMigrate,
+		skipSchemaCheck: true,
+	})
+	cliCommands.register(commandSpec{
+		name:        "login",
+		description: "Switch to an existing user, asking for their password if they have one",
+		args:        []argSpec{{name: "username", usage: "name of a registered user", complete: completeUsernames}},
+		handler:     handlerLogin,
+	})
+	cliCommands.register(commandSpec{
+		name:        "register",
+		description: "Create a new user and log in as them",
+		args:        []argSpec{{name: "username", usage: "name for the new user"}},
+		flags: []flagSpec{
+			{name: "password", usage: "protect the user with a password, prompted for", defValue: false},
+		},
+		handler: handlerRegister,
+	})
+	cliCommands.register(commandSpec{
+		name:        "reset",
+		description: "Delete all users, along with their feeds, follows and posts",
+		handler:     handlerReset,
+	})
+	cliCommands.register(commandSpec{
+		name:        "users",
+		description: "List all users",
+		handler:     handlerUsers,
+	})
+	cliCommands.register(commandSpec{
+		name:        "passwd",
+		description: "Set or change the logged-in user's password, logging out their other sessions",
+		flags: []flagSpec{
+			{name: "remove", usage: "remove the password instead", defValue: false},
+		},
+		handler: withLoggedInUser(handlerPasswd),
+	})
+	cliCommands.register(commandSpec{
+		name:        "token",
+		description: "Manage the logged-in user's tokens for the API and web reader",
+		args: []argSpec{
+			{name: "action", usage: "create, list or revoke", complete: completeValues("create", "list", "revoke")},
+			{name: "name", usage: "name of the token, to tell it apart from the user's others", optional: true},
+		},
+		flags: []flagSpec{
+			{name: "scope", usage: "what a new token may do: 'read' only, or 'write' too", defValue: scopeRead, complete: completeValues(scopeRead, scopeWrite)},
+		},
+		handler: withLoggedInUser(handlerToken),
+	})
+	cliCommands.register(commandSpec{
+		name:        "share",
+		description: "Manage the logged-in user's shared RSS and Atom feeds of their posts",
+		args: []argSpec{
+			{name: "action", usage: "create, list or revoke", complete: completeValues("create", "list", "revoke")},
+			{name: "name", usage: "name of the share, to tell it apart from the user's others", optional: true},
+		},
+		flags: []flagSpec{
+			{name: "folder", usage: "only share posts from feeds in this folder", defValue: "", complete: completeFolders},
+			{name: "tag", usage: "only share posts with this tag", defValue: "", complete: completeTags},
+			{name: "starred", usage: "only share starred posts", defValue: false},
+		},
+		handler: withLoggedInUser(handlerShare),
+	})
+	cliCommands.register(commandSpec{
+		name:        "webhooks",
+		aliases:     []string{"webhook"},
+		description: "Manage webhooks posting the logged-in user's new posts as JSON to other services",
+		args: []argSpec{
+			{name: "action", usage: "add, list, remove, test or log", complete: completeValues("add", "list", "remove", "test", "log")},
+			{name: "name", usage: "name of the webhook", optional: true},
+			{name: "url", usage: "URL to post to, for add", optional: true},
+		},
+		flags: []flagSpec{
+			{name: "feed", usage: "only post new posts from this feed", defValue: "", complete: completeFollowedFeedURLs},
+			{name: "folder", usage: "only post new posts from feeds in this folder", defValue: "", complete: completeFolders},
+			{name: "match", usage: "only post new posts with this text in their title or content, ignoring case", defValue: ""},
+			{name: "template", usage: "Go template for the JSON payload, e.g. '{\"text\": {{json .Post.Title}}}'", defValue: ""},
+			{name: "secret", usage: "secret to sign payloads with (default: a random one, shown once)", defValue: ""},
+			{name: "limit", usage: "most deliveries to show, for log", defValue: 20},
+		},
+		handler: withLoggedInUser(handlerWebhooks),
+	})
+	cliCommands.register(commandSpec{
+		name:        "agg",
+		description: "Poll added feeds from all users until stopped, fetching one feed per period",
+		args:        []argSpec{{name: "period", usage: "time between requests - a duration string like 1s, 1m, 1h5m3s etc"}},
+		flags: []flagSpec{
+			{name: "pidfile", usage: "file to record agg's process ID in, locked so only one agg runs at a time (default ~/.gator-agg.pid)", defValue: ""},
+		},
+		handler: handlerAgg,
+	})
+	cliCommands.register(commandSpec{
+		name:        "refresh",
+		description: "Fetch feeds that are due once, now, and show what's new",
+		args:        []argSpec{{name: "feed-url", usage: "feeds to fetch whether due or not (default: all due feeds)", optional: true, variadic: true, complete: completeFeedURLs}},
+		flags: []flagSpec{
+			{name: "mine", usage: "only fetch feeds the logged-in user follows", defValue: false},
+			{name: "older-than", usage: "feeds are due if not fetched for this long", defValue: 10 * time.Minute},
+			{name: "jobs", usage: "how many feeds to fetch at once", defValue: 4},
+		},
+		handler: handlerRefresh,
+	})
+	cliCommands.register(commandSpec{
+		name:        "serve",
+		description: "Serve the JSON API, web reader, Fever and Google Reader APIs, shared feeds and WebSub callbacks until stopped",
+		flags: []flagSpec{
+			{name: "addr", usage: "address to listen on, host:port", defValue: "localhost:8080"},
+			{name: "public-url", usage: "URL where WebSub hubs can reach this server, to have feeds with hubs pushed rather than polled", defValue: ""},
+		},
+		handler: handlerServe,
+	})
+	cliCommands.register(commandSpec{
+		name:        "addfeed",
+		aliases:     []string{"add"},
+		description: "Add a feed to be aggregated, and follow it",
+		args: []argSpec{
+			{name: "name", usage: "name to show for the feed"},
+			{name: "url", usage: "URL of the RSS feed"},
+		},
+		handler: withLoggedInUser(handlerAddFeed),
+	})
+	cliCommands.register(commandSpec{
+		name:        "feeds",
+		description: "List all added feeds",
+		handler:     handlerFeeds,
+	})
+	cliCommands.register(commandSpec{
+		name:        "warnings",
+		description: "List problems worked around when last fetching the feeds you follow",
+		args:        []argSpec{{name: "feed-url", usage: "only list warnings for this feed", optional: true, complete: completeFollowedFeedURLs}},
+		handler:     withLoggedInUser(handlerWarnings),
+	})
+	cliCommands.register(commandSpec{
+		name:        "follow",
+		aliases:     []string{"sub", "subscribe"},
+		description: "Follow an already-added feed",
+		args:        []argSpec{{name: "feed-url", usage: "URL of the feed", complete: completeFeedURLs}},
+		handler:     withLoggedInUser(handlerFollow),
+	})
+	cliCommands.register(commandSpec{
+		name:        "following",
+		aliases:     []string{"subs"},
+		description: "List the feeds the logged-in user follows",
+		handler:     withLoggedInUser(handlerFollowing),
+	})
+	cliCommands.register(commandSpec{
+		name:        "unfollow",
+		aliases:     []string{"unsub", "unsubscribe"},
+		description: "Stop following a feed",
+		args:        []argSpec{{name: "feed-url", usage: "URL of the feed", complete: completeFollowedFeedURLs}},
+		handler:     withLoggedInUser(handlerUnfollow),
+	})
+	cliCommands.register(commandSpec{
+		name:        "folder",
+		description: "File a followed feed in a folder, or remove it from its folder",
+		args: []argSpec{
+			{name: "feed-url", usage: "URL of the feed", complete: completeFollowedFeedURLs},
+			{name: "folder", usage: "folder name - omit to remove the feed from its folder", optional: true, complete: completeFolders},
+		},
+		handler: withLoggedInUser(handlerFolder),
+	})
+	cliCommands.register(commandSpec{
+		name:        "browse",
+		aliases:     []string{"ls"},
+		description: "Show the most recent posts across the logged-in user's feeds",
+		args:        []argSpec{{name: "row-limit", usage: "max number of posts to show (default 2)", optional: true}},
+		flags: []flagSpec{
+			{name: "feed", usage: "only show posts from the feed with this URL", defValue: "", complete: completeFollowedFeedURLs},
+			{name: "folder", usage: "only show posts from feeds in this folder", defValue: "", complete: completeFolders},
+			{name: "since", usage: "only show posts published at or after this date/time, or this long ago (36h, 7d)", defValue: ""},
+			{name: "until", usage: "only show posts published before this date/time, or this long ago (36h, 7d)", defValue: ""},
+			{name: "unread", usage: "only show posts not yet marked read", defValue: false},
+			{name: "starred", usage: "only show starred posts", defValue: false},
+			{name: "tag", usage: "only show posts with this tag", defValue: "", complete: completeTags},
+			{name: "search", usage: "only show posts with this text in their title or content (case-insensitive)", defValue: ""},
+			{name: "sort", usage: "order posts by 'published' or 'fetched' time, newest first", defValue: "published", complete: completeValues("published", "fetched")},
+			{name: "after", usage: "cursor from the previous page, to see the next", defValue: ""},
+		},
+		handler: withLoggedInUser(handlerBrowse),
+	})
+	cliCommands.register(commandSpec{
+		name:        "tag",
+		description: "Tag a post",
+		args: []argSpec{
+			{name: "post-url", usage: "URL of a post, as shown by browse"},
+			{name: "tag", usage: "tags to add - case-insensitive, no whitespace", variadic: true, complete: completeTags},
+		},
+		handler: withLoggedInUser(handlerTag),
+	})
+	cliCommands.register(commandSpec{
+		name:        "untag",
+		description: "Remove tags from a post",
+		args: []argSpec{
+			{name: "post-url", usage: "URL of a post, as shown by browse"},
+			{name: "tag", usage: "tags to remove", variadic: true, complete: completeTags},
+		},
+		handler: withLoggedInUser(handlerUntag),
+	})
+	cliCommands.register(commandSpec{
+		name:        "tags",
+		description: "List tags with their post counts, or the tags on one post",
+		args:        []argSpec{{name: "post-url", usage: "URL of a post to list the tags of", optional: true}},
+		handler:     withLoggedInUser(handlerTags),
+	})
+	cliCommands.register(commandSpec{
+		name:        "read",
+		description: "Mark posts read",
+		args:        postURLs,
+		handler:     withLoggedInUser(handlerRead),
+	})
+	cliCommands.register(commandSpec{
+		name:        "unread",
+		description: "Mark posts unread",
+		args:        postURLs,
+		handler:     withLoggedInUser(handlerUnread),
+	})
+	cliCommands.register(commandSpec{
+		name:        "star",
+		description: "Star posts",
+		args:        postURLs,
+		handler:     withLoggedInUser(handlerStar),
+	})
+	cliCommands.register(commandSpec{
+		name:        "unstar",
+		description: "Unstar posts",
+		args:        postURLs,
+		handler:     withLoggedInUser(handlerUnstar),
+	})
+}
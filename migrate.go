@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bufio"
+	"embed"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/pressly/goose/v3"
+	"golang.org/x/term"
+)
+
+// The goose migrations in sql/schema (and their SQLite equivalents), built
+// into the binary so setup doesn't need the goose CLI. They're tracked in
+// goose's usual goose_db_version table, so databases migrated by hand with
+// goose carry on as before.
+//
+//go:embed sql/schema/*.sql sql/sqlite/schema/*.sql
+var embeddedMigrations embed.FS
+
+func init() {
+	goose.SetBaseFS(embeddedMigrations)
+}
+
+// Sets goose up for the database in use, returning the migrations directory
+func setupGoose(s *state) (string, error) {
+	if s.dialect == dialectSQLite {
+		return "sql/sqlite/schema", goose.SetDialect("sqlite3")
+	}
+	return "sql/schema", goose.SetDialect("postgres")
+}
+
+func handlerMigrate(s *state, cmd command) error {
+	migrationsDir, err := setupGoose(s)
+	if err != nil {
+		return err
+	}
+
+	switch cmd.args[0] {
+	case "up":
+		if err := goose.Up(s.conn, migrationsDir); err != nil {
+			return fmt.Errorf("Problem migrating database up: %v", err)
+		}
+	case "down":
+		if err := goose.Down(s.conn, migrationsDir); err != nil {
+			return fmt.Errorf("Problem migrating database down: %v", err)
+		}
+	case "status":
+		if err := goose.Status(s.conn, migrationsDir); err != nil {
+			return fmt.Errorf("Problem getting migration status: %v", err)
+		}
+	case "version":
+		current, latest, err := schemaVersions(s)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Database schema version %d (latest known to gator: %d)\n", current, latest)
+	default:
+		return fmt.Errorf("Unknown migrate action '%s': must be up, down, status or version", cmd.args[0])
+	}
+
+	return nil
+}
+
+func schemaVersions(s *state) (current int64, latest int64, err error) {
+	migrationsDir, err := setupGoose(s)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	migrations, err := goose.CollectMigrations(migrationsDir, 0, goose.MaxVersion)
+	if err != nil {
+		return 0, 0, fmt.Errorf("Problem reading embedded migrations: %v", err)
+	}
+	last, err := migrations.Last()
+	if err != nil {
+		return 0, 0, fmt.Errorf("Problem reading embedded migrations: %v", err)
+	}
+
+	current, err = goose.GetDBVersion(s.conn)
+	if err != nil {
+		return 0, 0, fmt.Errorf("Problem getting database schema version: %v", err)
+	}
+
+	return current, last.Version, nil
+}
+
+// Run before any command that uses the database. An out of date schema makes
+// queries fail in confusing ways, so rather than run against one we offer to
+// migrate (or just do it, if the config says so), and otherwise refuse.
+func ensureSchemaCurrent(s *state) error {
+	current, latest, err := schemaVersions(s)
+	if err != nil {
+		return err
+	}
+
+	if current > latest {
+		return fmt.Errorf("Database schema version %d is newer than this gator knows about (%d) - please upgrade gator", current, latest)
+	}
+	if current == latest {
+		return nil
+	}
+
+	if !s.cfg.AutoMigrate && !confirm(fmt.Sprintf("Database schema is at version %d but gator needs version %d. Migrate now?", current, latest)) {
+		return fmt.Errorf("Database schema version %d is behind this gator (%d) - run '%s migrate up' first", current, latest, os.Args[0])
+	}
+
+	migrationsDir, err := setupGoose(s)
+	if err != nil {
+		return err
+	}
+	if err := goose.Up(s.conn, migrationsDir); err != nil {
+		return fmt.Errorf("Problem migrating database up: %v", err)
+	}
+
+	return nil
+}
+
+// Asks a yes/no question, if there's someone at a terminal to answer it
+func confirm(question string) bool {
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return false
+	}
+
+	fmt.Fprintf(os.Stderr, "%s [y/N] ", question)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return false
+	}
+
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+	"time"
+)
+
+// How listing commands print their records, chosen with the global --output
+// option
+type outputFormat struct {
+	kind string
+	tmpl *template.Template
+}
+
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+	outputJSONL = "jsonl"
+	outputCSV   = "csv"
+	// Anything containing "{{" is taken to be a text/template, executed once
+	// per record
+	outputTemplate = "template"
+)
+
+func parseOutputFormat(spec string) (outputFormat, error) {
+	switch strings.ToLower(spec) {
+	case "", outputTable:
+		return outputFormat{kind: outputTable}, nil
+	case outputJSON:
+		return outputFormat{kind: outputJSON}, nil
+	case outputJSONL, "jsonlines":
+		return outputFormat{kind: outputJSONL}, nil
+	case outputCSV:
+		return outputFormat{kind: outputCSV}, nil
+	}
+
+	if strings.Contains(spec, "{{") {
+		if !strings.HasSuffix(spec, "\n") {
+			spec += "\n"
+		}
+		tmpl, err := template.New("output").Parse(spec)
+		if err != nil {
+			return outputFormat{}, fmt.Errorf("Invalid output template: %v", err)
+		}
+		return outputFormat{kind: outputTemplate, tmpl: tmpl}, nil
+	}
+
+	return outputFormat{}, fmt.Errorf("Unknown output format '%s': must be table, json, jsonl, csv or a Go template like '{{.Name}}'", spec)
+}
+
+// Prints a listing in the chosen format. Records are structs whose exported
+// fields are the columns - json tags give the column names for table, CSV and
+// JSON, while templates see the Go field names. Fields tagged `table:"-"` are
+// left out of tables, for values only useful to scripts, and any other table
+// tag names the column in tables - which with `json:"-"` makes a column just
+// for tables, like browse's markers.
+func printRecords[T any](s *state, records []T) error {
+	return writeRecords(os.Stdout, s.output, records)
+}
+
+func writeRecords[T any](w io.Writer, format outputFormat, records []T) error {
+	switch format.kind {
+	case outputJSON:
+		if records == nil {
+			records = []T{}
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(records)
+
+	case outputJSONL:
+		encoder := json.NewEncoder(w)
+		for _, record := range records {
+			if err := encoder.Encode(record); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case outputCSV:
+		writer := csv.NewWriter(w)
+		if err := writer.Write(recordColumns[T](false)); err != nil {
+			return err
+		}
+		for _, record := range records {
+			if err := writer.Write(recordValues(record, false, time.RFC3339)); err != nil {
+				return err
+			}
+		}
+		writer.Flush()
+		return writer.Error()
+
+	case outputTemplate:
+		for _, record := range records {
+			if err := format.tmpl.Execute(w, record); err != nil {
+				return fmt.Errorf("Problem executing output template: %v", err)
+			}
+		}
+		return nil
+
+	default:
+		writer := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(writer, strings.ToUpper(strings.Join(recordColumns[T](true), "\t")))
+		for _, record := range records {
+			fmt.Fprintln(writer, strings.Join(recordValues(record, true, "2006-01-02 15:04:05 MST"), "\t"))
+		}
+		return writer.Flush()
+	}
+}
+
+func recordColumns[T any](table bool) []string {
+	recordType := reflect.TypeFor[T]()
+	var columns []string
+	for i := 0; i < recordType.NumField(); i++ {
+		if name, ok := columnName(recordType.Field(i), table); ok {
+			columns = append(columns, name)
+		}
+	}
+	return columns
+}
+
+func recordValues[T any](record T, table bool, timeFormat string) []string {
+	value := reflect.ValueOf(record)
+	var values []string
+	for i := 0; i < value.NumField(); i++ {
+		if _, ok := columnName(value.Type().Field(i), table); !ok {
+			continue
+		}
+		switch field := value.Field(i).Interface().(type) {
+		case time.Time:
+			values = append(values, field.Local().Format(timeFormat))
+		default:
+			values = append(values, fmt.Sprint(field))
+		}
+	}
+	return values
+}
+
+func columnName(field reflect.StructField, table bool) (string, bool) {
+	if !field.IsExported() {
+		return "", false
+	}
+	if tableName := field.Tag.Get("table"); table && tableName != "" {
+		return tableName, tableName != "-"
+	}
+	tag := strings.Split(field.Tag.Get("json"), ",")[0]
+	switch tag {
+	case "-":
+		return "", false
+	case "":
+		return strings.ToLower(field.Name), true
+	default:
+		return tag, true
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+type outputTestRecord struct {
+	Marker string    `json:"-" table:" "`
+	Name   string    `json:"name"`
+	When   time.Time `json:"when"`
+	Count  int
+	Secret string `json:"-"`
+	Notes  string `json:"notes" table:"-"`
+}
+
+func TestWriteRecords(t *testing.T) {
+	when := time.Date(2025, 1, 6, 9, 0, 0, 0, time.Local)
+	table := when.Format("2006-01-02 15:04:05 MST")
+	rfc3339 := when.Format(time.RFC3339)
+	jsonTime := when.Format(time.RFC3339Nano)
+	records := []outputTestRecord{
+		{Marker: "+", Name: "first", When: when, Count: 1, Secret: "hidden", Notes: "a, b"},
+		{Name: "second", When: when, Count: 22},
+	}
+
+	tests := []struct {
+		spec    string
+		records []outputTestRecord
+		want    string
+	}{
+		{
+			spec:    "table",
+			records: records,
+			want: "   NAME    WHEN" + strings.Repeat(" ", len(table)-2) + "COUNT\n" +
+				"+  first   " + table + "  1\n" +
+				"   second  " + table + "  22\n",
+		},
+		{
+			spec:    "json",
+			records: records,
+			want: `[
+  {
+    "name": "first",
+    "when": "` + jsonTime + `",
+    "Count": 1,
+    "notes": "a, b"
+  },
+  {
+    "name": "second",
+    "when": "` + jsonTime + `",
+    "Count": 22,
+    "notes": ""
+  }
+]
+`,
+		},
+		{spec: "json", records: nil, want: "[]\n"},
+		{
+			spec:    "jsonl",
+			records: records,
+			want: `{"name":"first","when":"` + jsonTime + `","Count":1,"notes":"a, b"}` + "\n" +
+				`{"name":"second","when":"` + jsonTime + `","Count":22,"notes":""}` + "\n",
+		},
+		{
+			spec:    "csv",
+			records: records,
+			want: "name,when,count,notes\n" +
+				"first," + rfc3339 + ",1,\"a, b\"\n" +
+				"second," + rfc3339 + ",22,\n",
+		},
+		{spec: "csv", records: nil, want: "name,when,count,notes\n"},
+		{spec: "{{.Name}}={{.Count}} {{.Secret}}", records: records, want: "first=1 hidden\nsecond=22 \n"},
+	}
+
+	for _, tt := range tests {
+		format, err := parseOutputFormat(tt.spec)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.spec, err)
+		}
+		var out strings.Builder
+		if err := writeRecords(&out, format, tt.records); err != nil {
+			t.Fatalf("%s: %v", tt.spec, err)
+		}
+		if out.String() != tt.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", tt.spec, out.String(), tt.want)
+		}
+	}
+}
+
+func TestParseOutputFormat(t *testing.T) {
+	for _, spec := range []string{"", "TABLE", "json", "jsonlines", "CSV", "{{.Name}}"} {
+		if _, err := parseOutputFormat(spec); err != nil {
+			t.Errorf("%q: %v", spec, err)
+		}
+	}
+	for _, spec := range []string{"xml", "{{.Name"} {
+		if _, err := parseOutputFormat(spec); err == nil {
+			t.Errorf("%q: expected an error", spec)
+		}
+	}
+}
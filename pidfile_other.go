@@ -0,0 +1,37 @@
+//go:build !unix
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// Without flock, the pidfile's existence is the lock. One left behind by a
+// crash has to be removed by hand.
+type pidFile struct {
+	path string
+}
+
+func lockPidFile(path string) (*pidFile, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if errors.Is(err, os.ErrExist) {
+		return nil, fmt.Errorf("agg is already running (pidfile %s exists - remove it if not)", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Problem creating pidfile: %v", err)
+	}
+	defer file.Close()
+
+	if _, err := fmt.Fprintf(file, "%d\n", os.Getpid()); err != nil {
+		os.Remove(path)
+		return nil, fmt.Errorf("Problem writing pidfile: %v", err)
+	}
+
+	return &pidFile{path: path}, nil
+}
+
+func (p *pidFile) release() {
+	os.Remove(p.path)
+}
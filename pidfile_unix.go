@@ -0,0 +1,52 @@
+//go:build unix
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// A pidfile held with an exclusive lock for as long as the process runs. The
+// lock goes when the process does, however it dies, so a stale pidfile left
+// by a crash doesn't get in the way.
+type pidFile struct {
+	file *os.File
+}
+
+func lockPidFile(path string) (*pidFile, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("Problem opening pidfile: %v", err)
+	}
+
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		defer file.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			contents, _ := os.ReadFile(path)
+			pid, _ := strconv.Atoi(strings.TrimSpace(string(contents)))
+			return nil, fmt.Errorf("agg is already running (pid %d, pidfile %s)", pid, path)
+		}
+		return nil, fmt.Errorf("Problem locking pidfile: %v", err)
+	}
+
+	if err := file.Truncate(0); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("Problem writing pidfile: %v", err)
+	}
+	if _, err := fmt.Fprintf(file, "%d\n", os.Getpid()); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("Problem writing pidfile: %v", err)
+	}
+
+	return &pidFile{file: file}, nil
+}
+
+func (p *pidFile) release() {
+	os.Remove(p.file.Name())
+	p.file.Close()
+}
@@ -0,0 +1,325 @@
+package main
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/venzy/gator/internal/database"
+)
+
+// The web reader's pages, styles and scripts all in templates, so the binary
+// is all it needs
+//
+//go:embed web/*.html
+var webTemplates embed.FS
+
+var webPages = template.Must(
+	template.New("").
+		Funcs(template.FuncMap{"date": formatReaderTime}).
+		ParseFS(webTemplates, "web/*.html"))
+
+// The reader keeps the API token it was signed in with in this cookie
+const readerCookie = "gator_token"
+
+// Long enough not to be a nuisance - revoking the token ends it sooner
+const readerCookieMaxAge = 365 * 24 * time.Hour
+
+func (srv *server) registerReaderRoutes() {
+	srv.mux.HandleFunc("GET /{$}", srv.withReaderUser(srv.readerPage))
+	srv.mux.HandleFunc("POST /posts/{postID}/read", sameOrigin(srv.withReaderUser(srv.readerSetPostState(postRead))))
+	srv.mux.HandleFunc("POST /posts/{postID}/star", sameOrigin(srv.withReaderUser(srv.readerSetPostState(postStarred))))
+
+	srv.mux.HandleFunc("GET /login", srv.readerLoginPage)
+	srv.mux.HandleFunc("POST /login", sameOrigin(srv.readerLogin))
+	srv.mux.HandleFunc("POST /logout", sameOrigin(srv.readerLogout))
+}
+
+// Like withUser, but with the token from the reader's cookie, and errors
+// shown as plain text rather than JSON. Pages go to the login page when
+// there's no valid token.
+func (srv *server) withReaderUser(handler apiHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var token string
+		if cookie, err := r.Cookie(readerCookie); err == nil {
+			token = cookie.Value
+		}
+		user, err := srv.tokenUser(r, token)
+		if err == nil {
+			err = handler(w, r, user)
+		}
+		if err != nil {
+			status := http.StatusInternalServerError
+			var apiErr *apiError
+			if errors.As(err, &apiErr) {
+				status = apiErr.status
+			} else {
+				fmt.Fprintf(os.Stderr, "Problem handling request: %v\n", err)
+			}
+
+			if status == http.StatusUnauthorized && r.Method == http.MethodGet {
+				http.Redirect(w, r, "/login?"+url.Values{"next": {r.URL.RequestURI()}}.Encode(), http.StatusSeeOther)
+				return
+			}
+			http.Error(w, err.Error(), status)
+		}
+	}
+}
+
+type loginData struct {
+	Next    string
+	Error   string
+	Command string
+}
+
+func (srv *server) readerLoginPage(w http.ResponseWriter, r *http.Request) {
+	data := loginData{Next: localPath(r.URL.Query().Get("next")), Command: os.Args[0]}
+	if err := renderPage(w, http.StatusOK, "login.html", data); err != nil {
+		fmt.Fprintf(os.Stderr, "Problem handling request: %v\n", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// Signs in with an API token, which goes in a cookie for the reader's
+// requests to carry
+func (srv *server) readerLogin(w http.ResponseWriter, r *http.Request) {
+	token := strings.TrimSpace(r.PostFormValue("token"))
+	next := localPath(r.PostFormValue("next"))
+
+	_, err := srv.s.db.GetAPITokenByHash(r.Context(), hashToken(token))
+	if err != nil {
+		data := loginData{Next: next, Error: "That isn't a valid token", Command: os.Args[0]}
+		if err := renderPage(w, http.StatusUnauthorized, "login.html", data); err != nil {
+			fmt.Fprintf(os.Stderr, "Problem handling request: %v\n", err)
+		}
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     readerCookie,
+		Value:    token,
+		Path:     "/",
+		MaxAge:   int(readerCookieMaxAge.Seconds()),
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, next, http.StatusSeeOther)
+}
+
+func (srv *server) readerLogout(w http.ResponseWriter, r *http.Request) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     readerCookie,
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, "/login", http.StatusSeeOther)
+}
+
+// Rendered to a buffer first, so a template error doesn't leave half a page
+// behind
+func renderPage(w http.ResponseWriter, status int, name string, data any) error {
+	var page bytes.Buffer
+	if err := webPages.ExecuteTemplate(&page, name, data); err != nil {
+		return fmt.Errorf("Problem rendering %s: %v", name, err)
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	_, err := page.WriteTo(w)
+	return err
+}
+
+// Where to go back to after a form, which must be one of our own pages
+func localPath(path string) string {
+	if !strings.HasPrefix(path, "/") || strings.HasPrefix(path, "//") || strings.HasPrefix(path, "/\\") {
+		return "/"
+	}
+	return path
+}
+
+// Refuses form posts made from other sites, which could otherwise change
+// things as the logged-in user just by getting their browser to post here
+func sameOrigin(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if site := r.Header.Get("Sec-Fetch-Site"); site != "" && site != "same-origin" && site != "none" {
+			http.Error(w, "Cross-site requests are not allowed", http.StatusForbidden)
+			return
+		}
+		if origin := r.Header.Get("Origin"); origin != "" {
+			originURL, err := url.Parse(origin)
+			if err != nil || originURL.Host != r.Host {
+				http.Error(w, "Cross-site requests are not allowed", http.StatusForbidden)
+				return
+			}
+		}
+		handler(w, r)
+	}
+}
+
+type readerData struct {
+	User        database.User
+	Folders     []readerFolder
+	TotalUnread int64
+	Filters     browseFilters
+	Posts       []readerListPost
+	// Link to the next page of posts, if there may be one
+	Older string
+	// The post open in the reading pane, if any
+	Post *readerPost
+	// The reader's query string, for links to keep the current view
+	query url.Values
+}
+
+// Followed feeds under a folder heading, with those in no folder first
+type readerFolder struct {
+	Name  string
+	Feeds []readerFeed
+}
+
+type readerFeed struct {
+	database.GetFollowedFeedsWithUnreadCountsRow
+	Link     string
+	Selected bool
+}
+
+type readerListPost struct {
+	database.BrowsePostsForUserRow
+	Link     string
+	Selected bool
+}
+
+type readerPost struct {
+	database.GetPostForUserRow
+	Content template.HTML
+	Tags    []string
+}
+
+// A link to the reader with the current view changed by name/value pairs,
+// where an empty value drops that parameter
+func (d readerData) Link(pairs ...string) string {
+	query := url.Values{}
+	for name, values := range d.query {
+		query[name] = values
+	}
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if pairs[i+1] == "" {
+			query.Del(pairs[i])
+		} else {
+			query.Set(pairs[i], pairs[i+1])
+		}
+	}
+
+	if len(query) == 0 {
+		return "/"
+	}
+	return "/?" + query.Encode()
+}
+
+func (srv *server) readerPage(w http.ResponseWriter, r *http.Request, user database.User) error {
+	filters, err := browseFiltersFromQuery(r)
+	if err != nil {
+		return err
+	}
+	data := readerData{User: user, Filters: filters, query: r.URL.Query()}
+
+	feeds, err := srv.s.db.GetFollowedFeedsWithUnreadCounts(r.Context(), user.ID)
+	if err != nil {
+		return fmt.Errorf("Problem fetching follows for user '%s': %v", user.Name, err)
+	}
+	for _, feed := range feeds {
+		folder := feed.Folder.String
+		if len(data.Folders) == 0 || data.Folders[len(data.Folders)-1].Name != folder {
+			data.Folders = append(data.Folders, readerFolder{Name: folder})
+		}
+		last := &data.Folders[len(data.Folders)-1]
+		last.Feeds = append(last.Feeds, readerFeed{
+			GetFollowedFeedsWithUnreadCountsRow: feed,
+			Link:                                data.Link("feed", feed.Url, "folder", "", "after", "", "post", ""),
+			Selected:                            filters.Feed == feed.Url,
+		})
+		data.TotalUnread += feed.UnreadCount
+	}
+
+	params, err := filters.params(user.ID, time.Now())
+	if err != nil {
+		return badRequest("%v", err)
+	}
+	posts, err := srv.s.db.BrowsePostsForUser(r.Context(), params)
+	if err != nil {
+		return fmt.Errorf("Problem fetching posts for user '%s': %v", user.Name, err)
+	}
+	selected := r.URL.Query().Get("post")
+	for _, post := range posts {
+		data.Posts = append(data.Posts, readerListPost{
+			BrowsePostsForUserRow: post,
+			Link:                  data.Link("post", post.ID.String()),
+			Selected:              post.ID.String() == selected,
+		})
+	}
+	if len(posts) == int(filters.Limit) {
+		last := posts[len(posts)-1]
+		data.Older = data.Link("after", encodeBrowseCursor(last.SortTime, last.ID, filters.Sort), "post", "")
+	}
+
+	if selected != "" {
+		postID, err := uuid.Parse(selected)
+		if err != nil {
+			return badRequest("Invalid post ID '%s'", selected)
+		}
+		post, err := srv.s.db.GetPostForUser(r.Context(), database.GetPostForUserParams{UserID: user.ID, ID: postID})
+		if err != nil {
+			return notFound("Post '%s' not found in feeds followed by '%s'", postID, user.Name)
+		}
+		tags, err := srv.s.db.GetTagsForPost(r.Context(), database.GetTagsForPostParams{UserID: user.ID, PostID: post.ID})
+		if err != nil {
+			return fmt.Errorf("Problem fetching tags for post '%s': %v", post.Title, err)
+		}
+		data.Post = &readerPost{
+			GetPostForUserRow: post,
+			// Sanitised again in case it was stored before we sanitised
+			// posts on fetching
+			Content: template.HTML(descriptionPolicy.Sanitize(post.Description.String)),
+			Tags:    tags,
+		}
+	}
+
+	return renderPage(w, http.StatusOK, "reader.html", data)
+}
+
+// Sets a post read or unread, or starred or unstarred, from the form's
+// value, then goes back to the page the form was on
+func (srv *server) readerSetPostState(which postState) apiHandler {
+	return func(w http.ResponseWriter, r *http.Request, user database.User) error {
+		post, err := srv.postFromPath(r, user)
+		if err != nil {
+			return err
+		}
+		set, err := strconv.ParseBool(r.PostFormValue("set"))
+		if err != nil {
+			return badRequest("Invalid set '%s': must be true or false", r.PostFormValue("set"))
+		}
+
+		if err := srv.setPostState(r, user, post.ID, which, set); err != nil {
+			return fmt.Errorf("Problem updating post '%s': %v", post.Title, err)
+		}
+
+		http.Redirect(w, r, localPath(r.PostFormValue("return")), http.StatusSeeOther)
+		return nil
+	}
+}
+
+func formatReaderTime(t time.Time) string {
+	return t.Local().Format("2 Jan 2006 15:04")
+}
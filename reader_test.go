@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// A browser for the reader, signed in with a token. It doesn't follow
+// redirects, so tests can see where they go.
+func readerClient(t *testing.T, baseURL string, token string) *http.Client {
+	t.Helper()
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{
+		Jar:           jar,
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+
+	res, err := client.PostForm(baseURL+"/login", url.Values{"token": {token}, "next": {"/"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusSeeOther {
+		t.Fatalf("got status %d signing in", res.StatusCode)
+	}
+	return client
+}
+
+// Fetches a reader page, returning its HTML
+func readerGet(t *testing.T, client *http.Client, baseURL string, path string) string {
+	t.Helper()
+
+	res, err := client.Get(baseURL + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("got status %d for %s: %s", res.StatusCode, path, body)
+	}
+	return string(body)
+}
+
+func TestReader(t *testing.T) {
+	feeds := newFeedServer(t)
+
+	for name, newState := range testStates {
+		t.Run(name, func(t *testing.T) {
+			s := newState(t)
+			mustRun(t, s, "register", "alice")
+			mustRun(t, s, "addfeed", "Test", feeds.URL+"/feeds/html.xml")
+			captureStdout(t, func() { scapeFeeds(context.Background(), s) })
+			token := createToken(t, s, "test", scopeWrite)
+			api := httptest.NewServer(newServer(s))
+			defer api.Close()
+			client := readerClient(t, api.URL, token)
+
+			var page apiPostPage
+			apiRequest(t, api.URL, token, "GET", "/api/v1/posts", nil, &page)
+			if len(page.Posts) == 0 {
+				t.Fatal("no posts to read")
+			}
+			post := page.Posts[0]
+
+			// The feed with its one unread post, and the post in the list
+			html := readerGet(t, client, api.URL, "/")
+			for _, want := range []string{`<span>Test</span><span class="count">1</span>`, post.Title} {
+				if !strings.Contains(html, want) {
+					t.Errorf("reader page doesn't contain %q", want)
+				}
+			}
+
+			// The reading pane has the post, without anything unsafe
+			html = readerGet(t, client, api.URL, "/?post="+post.ID.String())
+			if !strings.Contains(html, `id="toggle-read"`) {
+				t.Error("reading pane has no mark read form")
+			}
+			if strings.Contains(html, "<iframe") || strings.Contains(html, `alert("hi")`) {
+				t.Error("reading pane contains unsanitised content")
+			}
+
+			// Marking read goes back to where we were
+			form := url.Values{"set": {"true"}, "return": {"/?post=" + post.ID.String()}}
+			res, err := client.PostForm(api.URL+"/posts/"+post.ID.String()+"/read", form)
+			if err != nil {
+				t.Fatal(err)
+			}
+			res.Body.Close()
+			if res.StatusCode != http.StatusSeeOther || res.Header.Get("Location") != "/?post="+post.ID.String() {
+				t.Errorf("got %d to %s marking read, want a redirect back", res.StatusCode, res.Header.Get("Location"))
+			}
+			var read apiPost
+			apiRequest(t, api.URL, token, "GET", "/api/v1/posts/"+post.ID.String(), nil, &read)
+			if !read.Read {
+				t.Error("post not marked read")
+			}
+
+			// Nowhere else, though
+			form.Set("return", "//evil.example.com/")
+			res, err = client.PostForm(api.URL+"/posts/"+post.ID.String()+"/star", form)
+			if err != nil {
+				t.Fatal(err)
+			}
+			res.Body.Close()
+			if location := res.Header.Get("Location"); location != "/" {
+				t.Errorf("got redirect to %s, want /", location)
+			}
+
+			// Forms posted from other sites are refused
+			req, err := http.NewRequest("POST", api.URL+"/posts/"+post.ID.String()+"/read", strings.NewReader("set=false"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			req.Header.Set("Origin", "https://evil.example.com")
+			res, err = client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			res.Body.Close()
+			if res.StatusCode != http.StatusForbidden {
+				t.Errorf("got status %d for a cross-site post, want 403", res.StatusCode)
+			}
+
+			// Without a token, pages go to the login page and actions fail
+			anonymous := &http.Client{CheckRedirect: client.CheckRedirect}
+			res, err = anonymous.Get(api.URL + "/?unread=true")
+			if err != nil {
+				t.Fatal(err)
+			}
+			res.Body.Close()
+			if location := res.Header.Get("Location"); res.StatusCode != http.StatusSeeOther || !strings.HasPrefix(location, "/login?next=") {
+				t.Errorf("got %d to %s without a token, want a redirect to the login page", res.StatusCode, location)
+			}
+			res, err = anonymous.PostForm(api.URL+"/posts/"+post.ID.String()+"/read", url.Values{"set": {"false"}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			res.Body.Close()
+			if res.StatusCode != http.StatusUnauthorized {
+				t.Errorf("got status %d for an action without a token, want 401", res.StatusCode)
+			}
+
+			res, err = anonymous.PostForm(api.URL+"/login", url.Values{"token": {"gator_nonsense"}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			res.Body.Close()
+			if res.StatusCode != http.StatusUnauthorized {
+				t.Errorf("got status %d signing in with a bad token, want 401", res.StatusCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"time"
+
+	"github.com/venzy/gator/internal/database"
+)
+
+func handlerRefresh(s *state, cmd command) error {
+	jobs := cmd.intFlag("jobs")
+	if jobs < 1 {
+		return fmt.Errorf("Invalid --jobs %d: must be at least 1", jobs)
+	}
+
+	feeds, err := feedsToRefresh(s, cmd)
+	if err != nil {
+		return err
+	}
+
+	// Ctrl-C abandons the fetches still going, but we still report on them
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	records := make([]refreshRecord, len(feeds))
+	slots := make(chan struct{}, jobs)
+	var wg sync.WaitGroup
+	for i, feed := range feeds {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+
+			fetchCtx, cancel := context.WithTimeout(ctx, feedFetchTimeout)
+			defer cancel()
+
+			newPosts, err := collectFeed(fetchCtx, s, feed)
+			records[i] = refreshRecord{Feed: feed.Name, Url: feed.Url, NewPosts: newPosts}
+			if err != nil {
+				records[i].Error = err.Error()
+			}
+		}()
+	}
+	wg.Wait()
+	sendWebhooks(ctx, s)
+
+	if err := printRecords(s, records); err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, record := range records {
+		if record.Error != "" {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d feeds failed to refresh", failed, len(records))
+	}
+
+	return nil
+}
+
+// Feeds named on the command line are refreshed whether due or not. Otherwise
+// it's every feed (or every feed the user follows) not fetched recently.
+func feedsToRefresh(s *state, cmd command) ([]database.Feed, error) {
+	mine := cmd.boolFlag("mine")
+
+	if len(cmd.args) > 0 {
+		if mine {
+			return nil, fmt.Errorf("--mine can't be used with named feeds")
+		}
+
+		var feeds []database.Feed
+		for _, feedURL := range cmd.args {
+			feed, err := s.db.GetFeedByURL(context.Background(), feedURL)
+			if err != nil {
+				return nil, fmt.Errorf("Feed URL '%s' not in database!", feedURL)
+			}
+			feeds = append(feeds, feed)
+		}
+		return feeds, nil
+	}
+
+	fetchedBefore := sql.NullTime{Time: time.Now().Add(-cmd.durationFlag("older-than")), Valid: true}
+
+	if mine {
+		user, err := currentUser(s)
+		if err != nil {
+			return nil, err
+		}
+
+		feeds, err := s.db.GetFeedsDueForUser(
+			context.Background(),
+			database.GetFeedsDueForUserParams{
+				UserID:        user.ID,
+				FetchedBefore: fetchedBefore,
+			})
+		if err != nil {
+			return nil, fmt.Errorf("Problem fetching feeds for user '%s': %v", user.Name, err)
+		}
+		return feeds, nil
+	}
+
+	feeds, err := s.db.GetFeedsDue(context.Background(), fetchedBefore)
+	if err != nil {
+		return nil, fmt.Errorf("Problem fetching feeds: %v", err)
+	}
+	return feeds, nil
+}
+
+type refreshRecord struct {
+	Feed     string `json:"feed"`
+	Url      string `json:"url"`
+	NewPosts int    `json:"new_posts"`
+	Error    string `json:"error"`
+}
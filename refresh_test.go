@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestRefresh(t *testing.T) {
+	server := newFeedServer(t)
+	feedURL := server.URL + "/feeds/rss.xml"
+
+	for name, newState := range testStates {
+		t.Run(name, func(t *testing.T) {
+			s := newState(t)
+			mustRun(t, s, "register", "alice")
+			mustRun(t, s, "addfeed", "Test", feedURL)
+			// Neither JSON Feed nor Atom is supported, so both fail
+			mustRun(t, s, "addfeed", "Broken", server.URL+"/feeds/feed.json")
+			mustRun(t, s, "register", "bob")
+			mustRun(t, s, "addfeed", "Atom", server.URL+"/feeds/atom.xml")
+
+			s.output = outputFormat{kind: outputJSON}
+			output, err := runCommand(t, s, "refresh", "--jobs", "2")
+			if err == nil || !strings.Contains(err.Error(), "2 of 3 feeds failed") {
+				t.Errorf("got error %v, want 2 of 3 feeds failing", err)
+			}
+			var records []refreshRecord
+			if err := json.Unmarshal([]byte(output), &records); err != nil {
+				t.Fatalf("decoding output %q: %v", output, err)
+			}
+			results := make(map[string]refreshRecord)
+			for _, record := range records {
+				results[record.Feed] = record
+			}
+			if len(results) != 3 || results["Test"].NewPosts != 3 || results["Test"].Error != "" {
+				t.Errorf("got results %+v, want 3 new posts from Test", records)
+			}
+			if results["Broken"].Error == "" || results["Atom"].Error == "" {
+				t.Errorf("got results %+v, want errors from Broken and Atom", records)
+			}
+
+			// Everything was just fetched, so nothing's due
+			if records := runListing[refreshRecord](t, s, "refresh"); len(records) != 0 {
+				t.Errorf("got %+v refreshing again, want nothing due", records)
+			}
+
+			// Named feeds are fetched regardless
+			records = runListing[refreshRecord](t, s, "refresh", feedURL)
+			if len(records) != 1 || records[0].NewPosts != 0 {
+				t.Errorf("got %+v refreshing a named feed, want it with no new posts", records)
+			}
+
+			// Bob only follows the Atom feed
+			output, _ = runCommand(t, s, "refresh", "--mine", "--older-than", "0s")
+			records = nil
+			if err := json.Unmarshal([]byte(output), &records); err != nil {
+				t.Fatalf("decoding output %q: %v", output, err)
+			}
+			if len(records) != 1 || records[0].Feed != "Atom" {
+				t.Errorf("got %+v refreshing bob's feeds, want just Atom", records)
+			}
+
+			if _, err := runCommand(t, s, "refresh", "--mine", feedURL); err == nil {
+				t.Error("expected an error combining --mine with named feeds")
+			}
+			if _, err := runCommand(t, s, "refresh", "--jobs", "0"); err == nil {
+				t.Error("expected an error for --jobs 0")
+			}
+		})
+	}
+}
+
+func TestRefreshUpdatesMovedFeed(t *testing.T) {
+	server := newFeedServer(t)
+
+	for name, newState := range testStates {
+		t.Run(name, func(t *testing.T) {
+			s := newState(t)
+			mustRun(t, s, "register", "alice")
+			mustRun(t, s, "addfeed", "Moved", server.URL+"/moved-twice")
+			mustRun(t, s, "addfeed", "Redirected", server.URL+"/redirect")
+
+			// Both end up at the same place, but only one has moved there
+			records := runListing[refreshRecord](t, s, "refresh", "--jobs", "1")
+			if len(records) != 2 {
+				t.Fatalf("got %+v, want 2 feeds refreshed", records)
+			}
+
+			urls := make(map[string]string)
+			for _, feed := range runListing[feedRecord](t, s, "feeds") {
+				urls[feed.Name] = feed.Url
+			}
+			if want := server.URL + "/feeds/rss.xml"; urls["Moved"] != want {
+				t.Errorf("got moved feed URL %q, want %q", urls["Moved"], want)
+			}
+			if want := server.URL + "/redirect"; urls["Redirected"] != want {
+				t.Errorf("got redirected feed URL %q, want it unchanged (%q)", urls["Redirected"], want)
+			}
+		})
+	}
+}
+
+func TestRefreshSanitisesPosts(t *testing.T) {
+	server := newFeedServer(t)
+
+	for name, newState := range testStates {
+		t.Run(name, func(t *testing.T) {
+			s := newState(t)
+			mustRun(t, s, "register", "alice")
+			mustRun(t, s, "addfeed", "HTML", server.URL+"/feeds/html.xml")
+			runListing[refreshRecord](t, s, "refresh")
+
+			posts := runListing[postRecord](t, s, "browse")
+			if len(posts) != 1 {
+				t.Fatalf("got %d posts, want 1", len(posts))
+			}
+			if want := server.URL + "/blog/relative-post"; posts[0].Url != want {
+				t.Errorf("got URL %q, want %q", posts[0].Url, want)
+			}
+			// From the full content rather than the summary
+			if want := "Read about us.\nA photo"; posts[0].Text != want {
+				t.Errorf("got text %q, want %q", posts[0].Text, want)
+			}
+
+			post, err := s.db.GetPostByURL(context.Background(), server.URL+"/blog/relative-post")
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := `<p>Read <a href="` + server.URL + `/about" rel="nofollow">about us</a>.</p>` + "\n        \n        " +
+				`<p><img src="` + server.URL + `/blog/images/photo.jpg" alt="A photo"/></p>`
+			if post.Description.String != want {
+				t.Errorf("got description %q, want %q", post.Description.String, want)
+			}
+		})
+	}
+}
@@ -1,20 +1,30 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
 	"encoding/xml"
+	"errors"
 	"fmt"
 	"html"
 	"io"
-	"net/http"
+	"mime"
+	"net/url"
+	"os"
+	"slices"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/google/uuid"
 	"github.com/venzy/gator/internal/database"
+	"golang.org/x/net/html/charset"
 )
 
+// How long a single feed gets to fetch and store
+const feedFetchTimeout = time.Minute
+
 type RSSFeed struct {
 	Channel struct {
 		Title       string    `xml:"title"`
@@ -22,116 +32,414 @@ type RSSFeed struct {
 		Description string    `xml:"description"`
 		Item        []RSSItem `xml:"item"`
 	} `xml:"channel"`
+	// The WebSub hub the feed advertises, and the URL it gives for itself,
+	// which is what to subscribe to there
+	Hub  string `xml:"-"`
+	Self string `xml:"-"`
+	// Problems worked around while parsing
+	Warnings []string `xml:"-"`
 }
 
 type RSSItem struct {
 	Title       string `xml:"title"`
 	Link        string `xml:"link"`
 	Description string `xml:"description"`
-	PubDate     string `xml:"pubDate"`
+	// The full post from the content module, where description is a summary
+	Content string `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+	PubDate string `xml:"pubDate"`
 }
 
-func fetchFeed(ctx context.Context, feedURL string) (*RSSFeed, error) {
-	req, err := http.NewRequestWithContext(ctx, "GET", feedURL, nil)
+// Fetches and parses an RSS feed, also returning its new URL if it's been
+// permanently redirected
+func fetchFeed(ctx context.Context, opts fetchOptions, feedURL string) (*RSSFeed, string, error) {
+	res, err := fetchURL(ctx, opts, feedURL)
 	if err != nil {
-		return nil, fmt.Errorf("Error creating request: %s", err)
+		return nil, "", err
 	}
-	req.Header.Set("User-Agent", "gator")
-
-	client := &http.Client{}
 
-	res, err := client.Do(req)
+	rssFeed, err := parseRSS(res.body, res.contentType)
 	if err != nil {
-		return nil, fmt.Errorf("Error making request: %s", err)
+		return nil, "", err
 	}
-	defer res.Body.Close()
 
-	body, err := io.ReadAll(res.Body)
-	if err != nil {
-		return nil, fmt.Errorf("Error reading response body: %s", err)
+	// Unescape various strings. Descriptions are HTML, so they're left to
+	// the HTML parser when they're sanitised.
+	rssFeed.Channel.Title = html.UnescapeString(rssFeed.Channel.Title)
+	for idx, item := range rssFeed.Channel.Item {
+		rssFeed.Channel.Item[idx].Title = html.UnescapeString(item.Title)
 	}
 
+	return rssFeed, res.movedTo, nil
+}
+
+// Parses RSS as leniently as we can, as plenty of feeds out there aren't
+// well-formed XML. Undeclared HTML entities, stray ampersands and unclosed
+// tags are let through, and if parsing still fails part way we keep the
+// items before the problem. Only a document that isn't RSS at all fails.
+func parseRSS(body []byte, contentType string) (*RSSFeed, error) {
+	body = bytes.TrimPrefix(body, []byte("\xef\xbb\xbf"))
+
+	decoder, stripped := newFeedDecoder(body, contentType)
+	decoder.Strict = false
+	decoder.AutoClose = rssAutoClose
+	decoder.Entity = xml.HTMLEntity
+
 	var rssFeed RSSFeed
-	err = xml.Unmarshal(body, &rssFeed)
-	if err != nil {
-		return nil, fmt.Errorf("Error parsing XML: %s", err)
+	var warnings []string
+	// Elements we're inside, to tell the channel's title from an item's or
+	// an image's
+	var open []string
+	seenRoot := false
+
+parsing:
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if !seenRoot {
+				return nil, fmt.Errorf("Error parsing XML: %s", err)
+			}
+			warnings = append(warnings, fmt.Sprintf("Stopped parsing after %d items: %v", len(rssFeed.Channel.Item), err))
+			break
+		}
+
+		switch token := token.(type) {
+		case xml.StartElement:
+			if !seenRoot {
+				if token.Name.Local != "rss" {
+					return nil, fmt.Errorf("Error parsing XML: expected element type <rss> but have <%s>", token.Name.Local)
+				}
+				seenRoot = true
+			}
+
+			if len(open) > 0 && open[len(open)-1] == "channel" {
+				// <atom:link>s, unlike RSS's <link>, are all in attributes -
+				// the namespace is just its prefix if the feed forgets to
+				// declare it
+				if token.Name.Local == "link" && (token.Name.Space == atomNamespace || token.Name.Space == "atom") {
+					var href, rel string
+					for _, attr := range token.Attr {
+						switch attr.Name.Local {
+						case "href":
+							href = strings.TrimSpace(attr.Value)
+						case "rel":
+							rel = attr.Value
+						}
+					}
+					switch {
+					case rel == "hub" && rssFeed.Hub == "":
+						rssFeed.Hub = href
+					case rel == "self":
+						rssFeed.Self = href
+					}
+					if err := decoder.Skip(); err != nil {
+						warnings = append(warnings, fmt.Sprintf("Stopped parsing after %d items: %v", len(rssFeed.Channel.Item), err))
+						break parsing
+					}
+					continue
+				}
+
+				var target any
+				switch token.Name.Local {
+				case "title":
+					target = &rssFeed.Channel.Title
+				case "link":
+					target = &rssFeed.Channel.Link
+				case "description":
+					target = &rssFeed.Channel.Description
+				case "item":
+					var item RSSItem
+					if err := decoder.DecodeElement(&item, &token); err != nil {
+						warnings = append(warnings, fmt.Sprintf("Stopped parsing after %d items: %v", len(rssFeed.Channel.Item), err))
+						break parsing
+					}
+					if item.Link == "" {
+						warnings = append(warnings, fmt.Sprintf("Skipped item '%s' with no link", item.Title))
+					} else {
+						rssFeed.Channel.Item = append(rssFeed.Channel.Item, item)
+					}
+					continue
+				}
+				if target != nil {
+					if err := decoder.DecodeElement(target, &token); err != nil {
+						warnings = append(warnings, fmt.Sprintf("Stopped parsing after %d items: %v", len(rssFeed.Channel.Item), err))
+						break parsing
+					}
+					continue
+				}
+			}
+			open = append(open, token.Name.Local)
+
+		case xml.EndElement:
+			// Unclosed tags can leave us a few levels deeper than the end tag
+			for i := len(open) - 1; i >= 0; i-- {
+				if open[i] == token.Name.Local {
+					open = open[:i]
+					break
+				}
+			}
+		}
 	}
 
-	// Unescape various strings
-	rssFeed.Channel.Title = html.UnescapeString(rssFeed.Channel.Title)
-	rssFeed.Channel.Description = html.UnescapeString(rssFeed.Channel.Description)
-	for idx, item := range rssFeed.Channel.Item {
-		rssFeed.Channel.Item[idx].Title = html.UnescapeString(item.Title)
-		rssFeed.Channel.Item[idx].Description = html.UnescapeString(item.Description)
+	if !seenRoot {
+		return nil, fmt.Errorf("Error parsing XML: no <rss> element found")
 	}
 
+	if stripped.removed > 0 {
+		warnings = append([]string{fmt.Sprintf("Removed %d control characters", stripped.removed)}, warnings...)
+	}
+	rssFeed.Warnings = warnings
 	return &rssFeed, nil
 }
 
+const atomNamespace = "http://www.w3.org/2005/Atom"
+
+// HTML's empty elements, for unescaped HTML like <br> in descriptions -
+// except link, which isn't empty in RSS
+var rssAutoClose = slices.DeleteFunc(slices.Clone(xml.HTMLAutoClose), func(name string) bool { return name == "link" })
+
+// Drops control characters that aren't allowed in XML but turn up in feeds
+// anyway. It has to read UTF-8 (or another encoding where they're single
+// bytes), as in UTF-16 a NUL is half of most characters.
+type controlStripper struct {
+	input   io.Reader
+	removed int
+}
+
+func (c *controlStripper) Read(p []byte) (int, error) {
+	for {
+		n, err := c.input.Read(p)
+		kept := 0
+		for _, b := range p[:n] {
+			if b < 0x20 && b != '\t' && b != '\n' && b != '\r' {
+				c.removed++
+				continue
+			}
+			p[kept] = b
+			kept++
+		}
+		if kept > 0 || n == 0 || err != nil {
+			return kept, err
+		}
+	}
+}
+
+// An XML decoder producing UTF-8 whatever the feed's encoding. A charset in
+// the HTTP Content-Type wins over the XML declaration, as the server may
+// have transcoded the feed without rewriting the declaration - except for
+// a UTF-8 charset on a body that isn't, which is just a server default.
+// Control characters are stripped once we have UTF-8, or from the raw body
+// when only the declaration says what it is, as encoding/xml can only read
+// that from ASCII-compatible encodings anyway.
+func newFeedDecoder(body []byte, contentType string) (*xml.Decoder, *controlStripper) {
+	var input io.Reader = bytes.NewReader(body)
+
+	transcoded := false
+	if _, params, err := mime.ParseMediaType(contentType); err == nil {
+		encoding, name := charset.Lookup(params["charset"])
+		if encoding != nil && (name != "utf-8" || utf8.Valid(body)) {
+			if reader, err := charset.NewReaderLabel(name, input); err == nil {
+				input = reader
+				transcoded = true
+			}
+		}
+	}
+
+	stripped := &controlStripper{input: input}
+	decoder := xml.NewDecoder(stripped)
+	decoder.CharsetReader = func(label string, input io.Reader) (io.Reader, error) {
+		if transcoded {
+			return input, nil
+		}
+		return charset.NewReaderLabel(label, input)
+	}
+	return decoder, stripped
+}
+
 func parseDateTime(input string) (time.Time, error) {
-    // List of possible formats
-    formats := []string{
-        "Mon, 02 Jan 2006 15:04:05 -0700",
-    }
-
-    var parsedDate time.Time
-    var err error
-
-    // Attempt to parse the date using each format
-    for _, format := range formats {
-        parsedDate, err = time.Parse(format, input)
-        if err == nil {
-            return parsedDate, nil
-        }
-    }
-
-    // If none of the formats work, return an error
-    return time.Time{}, fmt.Errorf("could not parse date '%s': %v", input, err)
+	// List of possible formats
+	formats := []string{
+		"Mon, 02 Jan 2006 15:04:05 -0700",
+	}
+
+	var parsedDate time.Time
+	var err error
+
+	// Attempt to parse the date using each format
+	for _, format := range formats {
+		parsedDate, err = time.Parse(format, input)
+		if err == nil {
+			return parsedDate, nil
+		}
+	}
+
+	// If none of the formats work, return an error
+	return time.Time{}, fmt.Errorf("could not parse date '%s': %v", input, err)
 }
 
-func scapeFeeds(s *state) error {
-	feed, err := s.db.GetNextFeedToFetch(context.Background())
+// Collects the feed that's gone longest without a fetch, of those that
+// aren't being pushed to us by a WebSub hub
+func scapeFeeds(ctx context.Context, s *state) error {
+	feed, err := s.db.GetNextFeedToFetch(ctx, sql.NullTime{Time: time.Now(), Valid: true})
 	if err != nil {
 		return err
 	}
 
+	_, err = collectFeed(ctx, s, feed)
+	return err
+}
+
+// Fetches a feed and stores any posts we don't already have, returning how
+// many were new
+func collectFeed(ctx context.Context, s *state, feed database.Feed) (int, error) {
 	// Not sure why we mark it fetched before fetch success
-	err = s.db.MarkFeedFetched(
-		context.Background(),
+	err := s.db.MarkFeedFetched(
+		ctx,
 		database.MarkFeedFetchedParams{
-			ID: feed.ID,
+			ID:            feed.ID,
 			LastFetchedAt: sql.NullTime{Time: time.Now(), Valid: true},
 		})
-	
-	rssFeed, err := fetchFeed(context.Background(), feed.Url)
 	if err != nil {
-		return err
+		return 0, fmt.Errorf("Problem marking feed '%s' fetched: %v", feed.Name, err)
+	}
+
+	opts, err := newFetchOptions(s.cfg.Fetch)
+	if err != nil {
+		return 0, err
+	}
+	rssFeed, movedTo, err := fetchFeed(ctx, opts, feed.Url)
+	if err != nil {
+		return 0, err
 	}
 
+	if movedTo != "" && movedTo != feed.Url {
+		err := s.db.UpdateFeedURL(
+			ctx,
+			database.UpdateFeedURLParams{
+				ID:        feed.ID,
+				Url:       movedTo,
+				UpdatedAt: time.Now(),
+			})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Problem updating URL of feed '%s', which has moved to %s: %v\n", feed.Name, movedTo, err)
+		} else {
+			fmt.Fprintf(os.Stderr, "Feed '%s' has moved permanently from %s to %s\n", feed.Name, feed.Url, movedTo)
+		}
+	}
+
+	// The warnings from this fetch replace the last fetch's
+	err = s.db.DeleteFeedWarnings(ctx, feed.ID)
+	if err != nil {
+		return 0, fmt.Errorf("Problem clearing warnings for feed '%s': %v", feed.Name, err)
+	}
+	for _, warning := range rssFeed.Warnings {
+		fmt.Fprintf(os.Stderr, "Feed '%s': %s\n", feed.Name, warning)
+		err := s.db.CreateFeedWarning(
+			ctx,
+			database.CreateFeedWarningParams{
+				ID:        uuid.New(),
+				CreatedAt: time.Now(),
+				FeedID:    feed.ID,
+				Message:   warning,
+			})
+		if err != nil {
+			return 0, fmt.Errorf("Problem recording warning for feed '%s': %v", feed.Name, err)
+		}
+	}
+
+	// Relative links are relative to where we found the feed
+	feedURL, err := url.Parse(feed.Url)
+	if movedTo != "" {
+		feedURL, err = url.Parse(movedTo)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("Problem parsing URL of feed '%s': %v", feed.Name, err)
+	}
+
+	// Noted for serve to subscribe to the hub, if there is one. Feeds
+	// without a self link are taken to be known to the hub by their URL.
+	hub := sql.NullString{String: rssFeed.Hub, Valid: rssFeed.Hub != ""}
+	topic := sql.NullString{String: rssFeed.Self, Valid: hub.Valid}
+	if topic.Valid && topic.String == "" {
+		topic.String = feedURL.String()
+	}
+	if hub != feed.HubUrl || topic != feed.TopicUrl {
+		err := s.db.SetFeedHub(
+			ctx,
+			database.SetFeedHubParams{
+				ID:       feed.ID,
+				HubUrl:   hub,
+				TopicUrl: topic,
+			})
+		if err != nil {
+			return 0, fmt.Errorf("Problem recording WebSub hub of feed '%s': %v", feed.Name, err)
+		}
+	}
+
+	return storePosts(ctx, s, feed, feedURL, rssFeed.Channel.Item), nil
+}
+
+// Stores the posts we don't already have from a feed's items, whether
+// fetched or pushed, and queues the new ones for webhooks, returning how
+// many there were. Problems with single items go to stderr, leaving stdout for
+// listings.
+func storePosts(ctx context.Context, s *state, feed database.Feed, feedURL *url.URL, items []RSSItem) int {
+	var newPosts []database.Post
 	now := time.Now()
-	for _, item := range rssFeed.Channel.Item {
+	for _, item := range items {
 		pubTime, err := parseDateTime(item.PubDate)
 		if err != nil {
-			fmt.Printf("Problem parsing publication date '%v' for item '%s', assuming 'now': %v\n", item.PubDate, item.Title, err)
+			fmt.Fprintf(os.Stderr, "Problem parsing publication date '%v' for item '%s', assuming 'now': %v\n", item.PubDate, item.Title, err)
 			pubTime = now
 		}
-		_, err = s.db.CreatePost(
-			context.Background(),
+
+		postURL, err := feedURL.Parse(strings.TrimSpace(item.Link))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Problem parsing link '%s' of item '%s', skipping it: %v\n", item.Link, item.Title, err)
+			continue
+		}
+
+		body := item.Description
+		if item.Content != "" {
+			body = item.Content
+		}
+		description, text := sanitiseDescription(body, postURL)
+
+		post, err := s.db.CreatePost(
+			ctx,
 			database.CreatePostParams{
-				ID : uuid.New(),
-				CreatedAt: now,
-				UpdatedAt: now,
-				Title: item.Title,
-				Url: item.Link,
-				Description: sql.NullString{String: item.Description, Valid: true},
-				PublishedAt: pubTime,
-				FeedID: feed.ID,
+				ID:              uuid.New(),
+				CreatedAt:       now,
+				UpdatedAt:       now,
+				Title:           item.Title,
+				Url:             postURL.String(),
+				Description:     sql.NullString{String: description, Valid: true},
+				PublishedAt:     pubTime,
+				FeedID:          feed.ID,
+				DescriptionText: sql.NullString{String: text, Valid: true},
 			})
-		
-		if err != nil && !strings.HasSuffix(err.Error(), "\"posts_url_key\"") {
-			fmt.Printf("Problem adding post '%s': %v\n", item.Title, err)
+
+		// No rows means we already have a post with this URL
+		switch {
+		case err == nil:
+			newPosts = append(newPosts, post)
+		case !errors.Is(err, sql.ErrNoRows):
+			fmt.Fprintf(os.Stderr, "Problem adding post '%s': %v\n", item.Title, err)
 		}
 	}
 
-	return nil
-}
\ No newline at end of file
+	if len(newPosts) > 0 {
+		// Numbered as they arrive, so the APIs needn't write when only
+		// reading
+		if err := s.db.NumberNewFeeds(ctx); err != nil {
+			fmt.Fprintf(os.Stderr, "Problem numbering feeds: %v\n", err)
+		}
+		if err := s.db.NumberNewPosts(ctx); err != nil {
+			fmt.Fprintf(os.Stderr, "Problem numbering posts: %v\n", err)
+		}
+		queueWebhooks(ctx, s, feed, newPosts)
+	}
+	return len(newPosts)
+}
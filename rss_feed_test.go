@@ -0,0 +1,420 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/venzy/gator/internal/config"
+)
+
+func TestFetchFeed(t *testing.T) {
+	server := newFeedServer(t)
+
+	tests := []struct {
+		name      string
+		path      string
+		wantTitle string
+		wantItems int
+		wantErr   string
+	}{
+		{name: "rss", path: "/feeds/rss.xml", wantTitle: "Gator Test Feed", wantItems: 3},
+		{name: "redirect", path: "/redirect", wantTitle: "Gator Test Feed", wantItems: 3},
+		{name: "gzip", path: "/gzip", wantTitle: "Gator Test Feed", wantItems: 3},
+		{name: "brotli", path: "/brotli", wantTitle: "Gator Test Feed", wantItems: 3},
+		{name: "huge", path: "/huge", wantTitle: "Huge Feed", wantItems: hugeFeedItems},
+		// Only RSS is supported, so these just check other formats are
+		// turned away cleanly
+		{name: "atom rejected", path: "/feeds/atom.xml", wantErr: "expected element type <rss>"},
+		{name: "json feed rejected", path: "/feeds/feed.json", wantErr: "Error parsing XML"},
+		{name: "malformed", path: "/feeds/malformed.xml", wantTitle: "Broken Feed", wantItems: 1},
+		{name: "truncated", path: "/feeds/truncated.xml", wantTitle: "Truncated Feed", wantItems: 2},
+		{name: "sloppy", path: "/feeds/sloppy.xml", wantTitle: "Sloppy Feed", wantItems: 2},
+		{name: "not found", path: "/feeds/missing.xml", wantErr: "404"},
+		{name: "redirect loop", path: "/redirect-loop", wantErr: "more than 5 redirects"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			feed, _, err := fetchFeed(context.Background(), defaultFetchOptions(t), server.URL+tt.path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if feed.Channel.Title != tt.wantTitle {
+				t.Errorf("got title %q, want %q", feed.Channel.Title, tt.wantTitle)
+			}
+			if len(feed.Channel.Item) != tt.wantItems {
+				t.Errorf("got %d items, want %d", len(feed.Channel.Item), tt.wantItems)
+			}
+		})
+	}
+}
+
+func TestFetchFeedCharsets(t *testing.T) {
+	server := newFeedServer(t)
+
+	tests := []struct {
+		name        string
+		path        string
+		wantTitle   string
+		wantItem    string
+		wantDetails string
+	}{
+		{
+			name:        "ISO-8859-1 declared in XML",
+			path:        "/feeds/iso-8859-1.xml",
+			wantTitle:   "Café Société",
+			wantItem:    "Crème brûlée",
+			wantDetails: "Über naïve façades",
+		},
+		{
+			name:        "Windows-1252 declared in XML",
+			path:        "/feeds/windows-1252.xml",
+			wantTitle:   "“Smart” Quotes",
+			wantItem:    "Price: 5 €",
+			wantDetails: "It’s… a dash — here",
+		},
+		{
+			name:        "Shift_JIS declared in XML",
+			path:        "/feeds/shift_jis.xml",
+			wantTitle:   "日本語のフィード",
+			wantItem:    "こんにちは",
+			wantDetails: "東京からのニュース",
+		},
+		{
+			name:        "ISO-8859-1 in Content-Type only",
+			path:        "/typed/latin1-undeclared.xml?type=application/rss%2Bxml%3B%20charset=ISO-8859-1",
+			wantTitle:   "Déjà vu",
+			wantItem:    "Señor",
+			wantDetails: "Años",
+		},
+		{
+			// The server's default charset, which the feed itself disagrees with
+			name:        "UTF-8 in Content-Type but ISO-8859-1 declared in XML",
+			path:        "/typed/iso-8859-1.xml?type=text/xml%3B%20charset=utf-8",
+			wantTitle:   "Café Société",
+			wantItem:    "Crème brûlée",
+			wantDetails: "Über naïve façades",
+		},
+		{
+			// NUL bytes in every other place, which aren't control characters
+			name:        "UTF-16 in Content-Type",
+			path:        "/typed/utf-16.xml?type=application/rss%2Bxml%3B%20charset=utf-16",
+			wantTitle:   "Café Société",
+			wantItem:    "Crème brûlée",
+			wantDetails: "Über naïve façades",
+		},
+		{
+			// The server has transcoded the feed without updating its declaration
+			name:        "UTF-8 in Content-Type overriding XML declaration",
+			path:        "/typed/transcoded.xml?type=application/xml%3B%20charset=utf-8",
+			wantTitle:   "Café Société",
+			wantItem:    "Crème brûlée",
+			wantDetails: "Über naïve façades",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			feed, _, err := fetchFeed(context.Background(), defaultFetchOptions(t), server.URL+tt.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if feed.Channel.Title != tt.wantTitle {
+				t.Errorf("got title %q, want %q", feed.Channel.Title, tt.wantTitle)
+			}
+			if len(feed.Channel.Item) == 0 {
+				t.Fatal("got no items")
+			}
+			if item := feed.Channel.Item[0]; item.Title != tt.wantItem || item.Description != tt.wantDetails {
+				t.Errorf("got item %q / %q, want %q / %q", item.Title, item.Description, tt.wantItem, tt.wantDetails)
+			}
+		})
+	}
+}
+
+func TestParseRSSLenient(t *testing.T) {
+	feed, err := parseRSS(readFixture(t, "sloppy.xml"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantTitles := []string{"AT&T\u00a0news © 2025", "Fine"}
+	if len(feed.Channel.Item) != len(wantTitles) {
+		t.Fatalf("got %d items, want %d", len(feed.Channel.Item), len(wantTitles))
+	}
+	for i, want := range wantTitles {
+		if got := feed.Channel.Item[i].Title; got != want {
+			t.Errorf("item %d: got title %q, want %q", i, got, want)
+		}
+	}
+	if want := "https://example.com/sloppy/1?a=1&b=2"; feed.Channel.Item[0].Link != want {
+		t.Errorf("got link %q, want %q", feed.Channel.Item[0].Link, want)
+	}
+
+	wantWarnings := []string{"Removed 2 control characters", "Skipped item 'No link here' with no link"}
+	if !slices.Equal(feed.Warnings, wantWarnings) {
+		t.Errorf("got warnings %q, want %q", feed.Warnings, wantWarnings)
+	}
+
+	feed, err = parseRSS(readFixture(t, "truncated.xml"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(feed.Channel.Item) != 2 || len(feed.Warnings) != 1 || !strings.HasPrefix(feed.Warnings[0], "Stopped parsing after 2 items") {
+		t.Errorf("got items %+v and warnings %q, want 2 items and a warning about stopping", feed.Channel.Item, feed.Warnings)
+	}
+
+	for _, notRSS := range []string{"", "{}", "<html><body>Not a feed</body></html>"} {
+		if _, err := parseRSS([]byte(notRSS), ""); err == nil {
+			t.Errorf("parsing %q: expected an error", notRSS)
+		}
+	}
+}
+
+func TestFetchFeedUnescapes(t *testing.T) {
+	server := newFeedServer(t)
+
+	feed, _, err := fetchFeed(context.Background(), defaultFetchOptions(t), server.URL+"/feeds/rss.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := "Posts for the test suite & friends"; feed.Channel.Description != want {
+		t.Errorf("got description %q, want %q", feed.Channel.Description, want)
+	}
+	if want := "The <b>first</b> post"; feed.Channel.Item[0].Description != want {
+		t.Errorf("got item description %q, want %q", feed.Channel.Item[0].Description, want)
+	}
+}
+
+func TestFetchFeedTimeout(t *testing.T) {
+	server := newFeedServer(t)
+
+	opts, err := newFetchOptions(config.FetchConfig{ResponseTimeout: "100ms"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	_, _, err = fetchFeed(context.Background(), opts, server.URL+"/slow")
+	if err == nil {
+		t.Fatal("expected an error fetching a feed that never responds")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("took %s to give up", elapsed)
+	}
+}
+
+func TestFetchFeedMaxBodySize(t *testing.T) {
+	server := newFeedServer(t)
+
+	size := int64(len(readFixture(t, "rss.xml")))
+	for _, maxBodySize := range []int64{size, size - 1} {
+		opts, err := newFetchOptions(config.FetchConfig{MaxBodySize: maxBodySize})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// The limit applies after decompression
+		_, _, err = fetchFeed(context.Background(), opts, server.URL+"/gzip")
+		if tooBig := maxBodySize < size; tooBig != (err != nil) {
+			t.Errorf("max body size %d for a %d byte feed: got error %v", maxBodySize, size, err)
+		}
+	}
+}
+
+func TestFetchFeedRedirects(t *testing.T) {
+	server := newFeedServer(t)
+
+	tests := []struct {
+		path        string
+		wantMovedTo string
+	}{
+		{path: "/feeds/rss.xml"},
+		{path: "/redirect"},
+		{path: "/moved", wantMovedTo: "/feeds/rss.xml"},
+		{path: "/moved-twice", wantMovedTo: "/feeds/rss.xml"},
+		// Moved, but to somewhere that isn't the feed's permanent home
+		{path: "/moved-then-redirect", wantMovedTo: "/redirect"},
+	}
+
+	for _, tt := range tests {
+		_, movedTo, err := fetchFeed(context.Background(), defaultFetchOptions(t), server.URL+tt.path)
+		if err != nil {
+			t.Errorf("%s: %v", tt.path, err)
+			continue
+		}
+
+		wantMovedTo := ""
+		if tt.wantMovedTo != "" {
+			wantMovedTo = server.URL + tt.wantMovedTo
+		}
+		if movedTo != wantMovedTo {
+			t.Errorf("%s: got moved to %q, want %q", tt.path, movedTo, wantMovedTo)
+		}
+	}
+
+	maxRedirects := func(max int) fetchOptions {
+		t.Helper()
+		opts, err := newFetchOptions(config.FetchConfig{MaxRedirects: &max})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return opts
+	}
+	if _, _, err := fetchFeed(context.Background(), maxRedirects(1), server.URL+"/moved-twice"); err == nil {
+		t.Error("expected an error following 2 redirects with a maximum of 1")
+	}
+	// Zero turns redirects off rather than meaning the default
+	if _, _, err := fetchFeed(context.Background(), maxRedirects(0), server.URL+"/redirect"); err == nil || !strings.Contains(err.Error(), "more than 0 redirects") {
+		t.Errorf("got error %v following a redirect with a maximum of 0", err)
+	}
+	if _, _, err := fetchFeed(context.Background(), maxRedirects(0), server.URL+"/feeds/rss.xml"); err != nil {
+		t.Errorf("fetching without redirects: %v", err)
+	}
+}
+
+func TestFetchFeedUserAgent(t *testing.T) {
+	server := newFeedServer(t)
+
+	tests := []struct {
+		cfg  config.FetchConfig
+		want string
+	}{
+		{cfg: config.FetchConfig{}, want: "gator/dev (+https://github.com/venzy/gator)"},
+		{cfg: config.FetchConfig{ContactURL: "mailto:me@example.com"}, want: "gator/dev (+mailto:me@example.com)"},
+		{cfg: config.FetchConfig{UserAgent: "MyReader/1.0"}, want: "MyReader/1.0"},
+	}
+
+	for _, tt := range tests {
+		opts, err := newFetchOptions(tt.cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		feed, _, err := fetchFeed(context.Background(), opts, server.URL+"/user-agent")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if feed.Channel.Title != tt.want {
+			t.Errorf("config %+v: got User-Agent %q, want %q", tt.cfg, feed.Channel.Title, tt.want)
+		}
+	}
+}
+
+func TestNewFetchOptionsInvalid(t *testing.T) {
+	negative := -1
+	for _, cfg := range []config.FetchConfig{
+		{ConnectTimeout: "soon"},
+		{ResponseTimeout: "10"},
+		{MaxRedirects: &negative},
+	} {
+		if _, err := newFetchOptions(cfg); err == nil {
+			t.Errorf("config %+v: expected an error", cfg)
+		}
+	}
+}
+
+func TestParseDateTime(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    time.Time
+		wantErr bool
+	}{
+		{input: "Mon, 06 Jan 2025 09:00:00 +0000", want: time.Date(2025, 1, 6, 9, 0, 0, 0, time.UTC)},
+		{input: "Mon, 06 Jan 2025 19:30:00 +1030", want: time.Date(2025, 1, 6, 9, 0, 0, 0, time.UTC)},
+		{input: "", wantErr: true},
+		{input: "yesterday", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseDateTime(tt.input)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseDateTime(%q): expected an error, got %v", tt.input, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseDateTime(%q): %v", tt.input, err)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("parseDateTime(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestScapeFeeds(t *testing.T) {
+	server := newFeedServer(t)
+
+	for name, newState := range testStates {
+		t.Run(name, func(t *testing.T) {
+			s := newState(t)
+			mustRun(t, s, "register", "alice")
+			mustRun(t, s, "addfeed", "Broken", server.URL+"/feeds/feed.json")
+			mustRun(t, s, "addfeed", "Test", server.URL+"/feeds/rss.xml")
+
+			// Feeds are fetched in turn, a broken one not holding up the rest
+			captureStdout(t, func() {
+				if err := scapeFeeds(context.Background(), s); err == nil {
+					t.Error("expected an error fetching the broken feed")
+				}
+				if err := scapeFeeds(context.Background(), s); err != nil {
+					t.Errorf("fetching the good feed: %v", err)
+				}
+			})
+
+			posts := runListing[postRecord](t, s, "browse", "10")
+			if len(posts) != 3 {
+				t.Fatalf("got %d posts, want 3: %+v", len(posts), posts)
+			}
+			// Newest first, the undated post counting as published when fetched
+			wantTitles := []string{"Undated Post", "Second Post", "First Post"}
+			for i, post := range posts {
+				if post.Title != wantTitles[i] {
+					t.Errorf("post %d: got title %q, want %q", i, post.Title, wantTitles[i])
+				}
+				if post.Feed != "Test" {
+					t.Errorf("post %d: got feed %q, want %q", i, post.Feed, "Test")
+				}
+			}
+
+			// Fetching again stores nothing new
+			captureStdout(t, func() {
+				scapeFeeds(context.Background(), s)
+				scapeFeeds(context.Background(), s)
+			})
+			if posts := runListing[postRecord](t, s, "browse", "10"); len(posts) != 3 {
+				t.Errorf("got %d posts after refetching, want 3", len(posts))
+			}
+		})
+	}
+}
+
+func TestScapeFeedsHuge(t *testing.T) {
+	server := newFeedServer(t)
+
+	s := newMemoryState(t)
+	mustRun(t, s, "register", "alice")
+	mustRun(t, s, "addfeed", "Huge", server.URL+"/huge")
+
+	if err := scapeFeeds(context.Background(), s); err != nil {
+		t.Fatal(err)
+	}
+
+	posts := runListing[postRecord](t, s, "browse", "100000")
+	if len(posts) != hugeFeedItems {
+		t.Errorf("got %d posts, want %d", len(posts), hugeFeedItems)
+	}
+}
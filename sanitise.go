@@ -0,0 +1,142 @@
+package main
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/microcosm-cc/bluemonday"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// What's left of publisher HTML once it's stored: formatting, links and
+// images, but no scripts, styles, iframes, forms or event handlers
+var descriptionPolicy = bluemonday.UGCPolicy()
+
+// Attributes holding URLs, which are made absolute so they still work when
+// the HTML is shown somewhere other than the publisher's site
+var urlAttributes = map[string]bool{
+	"href":   true,
+	"src":    true,
+	"cite":   true,
+	"poster": true,
+}
+
+// Sanitises a post's HTML, resolving relative URLs against base, and returns
+// it along with a plain text version for the terminal and searching
+func sanitiseDescription(description string, base *url.URL) (string, string) {
+	parent := &html.Node{Type: html.ElementNode, Data: "div", DataAtom: atom.Div}
+	nodes, err := html.ParseFragment(strings.NewReader(description), parent)
+	if err != nil {
+		// Only happens if reading fails, which a strings.Reader doesn't
+		return "", ""
+	}
+
+	var rendered strings.Builder
+	for _, node := range nodes {
+		if isTrackingPixel(node) {
+			continue
+		}
+		prepareNode(node, base)
+		html.Render(&rendered, node)
+	}
+	sanitised := strings.TrimSpace(descriptionPolicy.Sanitize(rendered.String()))
+
+	return sanitised, htmlToText(sanitised)
+}
+
+// Resolves URLs and takes out tracking pixels, which the sanitising policy
+// can't tell from other images
+func prepareNode(node *html.Node, base *url.URL) {
+	for child := node.FirstChild; child != nil; {
+		next := child.NextSibling
+		if isTrackingPixel(child) {
+			node.RemoveChild(child)
+		} else {
+			prepareNode(child, base)
+		}
+		child = next
+	}
+
+	if node.Type != html.ElementNode || base == nil {
+		return
+	}
+	for i, attr := range node.Attr {
+		if !urlAttributes[attr.Key] {
+			continue
+		}
+		if ref, err := url.Parse(strings.TrimSpace(attr.Val)); err == nil {
+			node.Attr[i].Val = base.ResolveReference(ref).String()
+		}
+	}
+}
+
+func isTrackingPixel(node *html.Node) bool {
+	if node.Type != html.ElementNode || node.DataAtom != atom.Img {
+		return false
+	}
+	for _, attr := range node.Attr {
+		if (attr.Key == "width" || attr.Key == "height") && (attr.Val == "0" || attr.Val == "1" || attr.Val == "1px") {
+			return true
+		}
+	}
+	return false
+}
+
+// Elements that start a new line in plain text
+var blockElements = map[atom.Atom]bool{
+	atom.P: true, atom.Div: true, atom.Br: true, atom.Li: true, atom.Tr: true,
+	atom.H1: true, atom.H2: true, atom.H3: true, atom.H4: true, atom.H5: true, atom.H6: true,
+	atom.Blockquote: true, atom.Pre: true, atom.Hr: true, atom.Table: true,
+	atom.Ul: true, atom.Ol: true, atom.Dl: true, atom.Dt: true, atom.Dd: true,
+	atom.Figure: true, atom.Figcaption: true,
+}
+
+// The text of some HTML, one line per paragraph (or list item etc.) with
+// whitespace collapsed
+func htmlToText(fragment string) string {
+	parent := &html.Node{Type: html.ElementNode, Data: "div", DataAtom: atom.Div}
+	nodes, err := html.ParseFragment(strings.NewReader(fragment), parent)
+	if err != nil {
+		return ""
+	}
+
+	var text strings.Builder
+	var walk func(node *html.Node)
+	walk = func(node *html.Node) {
+		switch {
+		case node.Type == html.TextNode:
+			// Line breaks in the source aren't line breaks on the page
+			text.WriteString(strings.NewReplacer("\r", " ", "\n", " ").Replace(node.Data))
+		case node.Type == html.ElementNode && node.DataAtom == atom.Img:
+			// Alt text stands in for the image
+			for _, attr := range node.Attr {
+				if attr.Key == "alt" && attr.Val != "" {
+					text.WriteString(" " + attr.Val + " ")
+				}
+			}
+		}
+
+		block := node.Type == html.ElementNode && blockElements[node.DataAtom]
+		if block {
+			text.WriteString("\n")
+		}
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+		if block {
+			text.WriteString("\n")
+		}
+	}
+	for _, node := range nodes {
+		walk(node)
+	}
+
+	var lines []string
+	for _, line := range strings.Split(text.String(), "\n") {
+		if line = strings.Join(strings.Fields(line), " "); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestSanitiseDescription(t *testing.T) {
+	base, err := url.Parse("https://example.com/blog/post")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		input    string
+		wantHTML string
+		wantText string
+	}{
+		{
+			name:     "plain text",
+			input:    "Just some text",
+			wantHTML: "Just some text",
+			wantText: "Just some text",
+		},
+		{
+			name:     "scripts and styles",
+			input:    `<p style="color: red">Hello<script>alert("hi")</script></p><style>p { display: none }</style>`,
+			wantHTML: `<p>Hello</p>`,
+			wantText: "Hello",
+		},
+		{
+			name:     "event handlers and javascript URLs",
+			input:    `<a href="javascript:alert(1)" onclick="alert(2)">Click</a>`,
+			wantHTML: `Click`,
+			wantText: "Click",
+		},
+		{
+			name:     "iframes and forms",
+			input:    `<iframe src="https://ads.example.net/"></iframe><form><input name="q"></form>Text`,
+			wantHTML: `Text`,
+			wantText: "Text",
+		},
+		{
+			name:     "relative links and images",
+			input:    `<a href="../about">About</a> <img src="photo.jpg" alt="Photo">`,
+			wantHTML: `<a href="https://example.com/about" rel="nofollow">About</a> <img src="https://example.com/blog/photo.jpg" alt="Photo"/>`,
+			wantText: "About Photo",
+		},
+		{
+			name:     "tracking pixels",
+			input:    `Text<img src="https://tracker.example.net/p.gif" width="1" height="1"><img src="https://example.com/t.gif" width="0">`,
+			wantHTML: `Text`,
+			wantText: "Text",
+		},
+		{
+			name:     "paragraphs and lists",
+			input:    "<h1>Title</h1><p>First\n   paragraph</p><ul><li>One</li><li>Two</li></ul>Last<br>line",
+			wantHTML: "<h1>Title</h1><p>First\n   paragraph</p><ul><li>One</li><li>Two</li></ul>Last<br/>line",
+			wantText: "Title\nFirst paragraph\nOne\nTwo\nLast\nline",
+		},
+		{
+			name:     "entities",
+			input:    "Fish &amp; chips&nbsp;&lt;3",
+			wantHTML: "Fish &amp; chips &lt;3",
+			wantText: "Fish & chips <3",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotHTML, gotText := sanitiseDescription(tt.input, base)
+			if gotHTML != tt.wantHTML {
+				t.Errorf("got HTML %q, want %q", gotHTML, tt.wantHTML)
+			}
+			if gotText != tt.wantText {
+				t.Errorf("got text %q, want %q", gotText, tt.wantText)
+			}
+		})
+	}
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/venzy/gator/internal/database"
+)
+
+// How long requests in progress get to finish when serve is stopped
+const serverShutdownTimeout = 10 * time.Second
+
+// Largest request body the API accepts - they're all small JSON objects
+const maxRequestBodySize = 1 << 20
+
+func handlerServe(s *state, cmd command) error {
+	publicURL := cmd.stringFlag("public-url")
+	if publicURL != "" {
+		parsed, err := url.Parse(publicURL)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return fmt.Errorf("Invalid public URL '%s': must be an http or https URL", publicURL)
+		}
+	}
+
+	listener, err := net.Listen("tcp", cmd.stringFlag("addr"))
+	if err != nil {
+		return fmt.Errorf("Problem listening on '%s': %v", cmd.stringFlag("addr"), err)
+	}
+
+	server := &http.Server{
+		Handler:           newServer(s),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Sends what WebSub pushes, and retries for agg and refresh too
+	go webhookLoop(ctx, s)
+
+	// Hubs need to reach us, so WebSub is only for servers that say where
+	if publicURL != "" {
+		go websubLoop(ctx, s, publicURL)
+		fmt.Printf("Subscribing to WebSub hubs with callbacks under %s/websub/\n", strings.TrimSuffix(publicURL, "/"))
+	}
+
+	served := make(chan error, 1)
+	go func() {
+		served <- server.Serve(listener)
+	}()
+	fmt.Printf("Serving the reader at http://%s/ and the API under /api/v1/\n", listener.Addr())
+
+	select {
+	case err := <-served:
+		return fmt.Errorf("Problem serving: %v", err)
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), serverShutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("Problem stopping server: %v", err)
+	}
+	fmt.Println("Stopped serving")
+	return nil
+}
+
+type server struct {
+	s        *state
+	mux      *http.ServeMux
+	favicons faviconCache
+}
+
+// The HTTP interface to everything, the API and the web reader, on the same
+// queries as the commands
+func newServer(s *state) http.Handler {
+	srv := &server{s: s, mux: http.NewServeMux()}
+	srv.registerAPIRoutes()
+	srv.registerReaderRoutes()
+	srv.registerFeverRoutes()
+	srv.registerGoogleReaderRoutes()
+	srv.registerShareRoutes()
+	srv.registerWebSubRoutes()
+	return srv.mux
+}
+
+// Failures with an HTTP status to report them with. Any other error from an
+// API handler is a 500.
+type apiError struct {
+	status  int
+	message string
+}
+
+func (e *apiError) Error() string {
+	return e.message
+}
+
+func badRequest(format string, args ...any) error {
+	return &apiError{status: http.StatusBadRequest, message: fmt.Sprintf(format, args...)}
+}
+
+func notFound(format string, args ...any) error {
+	return &apiError{status: http.StatusNotFound, message: fmt.Sprintf(format, args...)}
+}
+
+func conflict(format string, args ...any) error {
+	return &apiError{status: http.StatusConflict, message: fmt.Sprintf(format, args...)}
+}
+
+type apiHandler func(w http.ResponseWriter, r *http.Request, user database.User) error
+
+func unauthorised(format string, args ...any) error {
+	return &apiError{status: http.StatusUnauthorized, message: fmt.Sprintf(format, args...)}
+}
+
+func forbidden(format string, args ...any) error {
+	return &apiError{status: http.StatusForbidden, message: fmt.Sprintf(format, args...)}
+}
+
+// Who a request is from, going by its API token, which must also allow the
+// request - read tokens only allow looking
+func (srv *server) tokenUser(r *http.Request, token string) (database.User, error) {
+	return srv.scopedTokenUser(r, token, r.Method != http.MethodGet && r.Method != http.MethodHead)
+}
+
+// Like tokenUser, for requests whose method doesn't say whether they write
+func (srv *server) scopedTokenUser(r *http.Request, token string, writes bool) (database.User, error) {
+	if token == "" {
+		return database.User{}, unauthorised("An API token is needed - create one with '%s token create'", os.Args[0])
+	}
+
+	apiToken, err := srv.s.db.GetAPITokenByHash(r.Context(), hashToken(token))
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.User{}, unauthorised("Invalid API token")
+	} else if err != nil {
+		return database.User{}, fmt.Errorf("Problem checking API token: %v", err)
+	}
+
+	if writes && apiToken.Scope != scopeWrite {
+		return database.User{}, forbidden("API token '%s' is read-only", apiToken.Name)
+	}
+
+	return srv.tokenOwner(r, apiToken)
+}
+
+// The user an API token belongs to, noting that the token's been used
+func (srv *server) tokenOwner(r *http.Request, apiToken database.ApiToken) (database.User, error) {
+	user, err := srv.s.db.GetUserByID(r.Context(), apiToken.UserID)
+	if err != nil {
+		return database.User{}, fmt.Errorf("Problem fetching user for API token '%s': %v", apiToken.Name, err)
+	}
+
+	err = srv.s.db.MarkAPITokenUsed(
+		r.Context(),
+		database.MarkAPITokenUsedParams{
+			ID:         apiToken.ID,
+			LastUsedAt: sql.NullTime{Time: time.Now(), Valid: true},
+		})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Problem recording use of API token '%s': %v\n", apiToken.Name, err)
+	}
+
+	return user, nil
+}
+
+// The API's equivalent of withLoggedInUser, taking the user from the
+// request's bearer token, which also turns a handler's error into a JSON
+// error response
+func (srv *server) withUser(handler apiHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !found {
+			token = ""
+		}
+		user, err := srv.tokenUser(r, strings.TrimSpace(token))
+		if err == nil {
+			err = handler(w, r, user)
+		}
+		if err != nil {
+			var apiErr *apiError
+			if errors.As(err, &apiErr) && apiErr.status == http.StatusUnauthorized {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="gator"`)
+			}
+			writeError(w, err)
+		}
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		fmt.Fprintf(os.Stderr, "Problem writing response: %v\n", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	var apiErr *apiError
+	if errors.As(err, &apiErr) {
+		status = apiErr.status
+	} else {
+		fmt.Fprintf(os.Stderr, "Problem handling request: %v\n", err)
+	}
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+// Decodes a JSON request body, refusing fields we don't know so that typos
+// aren't silently ignored
+func decodeBody(w http.ResponseWriter, r *http.Request, value any) error {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBodySize))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(value); err != nil {
+		return badRequest("Invalid request body: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/venzy/gator/internal/database"
+)
+
+// Most posts in a shared feed - the newest ones
+const shareItemsLimit = 50
+
+func (srv *server) registerShareRoutes() {
+	srv.mux.HandleFunc("GET /shared/{token}/{format}", srv.sharedFeed)
+}
+
+type rssOutput struct {
+	XMLName xml.Name         `xml:"rss"`
+	Version string           `xml:"version,attr"`
+	Atom    string           `xml:"xmlns:atom,attr"`
+	Channel rssOutputChannel `xml:"channel"`
+}
+
+type rssOutputChannel struct {
+	Title         string          `xml:"title"`
+	Link          string          `xml:"link"`
+	Self          atomLink        `xml:"atom:link"`
+	Description   string          `xml:"description"`
+	LastBuildDate string          `xml:"lastBuildDate"`
+	Generator     string          `xml:"generator"`
+	Items         []rssOutputItem `xml:"item"`
+}
+
+type rssOutputItem struct {
+	Title       string          `xml:"title"`
+	Link        string          `xml:"link"`
+	Guid        string          `xml:"guid"`
+	PubDate     string          `xml:"pubDate"`
+	Description string          `xml:"description,omitempty"`
+	Source      rssOutputSource `xml:"source"`
+}
+
+type rssOutputSource struct {
+	Url  string `xml:"url,attr"`
+	Name string `xml:",chardata"`
+}
+
+type atomOutput struct {
+	XMLName   xml.Name          `xml:"http://www.w3.org/2005/Atom feed"`
+	ID        string            `xml:"id"`
+	Title     string            `xml:"title"`
+	Subtitle  string            `xml:"subtitle"`
+	Updated   string            `xml:"updated"`
+	Generator string            `xml:"generator"`
+	Links     []atomLink        `xml:"link"`
+	Entries   []atomOutputEntry `xml:"entry"`
+}
+
+type atomOutputEntry struct {
+	ID        string          `xml:"id"`
+	Title     string          `xml:"title"`
+	Links     []atomLink      `xml:"link"`
+	Published string          `xml:"published"`
+	Updated   string          `xml:"updated"`
+	Author    atomOutputName  `xml:"author"`
+	Content   *atomOutputText `xml:"content,omitempty"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomOutputName struct {
+	Name string `xml:"name"`
+}
+
+type atomOutputText struct {
+	Type string `xml:"type,attr"`
+	Text string `xml:",chardata"`
+}
+
+// A share's posts as RSS 2.0 or Atom. Anyone with the URL can read it, so
+// there's no other auth, and errors give nothing away.
+func (srv *server) sharedFeed(w http.ResponseWriter, r *http.Request) {
+	format := r.PathValue("format")
+	if format != "rss" && format != "atom" {
+		http.NotFound(w, r)
+		return
+	}
+
+	share, err := srv.s.db.GetShareByHash(r.Context(), hashToken(r.PathValue("token")))
+	if errors.Is(err, sql.ErrNoRows) {
+		http.NotFound(w, r)
+		return
+	}
+	if err == nil {
+		err = srv.writeSharedFeed(w, r, share, format)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Problem serving share '%s': %v\n", share.Name, err)
+		http.Error(w, "Problem generating feed", http.StatusInternalServerError)
+	}
+}
+
+func (srv *server) writeSharedFeed(w http.ResponseWriter, r *http.Request, share database.Share, format string) error {
+	user, err := srv.s.db.GetUserByID(r.Context(), share.UserID)
+	if err != nil {
+		return fmt.Errorf("Problem fetching user: %v", err)
+	}
+	if err := srv.s.db.NumberNewFeeds(r.Context()); err != nil {
+		return fmt.Errorf("Problem numbering feeds: %v", err)
+	}
+	if err := srv.s.db.NumberNewPosts(r.Context()); err != nil {
+		return fmt.Errorf("Problem numbering posts: %v", err)
+	}
+	posts, err := srv.s.db.GetNumberedPostsForUser(
+		r.Context(),
+		database.GetNumberedPostsForUserParams{
+			UserID:      share.UserID,
+			Folder:      share.Folder,
+			Tag:         share.Tag,
+			StarredOnly: share.Starred,
+			RowLimit:    shareItemsLimit,
+		})
+	if err != nil {
+		return fmt.Errorf("Problem fetching posts: %v", err)
+	}
+
+	// The newest post's time, so feed readers see no change when there's none.
+	// Posts come in the order they were fetched, which needn't be by date.
+	updated := share.CreatedAt
+	for i, post := range posts {
+		if i == 0 || post.PublishedAt.After(updated) {
+			updated = post.PublishedAt
+		}
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	self := fmt.Sprintf("%s://%s%s", scheme, r.Host, r.URL.Path)
+	title := fmt.Sprintf("%s: %s", user.Name, share.Name)
+	description := shareDescription(user, share)
+
+	var output any
+	if format == "rss" {
+		channel := rssOutputChannel{
+			Title:         title,
+			Link:          self,
+			Self:          atomLink{Href: self, Rel: "self", Type: "application/rss+xml"},
+			Description:   description,
+			LastBuildDate: updated.UTC().Format(time.RFC1123Z),
+			Generator:     "gator/" + version,
+			Items:         []rssOutputItem{},
+		}
+		for _, post := range posts {
+			channel.Items = append(channel.Items, rssOutputItem{
+				Title:       post.Title,
+				Link:        post.Url,
+				Guid:        post.Url,
+				PubDate:     post.PublishedAt.UTC().Format(time.RFC1123Z),
+				Description: descriptionPolicy.Sanitize(post.Description.String),
+				Source:      rssOutputSource{Url: post.FeedUrl, Name: post.FeedName},
+			})
+		}
+		output = rssOutput{Version: "2.0", Atom: "http://www.w3.org/2005/Atom", Channel: channel}
+		w.Header().Set("Content-Type", "application/rss+xml; charset=utf-8")
+	} else {
+		feed := atomOutput{
+			ID:        "urn:uuid:" + share.ID.String(),
+			Title:     title,
+			Subtitle:  description,
+			Updated:   updated.UTC().Format(time.RFC3339),
+			Generator: "gator/" + version,
+			Links:     []atomLink{{Href: self, Rel: "self", Type: "application/atom+xml"}},
+		}
+		for _, post := range posts {
+			entry := atomOutputEntry{
+				ID:        "urn:uuid:" + post.ID.String(),
+				Title:     post.Title,
+				Links:     []atomLink{{Href: post.Url, Rel: "alternate", Type: "text/html"}},
+				Published: post.PublishedAt.UTC().Format(time.RFC3339),
+				Updated:   post.PublishedAt.UTC().Format(time.RFC3339),
+				Author:    atomOutputName{Name: post.FeedName},
+			}
+			if post.Description.Valid {
+				entry.Content = &atomOutputText{Type: "html", Text: descriptionPolicy.Sanitize(post.Description.String)}
+			}
+			feed.Entries = append(feed.Entries, entry)
+		}
+		output = feed
+		w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+	}
+
+	fmt.Fprint(w, xml.Header)
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(output); err != nil {
+		// Too late for an error response, with the headers sent
+		fmt.Fprintf(os.Stderr, "Problem writing share '%s': %v\n", share.Name, err)
+	}
+	return nil
+}
+
+// What's in a share, in words
+func shareDescription(user database.User, share database.Share) string {
+	var filters []string
+	if share.Starred {
+		filters = append(filters, "starred")
+	}
+	if share.Folder.Valid {
+		filters = append(filters, fmt.Sprintf("in folder '%s'", share.Folder.String))
+	}
+	if share.Tag.Valid {
+		filters = append(filters, fmt.Sprintf("tagged '%s'", share.Tag.String))
+	}
+	if len(filters) == 0 {
+		return fmt.Sprintf("Posts from the feeds %s follows, shared from gator", user.Name)
+	}
+	return fmt.Sprintf("Posts from the feeds %s follows, %s, shared from gator", user.Name, strings.Join(filters, ", "))
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+var shareURLPattern = regexp.MustCompile(`/shared/\S+/rss`)
+
+// Fetches a shared feed, returning its body and status
+func getShared(t *testing.T, url string) (string, int) {
+	t.Helper()
+
+	res, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(body), res.StatusCode
+}
+
+func TestShares(t *testing.T) {
+	feeds := newFeedServer(t)
+
+	for name, newState := range testStates {
+		t.Run(name, func(t *testing.T) {
+			s := newState(t)
+			mustRun(t, s, "register", "alice")
+			mustRun(t, s, "addfeed", "RSS", feeds.URL+"/feeds/rss.xml")
+			mustRun(t, s, "addfeed", "HTML", feeds.URL+"/feeds/html.xml")
+			mustRun(t, s, "folder", feeds.URL+"/feeds/html.xml", "Blogs")
+			// One feed at a time
+			captureStdout(t, func() { scapeFeeds(context.Background(), s) })
+			captureStdout(t, func() { scapeFeeds(context.Background(), s) })
+			api := httptest.NewServer(newServer(s))
+			defer api.Close()
+
+			everything := api.URL + shareURLPattern.FindString(mustRun(t, s, "share", "create", "everything"))
+			blogs := api.URL + shareURLPattern.FindString(mustRun(t, s, "share", "create", "--folder", "Blogs", "blogs"))
+			starred := api.URL + shareURLPattern.FindString(mustRun(t, s, "share", "create", "--starred", "starred"))
+			if _, err := runCommand(t, s, "share", "create", "everything"); err == nil {
+				t.Error("created a second share with the same name")
+			}
+
+			body, status := getShared(t, everything)
+			if status != http.StatusOK {
+				t.Fatalf("got status %d for a shared feed", status)
+			}
+			all, err := parseRSS([]byte(body), "application/rss+xml")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(all.Channel.Item) == 0 || all.Channel.Title != "alice: everything" {
+				t.Fatalf("got %+v, want alice's posts", all.Channel)
+			}
+
+			// The HTML feed was fetched last but its post is older, so the
+			// feed's time is the newest post's rather than the first listed
+			var built rssOutput
+			if err := xml.Unmarshal([]byte(body), &built); err != nil {
+				t.Fatal(err)
+			}
+			newest := all.Channel.Item[0].PubDate
+			for _, item := range all.Channel.Item {
+				if parseShareTime(t, item.PubDate).After(parseShareTime(t, newest)) {
+					newest = item.PubDate
+				}
+			}
+			if built.Channel.LastBuildDate != newest || newest == all.Channel.Item[0].PubDate {
+				t.Errorf("got lastBuildDate %s with the first post from %s, want the newest, %s", built.Channel.LastBuildDate, all.Channel.Item[0].PubDate, newest)
+			}
+
+			body, _ = getShared(t, blogs)
+			folder, err := parseRSS([]byte(body), "application/rss+xml")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(folder.Channel.Item) == 0 || len(folder.Channel.Item) >= len(all.Channel.Item) {
+				t.Errorf("got %d posts in the Blogs share of %d, want some but not all", len(folder.Channel.Item), len(all.Channel.Item))
+			}
+
+			mustRun(t, s, "star", all.Channel.Item[0].Link)
+			body, _ = getShared(t, strings.TrimSuffix(starred, "/rss")+"/atom")
+			var atom atomOutput
+			if err := xml.Unmarshal([]byte(body), &atom); err != nil {
+				t.Fatalf("problem parsing Atom %q: %v", body, err)
+			}
+			if len(atom.Entries) != 1 || atom.Entries[0].Links[0].Href != all.Channel.Item[0].Link {
+				t.Errorf("got %+v, want just the starred post", atom.Entries)
+			}
+
+			if shares := runListing[shareRecord](t, s, "share", "list"); len(shares) != 3 || shares[0].Folder != "Blogs" {
+				t.Errorf("got shares %+v, want three, blogs first", shares)
+			}
+			mustRun(t, s, "share", "revoke", "blogs")
+			if _, status := getShared(t, blogs); status != http.StatusNotFound {
+				t.Errorf("got status %d for a revoked share, want 404", status)
+			}
+			if _, status := getShared(t, strings.TrimSuffix(everything, "/rss")+"/json"); status != http.StatusNotFound {
+				t.Errorf("got status %d for an unknown format, want 404", status)
+			}
+		})
+	}
+}
+
+// Parses a shared RSS feed's dates
+func parseShareTime(t *testing.T, value string) time.Time {
+	t.Helper()
+
+	parsed, err := time.Parse(time.RFC1123Z, value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parsed
+}
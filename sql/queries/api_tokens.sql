@@ -0,0 +1,29 @@
+-- name: CreateAPIToken :one
+INSERT INTO api_tokens (id, created_at, user_id, name, token_hash, scope, fever_key)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7
+)
+RETURNING *;
+
+-- name: GetAPITokensForUser :many
+SELECT * FROM api_tokens
+    WHERE user_id = $1
+    ORDER BY name;
+
+-- name: DeleteAPIToken :execrows
+DELETE FROM api_tokens WHERE user_id = $1 AND name = $2;
+
+-- name: GetAPITokenByHash :one
+SELECT * FROM api_tokens WHERE token_hash = $1;
+
+-- name: GetAPITokenByFeverKey :one
+SELECT * FROM api_tokens WHERE fever_key = $1;
+
+-- name: MarkAPITokenUsed :exec
+UPDATE api_tokens SET last_used_at = $2 WHERE id = $1;
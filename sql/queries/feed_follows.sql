@@ -16,11 +16,38 @@ INNER JOIN feeds ON feeds.id = inserted_feed_follow.feed_id
 INNER JOIN users ON users.id = inserted_feed_follow.user_id;
 
 -- name: GetFeedFollowsForUser :many
-SELECT feed_follows.*, feeds.name AS feed_name, users.name AS user_name
+SELECT feed_follows.*, feeds.name AS feed_name, feeds.url AS feed_url, users.name AS user_name
 FROM feed_follows
 INNER JOIN feeds ON feeds.id = feed_follows.feed_id
 INNER JOIN users ON users.id = feed_follows.user_id
 WHERE feed_follows.user_id = $1;
 
 -- name: DeleteFeedFollow :exec
-DELETE FROM feed_follows WHERE user_id = $1 and feed_id = $2;
\ No newline at end of file
+DELETE FROM feed_follows WHERE user_id = $1 and feed_id = $2;
+
+-- name: SetFeedFollowFolder :execrows
+UPDATE feed_follows
+    SET folder = $3, updated_at = $4
+    WHERE user_id = $1 AND feed_id = $2;
+
+-- name: GetFoldersForUser :many
+SELECT DISTINCT folder::varchar FROM feed_follows
+    WHERE user_id = $1 AND folder IS NOT NULL
+    ORDER BY folder;
+
+-- name: GetFollowedFeedsWithUnreadCounts :many
+SELECT
+    feeds.id,
+    feeds.name,
+    feeds.url,
+    feed_follows.folder,
+    (SELECT COUNT(*) FROM posts
+        WHERE posts.feed_id = feeds.id
+            AND NOT EXISTS (
+                SELECT 1 FROM post_reads
+                WHERE post_reads.post_id = posts.id AND post_reads.user_id = feed_follows.user_id)
+    ) AS unread_count
+FROM feed_follows
+INNER JOIN feeds ON feeds.id = feed_follows.feed_id
+WHERE feed_follows.user_id = $1
+ORDER BY COALESCE(feed_follows.folder, ''), feeds.name;
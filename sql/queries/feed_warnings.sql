@@ -0,0 +1,18 @@
+-- name: CreateFeedWarning :exec
+INSERT INTO feed_warnings (id, created_at, feed_id, message)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4
+);
+
+-- name: DeleteFeedWarnings :exec
+DELETE FROM feed_warnings WHERE feed_id = $1;
+
+-- name: GetFeedWarningsForUser :many
+SELECT feed_warnings.*, feeds.name AS feed_name, feeds.url AS feed_url FROM feed_warnings
+    INNER JOIN feeds ON feeds.id = feed_warnings.feed_id
+    INNER JOIN feed_follows ON feed_follows.feed_id = feed_warnings.feed_id
+    WHERE feed_follows.user_id = $1
+    ORDER BY feeds.name, feed_warnings.created_at;
@@ -16,10 +16,35 @@ SELECT * FROM feeds;
 -- name: GetFeedByURL :one
 SELECT * FROM feeds WHERE url = $1;
 
+-- name: GetFeedByID :one
+SELECT * FROM feeds WHERE id = $1;
+
 -- name: GetNextFeedToFetch :one
-SELECT * FROM feeds ORDER BY last_fetched_at ASC NULLS FIRST, created_at ASC LIMIT 1;
+-- Feeds being pushed to us don't need polling
+SELECT * FROM feeds
+    WHERE NOT EXISTS (
+        SELECT 1 FROM websub_subscriptions
+            WHERE websub_subscriptions.feed_id = feeds.id
+                AND websub_subscriptions.expires_at > @now)
+    ORDER BY last_fetched_at ASC NULLS FIRST, created_at ASC LIMIT 1;
 
 -- name: MarkFeedFetched :exec
 UPDATE feeds
     SET last_fetched_at = $2, updated_at = $2
-    WHERE id = $1;
\ No newline at end of file
+    WHERE id = $1;
+-- name: GetFeedsDue :many
+SELECT * FROM feeds
+    WHERE last_fetched_at IS NULL OR last_fetched_at < @fetched_before
+    ORDER BY last_fetched_at ASC NULLS FIRST, created_at ASC;
+
+-- name: GetFeedsDueForUser :many
+SELECT feeds.* FROM feeds
+    INNER JOIN feed_follows ON feed_follows.feed_id = feeds.id
+    WHERE feed_follows.user_id = @user_id
+        AND (feeds.last_fetched_at IS NULL OR feeds.last_fetched_at < @fetched_before)
+    ORDER BY feeds.last_fetched_at ASC NULLS FIRST, feeds.created_at ASC;
+
+-- name: UpdateFeedURL :exec
+UPDATE feeds
+    SET url = $2, updated_at = $3
+    WHERE id = $1;
@@ -0,0 +1,24 @@
+-- name: CountFeverItems :one
+SELECT COUNT(*) FROM posts
+    INNER JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
+    INNER JOIN post_numbers ON post_numbers.post_id = posts.id
+    WHERE feed_follows.user_id = $1;
+
+-- name: GetUnreadPostNumbers :many
+SELECT post_numbers.number FROM posts
+    INNER JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
+    INNER JOIN post_numbers ON post_numbers.post_id = posts.id
+    WHERE feed_follows.user_id = $1
+        AND NOT EXISTS (
+            SELECT 1 FROM post_reads
+                WHERE post_reads.user_id = feed_follows.user_id AND post_reads.post_id = posts.id
+        )
+    ORDER BY post_numbers.number;
+
+-- name: GetStarredPostNumbers :many
+SELECT post_numbers.number FROM posts
+    INNER JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
+    INNER JOIN post_numbers ON post_numbers.post_id = posts.id
+    INNER JOIN post_stars ON post_stars.post_id = posts.id AND post_stars.user_id = feed_follows.user_id
+    WHERE feed_follows.user_id = $1
+    ORDER BY post_numbers.number;
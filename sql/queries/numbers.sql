@@ -0,0 +1,68 @@
+-- name: NumberNewPosts :exec
+INSERT INTO post_numbers (post_id)
+SELECT posts.id FROM posts
+    WHERE NOT EXISTS (SELECT 1 FROM post_numbers WHERE post_numbers.post_id = posts.id)
+    ORDER BY posts.created_at, posts.id
+ON CONFLICT (post_id) DO NOTHING;
+
+-- name: NumberNewFeeds :exec
+INSERT INTO feed_numbers (feed_id)
+SELECT feeds.id FROM feeds
+    WHERE NOT EXISTS (SELECT 1 FROM feed_numbers WHERE feed_numbers.feed_id = feeds.id)
+    ORDER BY feeds.created_at, feeds.id
+ON CONFLICT (feed_id) DO NOTHING;
+
+-- name: GetPostIDByNumber :one
+SELECT post_id FROM post_numbers WHERE number = $1;
+
+-- name: GetFeedIDByNumber :one
+SELECT feed_id FROM feed_numbers WHERE number = $1;
+
+-- name: GetNumberedFeedsForUser :many
+SELECT feed_numbers.number, feeds.name, feeds.url, feeds.last_fetched_at, feed_follows.folder
+FROM feed_follows
+    INNER JOIN feeds ON feeds.id = feed_follows.feed_id
+    INNER JOIN feed_numbers ON feed_numbers.feed_id = feeds.id
+    WHERE feed_follows.user_id = $1
+    ORDER BY feed_numbers.number;
+
+-- name: GetNumberedPostsForUser :many
+-- Posts with their numbers, for the APIs that use them, filtered the ways
+-- those APIs ask for and ordered by number
+SELECT posts.id, post_numbers.number, feed_numbers.number AS feed_number,
+    feeds.name AS feed_name, feeds.url AS feed_url, feed_follows.folder,
+    posts.title, posts.url, posts.description, posts.published_at, posts.created_at,
+    EXISTS (
+        SELECT 1 FROM post_reads
+            WHERE post_reads.user_id = feed_follows.user_id AND post_reads.post_id = posts.id
+    ) AS is_read,
+    EXISTS (
+        SELECT 1 FROM post_stars
+            WHERE post_stars.user_id = feed_follows.user_id AND post_stars.post_id = posts.id
+    ) AS is_starred
+FROM posts
+    INNER JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
+    INNER JOIN feeds ON feeds.id = posts.feed_id
+    INNER JOIN post_numbers ON post_numbers.post_id = posts.id
+    INNER JOIN feed_numbers ON feed_numbers.feed_id = posts.feed_id
+    WHERE feed_follows.user_id = sqlc.arg('user_id')
+        AND (sqlc.narg('feed_id')::uuid IS NULL OR posts.feed_id = sqlc.narg('feed_id'))
+        AND (sqlc.narg('folder')::varchar IS NULL OR feed_follows.folder = sqlc.narg('folder'))
+        AND (sqlc.narg('tag')::varchar IS NULL OR EXISTS (
+            SELECT 1 FROM post_tags
+                WHERE post_tags.post_id = posts.id
+                    AND post_tags.user_id = feed_follows.user_id
+                    AND post_tags.tag = sqlc.narg('tag')))
+        AND (sqlc.narg('read')::boolean IS NULL OR sqlc.narg('read') = EXISTS (
+            SELECT 1 FROM post_reads
+                WHERE post_reads.user_id = feed_follows.user_id AND post_reads.post_id = posts.id))
+        AND (NOT sqlc.arg('starred_only')::boolean OR EXISTS (
+            SELECT 1 FROM post_stars
+                WHERE post_stars.user_id = feed_follows.user_id AND post_stars.post_id = posts.id))
+        AND (sqlc.narg('published_since')::timestamp IS NULL OR posts.published_at >= sqlc.narg('published_since'))
+        AND (sqlc.narg('published_until')::timestamp IS NULL OR posts.published_at < sqlc.narg('published_until'))
+        AND (sqlc.narg('since_number')::bigint IS NULL OR post_numbers.number > sqlc.narg('since_number'))
+        AND (sqlc.narg('max_number')::bigint IS NULL OR post_numbers.number < sqlc.narg('max_number'))
+        AND (sqlc.narg('number')::bigint IS NULL OR post_numbers.number = sqlc.narg('number'))
+    ORDER BY CASE WHEN sqlc.arg('ascending')::boolean THEN post_numbers.number ELSE -post_numbers.number END
+    LIMIT sqlc.arg('row_limit');
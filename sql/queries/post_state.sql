@@ -0,0 +1,25 @@
+-- name: MarkPostRead :exec
+INSERT INTO post_reads (id, created_at, user_id, post_id)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4
+)
+ON CONFLICT (user_id, post_id) DO NOTHING;
+
+-- name: MarkPostUnread :execrows
+DELETE FROM post_reads WHERE user_id = $1 AND post_id = $2;
+
+-- name: StarPost :exec
+INSERT INTO post_stars (id, created_at, user_id, post_id)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4
+)
+ON CONFLICT (user_id, post_id) DO NOTHING;
+
+-- name: UnstarPost :execrows
+DELETE FROM post_stars WHERE user_id = $1 AND post_id = $2;
@@ -0,0 +1,24 @@
+-- name: CreatePostTag :exec
+INSERT INTO post_tags (id, created_at, user_id, post_id, tag)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5
+)
+ON CONFLICT (user_id, post_id, tag) DO NOTHING;
+
+-- name: DeletePostTag :execrows
+DELETE FROM post_tags WHERE user_id = $1 AND post_id = $2 AND tag = $3;
+
+-- name: GetTagsForPost :many
+SELECT tag FROM post_tags
+    WHERE user_id = $1 AND post_id = $2
+    ORDER BY tag;
+
+-- name: GetTagCountsForUser :many
+SELECT tag, COUNT(*) AS post_count FROM post_tags
+    WHERE user_id = $1
+    GROUP BY tag
+    ORDER BY tag;
@@ -1,5 +1,5 @@
 -- name: CreatePost :one
-INSERT INTO posts (id, created_at, updated_at, title, url, description, published_at, feed_id)
+INSERT INTO posts (id, created_at, updated_at, title, url, description, published_at, feed_id, description_text)
 VALUES (
     $1,
     $2,
@@ -8,8 +8,10 @@ VALUES (
     $5,
     $6,
     $7,
-    $8
+    $8,
+    $9
 )
+ON CONFLICT (url) DO NOTHING
 RETURNING *;
 
 -- name: GetPostsForUser :many
@@ -18,3 +20,54 @@ SELECT posts.*, feeds.name AS feed_name FROM posts
     INNER JOIN feeds ON feeds.id = posts.feed_id
     WHERE feed_follows.user_id = $1
     ORDER BY published_at DESC LIMIT $2;
+
+-- name: GetPostByURL :one
+SELECT * FROM posts WHERE url = $1;
+
+-- name: BrowsePostsForUser :many
+SELECT
+    posts.*,
+    feeds.name AS feed_name,
+    feed_follows.folder,
+    (post_reads.id IS NOT NULL)::boolean AS is_read,
+    (post_stars.id IS NOT NULL)::boolean AS is_starred,
+    (CASE WHEN @sort_by_fetched::boolean THEN posts.created_at ELSE posts.published_at END)::timestamp AS sort_time
+FROM posts
+    INNER JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
+    INNER JOIN feeds ON feeds.id = posts.feed_id
+    LEFT JOIN post_reads ON post_reads.post_id = posts.id AND post_reads.user_id = feed_follows.user_id
+    LEFT JOIN post_stars ON post_stars.post_id = posts.id AND post_stars.user_id = feed_follows.user_id
+WHERE feed_follows.user_id = @user_id
+    AND (sqlc.narg('feed_url')::varchar IS NULL OR feeds.url = sqlc.narg('feed_url'))
+    AND (sqlc.narg('folder')::varchar IS NULL OR feed_follows.folder = sqlc.narg('folder'))
+    AND (sqlc.narg('since')::timestamp IS NULL OR posts.published_at >= sqlc.narg('since'))
+    AND (sqlc.narg('until')::timestamp IS NULL OR posts.published_at < sqlc.narg('until'))
+    AND (NOT @unread_only::boolean OR post_reads.id IS NULL)
+    AND (NOT @starred_only::boolean OR post_stars.id IS NOT NULL)
+    AND (sqlc.narg('tag')::varchar IS NULL OR EXISTS (
+        SELECT 1 FROM post_tags
+        WHERE post_tags.post_id = posts.id
+            AND post_tags.user_id = feed_follows.user_id
+            AND post_tags.tag = sqlc.narg('tag')))
+    -- A LIKE pattern, matched against the title and plain text
+    AND (sqlc.narg('search')::varchar IS NULL
+        OR posts.title ILIKE sqlc.narg('search') ESCAPE '\'
+        OR posts.description_text ILIKE sqlc.narg('search') ESCAPE '\')
+    -- Keyset pagination: strictly after the (sort_time, id) of the last row of the previous page
+    AND (sqlc.narg('after_time')::timestamp IS NULL
+        OR (CASE WHEN @sort_by_fetched::boolean THEN posts.created_at ELSE posts.published_at END, posts.id)
+            < (sqlc.narg('after_time')::timestamp, sqlc.narg('after_id')::uuid))
+ORDER BY sort_time DESC, posts.id DESC
+LIMIT @row_limit;
+
+-- name: GetPostForUser :one
+SELECT
+    posts.*,
+    feeds.name AS feed_name,
+    feed_follows.folder,
+    EXISTS (SELECT 1 FROM post_reads WHERE post_reads.post_id = posts.id AND post_reads.user_id = feed_follows.user_id) AS is_read,
+    EXISTS (SELECT 1 FROM post_stars WHERE post_stars.post_id = posts.id AND post_stars.user_id = feed_follows.user_id) AS is_starred
+FROM posts
+    INNER JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
+    INNER JOIN feeds ON feeds.id = posts.feed_id
+WHERE feed_follows.user_id = $1 AND posts.id = $2;
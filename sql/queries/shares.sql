@@ -0,0 +1,24 @@
+-- name: CreateShare :one
+INSERT INTO shares (id, created_at, user_id, name, token_hash, folder, tag, starred)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7,
+    $8
+)
+RETURNING *;
+
+-- name: GetSharesForUser :many
+SELECT * FROM shares
+    WHERE user_id = $1
+    ORDER BY name;
+
+-- name: DeleteShare :execrows
+DELETE FROM shares WHERE user_id = $1 AND name = $2;
+
+-- name: GetShareByHash :one
+SELECT * FROM shares WHERE token_hash = $1;
@@ -1,10 +1,11 @@
 -- name: CreateUser :one
-INSERT INTO users (id, created_at, updated_at, name)
+INSERT INTO users (id, created_at, updated_at, name, password_hash)
 VALUES (
     $1,
     $2,
     $3,
-    $4
+    $4,
+    $5
 )
 RETURNING *;
 
@@ -18,4 +19,25 @@ SELECT * FROM users WHERE id = $1;
 SELECT * FROM users;
 
 -- name: ResetUsers :exec
-DELETE FROM users;
\ No newline at end of file
+DELETE FROM users;
+
+-- name: SetUserPassword :exec
+UPDATE users SET password_hash = $2, updated_at = $3 WHERE id = $1;
+
+-- name: CreateUserSession :one
+INSERT INTO user_sessions (id, created_at, user_id, token_hash)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4
+)
+RETURNING *;
+
+-- name: GetUserBySession :one
+SELECT users.* FROM user_sessions
+    INNER JOIN users ON users.id = user_sessions.user_id
+    WHERE user_sessions.token_hash = $1;
+
+-- name: DeleteUserSessions :exec
+DELETE FROM user_sessions WHERE user_id = $1;
@@ -0,0 +1,81 @@
+-- name: CreateWebhook :one
+INSERT INTO webhooks (id, created_at, user_id, name, url, feed_id, folder, match, template, secret)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7,
+    $8,
+    $9,
+    $10
+)
+RETURNING *;
+
+-- name: GetWebhooksForUser :many
+SELECT * FROM webhooks
+    WHERE user_id = $1
+    ORDER BY name;
+
+-- name: GetWebhookByName :one
+SELECT * FROM webhooks WHERE user_id = $1 AND name = $2;
+
+-- name: DeleteWebhook :execrows
+DELETE FROM webhooks WHERE user_id = $1 AND name = $2;
+
+-- name: GetWebhooksForFeed :many
+-- Those of users following the feed, whose filters let its posts through
+SELECT webhooks.* FROM webhooks
+    INNER JOIN feed_follows ON feed_follows.user_id = webhooks.user_id
+    WHERE feed_follows.feed_id = @feed_id
+        AND (webhooks.feed_id IS NULL OR webhooks.feed_id = @feed_id)
+        AND (webhooks.folder IS NULL OR webhooks.folder = feed_follows.folder)
+    ORDER BY webhooks.created_at;
+
+-- name: CreateWebhookDelivery :one
+INSERT INTO webhook_deliveries (id, created_at, webhook_id, post_id, payload, attempts, next_attempt_at)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7
+)
+RETURNING *;
+
+-- name: UpdateWebhookDelivery :exec
+UPDATE webhook_deliveries
+    SET attempts = $2, last_status = $3, last_error = $4, delivered_at = $5, next_attempt_at = $6
+    WHERE id = $1;
+
+-- name: GetDueWebhookDeliveries :many
+SELECT webhook_deliveries.*, webhooks.name AS webhook_name, webhooks.url, webhooks.secret
+FROM webhook_deliveries
+    INNER JOIN webhooks ON webhooks.id = webhook_deliveries.webhook_id
+    WHERE webhook_deliveries.next_attempt_at <= @now
+    ORDER BY webhook_deliveries.next_attempt_at
+    LIMIT @row_limit;
+
+-- name: ClaimWebhookDelivery :execrows
+-- Puts off a due delivery's next attempt while it's being sent, so nothing
+-- else sending due deliveries sends it too
+UPDATE webhook_deliveries
+    SET next_attempt_at = @claimed_until
+    WHERE id = @id AND next_attempt_at <= @now;
+
+-- name: GetWebhookDeliveriesForUser :many
+-- The newest first, optionally for just one webhook
+SELECT webhook_deliveries.id, webhook_deliveries.created_at, webhooks.name AS webhook_name,
+    posts.title AS post_title, webhook_deliveries.attempts, webhook_deliveries.last_status,
+    webhook_deliveries.last_error, webhook_deliveries.delivered_at, webhook_deliveries.next_attempt_at
+FROM webhook_deliveries
+    INNER JOIN webhooks ON webhooks.id = webhook_deliveries.webhook_id
+    LEFT JOIN posts ON posts.id = webhook_deliveries.post_id
+    WHERE webhooks.user_id = @user_id
+        AND (webhooks.name = @webhook_name OR @webhook_name = '')
+    ORDER BY webhook_deliveries.created_at DESC
+    LIMIT @row_limit;
@@ -0,0 +1,37 @@
+-- name: SetFeedHub :exec
+UPDATE feeds
+    SET hub_url = $2, topic_url = $3
+    WHERE id = $1;
+
+-- name: GetFeedsWithHubs :many
+SELECT * FROM feeds
+    WHERE hub_url IS NOT NULL AND topic_url IS NOT NULL
+    ORDER BY created_at;
+
+-- name: CreateWebSubSubscription :one
+INSERT INTO websub_subscriptions (id, created_at, updated_at, feed_id, hub_url, topic_url, secret, state)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7,
+    $8
+)
+RETURNING *;
+
+-- name: GetWebSubSubscription :one
+SELECT * FROM websub_subscriptions WHERE id = $1;
+
+-- name: GetWebSubSubscriptionForFeed :one
+SELECT * FROM websub_subscriptions WHERE feed_id = $1;
+
+-- name: UpdateWebSubSubscription :exec
+UPDATE websub_subscriptions
+    SET hub_url = $2, topic_url = $3, state = $4, expires_at = $5, updated_at = $6
+    WHERE id = $1;
+
+-- name: DeleteWebSubSubscription :exec
+DELETE FROM websub_subscriptions WHERE id = $1;
@@ -0,0 +1,19 @@
+-- +goose Up
+CREATE TABLE post_tags (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL,
+    CONSTRAINT fk_user_id
+        FOREIGN KEY (user_id) REFERENCES users(id)
+        ON DELETE CASCADE,
+    post_id UUID NOT NULL,
+    CONSTRAINT fk_post_id
+        FOREIGN KEY (post_id) REFERENCES posts(id)
+        ON DELETE CASCADE,
+    tag VARCHAR NOT NULL,
+    CONSTRAINT unique_user_post_tag
+        UNIQUE(user_id, post_id, tag)
+);
+
+-- +goose Down
+DROP TABLE post_tags;
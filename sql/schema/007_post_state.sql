@@ -0,0 +1,39 @@
+-- +goose Up
+ALTER TABLE feed_follows
+    ADD COLUMN folder VARCHAR;
+
+CREATE TABLE post_reads (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL,
+    CONSTRAINT fk_user_id
+        FOREIGN KEY (user_id) REFERENCES users(id)
+        ON DELETE CASCADE,
+    post_id UUID NOT NULL,
+    CONSTRAINT fk_post_id
+        FOREIGN KEY (post_id) REFERENCES posts(id)
+        ON DELETE CASCADE,
+    CONSTRAINT unique_user_post_read
+        UNIQUE(user_id, post_id)
+);
+
+CREATE TABLE post_stars (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL,
+    CONSTRAINT fk_user_id
+        FOREIGN KEY (user_id) REFERENCES users(id)
+        ON DELETE CASCADE,
+    post_id UUID NOT NULL,
+    CONSTRAINT fk_post_id
+        FOREIGN KEY (post_id) REFERENCES posts(id)
+        ON DELETE CASCADE,
+    CONSTRAINT unique_user_post_star
+        UNIQUE(user_id, post_id)
+);
+
+-- +goose Down
+DROP TABLE post_stars;
+DROP TABLE post_reads;
+ALTER TABLE feed_follows
+    DROP COLUMN folder;
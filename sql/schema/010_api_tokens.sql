@@ -0,0 +1,20 @@
+-- +goose Up
+CREATE TABLE api_tokens (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL,
+    CONSTRAINT fk_user_id
+        FOREIGN KEY (user_id) REFERENCES users(id)
+        ON DELETE CASCADE,
+    name VARCHAR NOT NULL,
+    -- SHA-256 of the token, hex encoded - the token itself is never stored
+    token_hash VARCHAR UNIQUE NOT NULL,
+    -- 'read' or 'write'
+    scope VARCHAR NOT NULL,
+    last_used_at TIMESTAMP,
+    CONSTRAINT unique_user_token_name
+        UNIQUE(user_id, name)
+);
+
+-- +goose Down
+DROP TABLE api_tokens;
@@ -0,0 +1,18 @@
+-- +goose Up
+-- bcrypt hash, or NULL for users without a password
+ALTER TABLE users ADD COLUMN password_hash VARCHAR;
+
+CREATE TABLE user_sessions (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL,
+    CONSTRAINT fk_user_id
+        FOREIGN KEY (user_id) REFERENCES users(id)
+        ON DELETE CASCADE,
+    -- SHA-256 of the session token kept in the config file
+    token_hash VARCHAR UNIQUE NOT NULL
+);
+
+-- +goose Down
+DROP TABLE user_sessions;
+ALTER TABLE users DROP COLUMN password_hash;
@@ -0,0 +1,29 @@
+-- +goose Up
+-- Integer ids for posts and feeds, for APIs whose clients expect them.
+-- Numbered in the order gator first hands them out, so newer posts get
+-- higher numbers.
+CREATE TABLE post_numbers (
+    number BIGSERIAL PRIMARY KEY,
+    post_id UUID UNIQUE NOT NULL,
+    CONSTRAINT fk_post_id
+        FOREIGN KEY (post_id) REFERENCES posts(id)
+        ON DELETE CASCADE
+);
+
+CREATE TABLE feed_numbers (
+    number BIGSERIAL PRIMARY KEY,
+    feed_id UUID UNIQUE NOT NULL,
+    CONSTRAINT fk_feed_id
+        FOREIGN KEY (feed_id) REFERENCES feeds(id)
+        ON DELETE CASCADE
+);
+
+-- Hash of the MD5 of "<user name>:<token>", which is how Fever clients log in
+ALTER TABLE api_tokens ADD COLUMN fever_key VARCHAR;
+CREATE UNIQUE INDEX api_tokens_fever_key ON api_tokens (fever_key);
+
+-- +goose Down
+DROP INDEX api_tokens_fever_key;
+ALTER TABLE api_tokens DROP COLUMN fever_key;
+DROP TABLE feed_numbers;
+DROP TABLE post_numbers;
@@ -0,0 +1,22 @@
+-- +goose Up
+-- Output feeds of a user's posts, at secret URLs, optionally just those in
+-- a folder, with a tag or starred
+CREATE TABLE shares (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL,
+    CONSTRAINT fk_user_id
+        FOREIGN KEY (user_id) REFERENCES users(id)
+        ON DELETE CASCADE,
+    name VARCHAR NOT NULL,
+    -- SHA-256 of the token in the URL, hex encoded, as for api_tokens
+    token_hash VARCHAR UNIQUE NOT NULL,
+    folder VARCHAR,
+    tag VARCHAR,
+    starred BOOLEAN NOT NULL,
+    CONSTRAINT unique_user_share_name
+        UNIQUE(user_id, name)
+);
+
+-- +goose Down
+DROP TABLE shares;
@@ -0,0 +1,29 @@
+-- +goose Up
+-- The WebSub hub a feed advertises, and the topic URL (its self link) to
+-- subscribe to there
+ALTER TABLE feeds ADD COLUMN hub_url VARCHAR;
+ALTER TABLE feeds ADD COLUMN topic_url VARCHAR;
+
+-- Subscriptions to hubs, made by serve. Feeds are pushed to us rather than
+-- polled until expires_at.
+CREATE TABLE websub_subscriptions (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    feed_id UUID UNIQUE NOT NULL,
+    CONSTRAINT fk_feed_id
+        FOREIGN KEY (feed_id) REFERENCES feeds(id)
+        ON DELETE CASCADE,
+    hub_url VARCHAR NOT NULL,
+    topic_url VARCHAR NOT NULL,
+    -- For the HMAC signatures on pushed content
+    secret VARCHAR NOT NULL,
+    -- 'pending' until the hub verifies it, then 'active', or 'denied'
+    state VARCHAR NOT NULL,
+    expires_at TIMESTAMP
+);
+
+-- +goose Down
+DROP TABLE websub_subscriptions;
+ALTER TABLE feeds DROP COLUMN topic_url;
+ALTER TABLE feeds DROP COLUMN hub_url;
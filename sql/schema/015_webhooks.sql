@@ -0,0 +1,51 @@
+-- +goose Up
+-- Where to post a user's new posts, optionally just those from one feed,
+-- from feeds in a folder, or containing some text
+CREATE TABLE webhooks (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL,
+    CONSTRAINT fk_user_id
+        FOREIGN KEY (user_id) REFERENCES users(id)
+        ON DELETE CASCADE,
+    name VARCHAR NOT NULL,
+    url VARCHAR NOT NULL,
+    feed_id UUID,
+    CONSTRAINT fk_feed_id
+        FOREIGN KEY (feed_id) REFERENCES feeds(id)
+        ON DELETE CASCADE,
+    folder VARCHAR,
+    match VARCHAR,
+    -- A Go text/template for the JSON payload, or NULL for gator's own
+    template VARCHAR,
+    -- For the HMAC signature on each payload
+    secret VARCHAR NOT NULL,
+    CONSTRAINT unique_user_webhook_name
+        UNIQUE(user_id, name)
+);
+
+-- Each payload sent or to send. Failed deliveries are tried again at
+-- next_attempt_at, which is NULL once delivered or given up on.
+CREATE TABLE webhook_deliveries (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    webhook_id UUID NOT NULL,
+    CONSTRAINT fk_webhook_id
+        FOREIGN KEY (webhook_id) REFERENCES webhooks(id)
+        ON DELETE CASCADE,
+    -- NULL for tests
+    post_id UUID,
+    CONSTRAINT fk_post_id
+        FOREIGN KEY (post_id) REFERENCES posts(id)
+        ON DELETE CASCADE,
+    payload VARCHAR NOT NULL,
+    attempts INTEGER NOT NULL,
+    last_status INTEGER,
+    last_error VARCHAR,
+    delivered_at TIMESTAMP,
+    next_attempt_at TIMESTAMP
+);
+
+-- +goose Down
+DROP TABLE webhook_deliveries;
+DROP TABLE webhooks;
@@ -0,0 +1,20 @@
+-- SQLite versions of queries in sql/queries/feed_follows.sql which aren't
+-- portable. Parameters and result columns must match the originals.
+
+-- name: CreateFeedFollow :one
+INSERT INTO feed_follows (id, created_at, updated_at, user_id, feed_id)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5
+)
+RETURNING id, created_at, updated_at, user_id, feed_id, folder,
+    (SELECT feeds.name FROM feeds WHERE feeds.id = feed_id) AS feed_name,
+    (SELECT users.name FROM users WHERE users.id = user_id) AS user_name;
+
+-- name: GetFoldersForUser :many
+SELECT DISTINCT folder FROM feed_follows
+    WHERE user_id = $1 AND folder IS NOT NULL
+    ORDER BY folder;
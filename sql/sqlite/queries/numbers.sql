@@ -0,0 +1,41 @@
+-- SQLite versions of queries in sql/queries/numbers.sql which aren't
+-- portable. Parameters and result columns must match the originals.
+
+-- name: GetNumberedPostsForUser :many
+SELECT posts.id, post_numbers.number, feed_numbers.number AS feed_number,
+    feeds.name AS feed_name, feeds.url AS feed_url, feed_follows.folder,
+    posts.title, posts.url, posts.description, posts.published_at, posts.created_at,
+    EXISTS (
+        SELECT 1 FROM post_reads
+            WHERE post_reads.user_id = feed_follows.user_id AND post_reads.post_id = posts.id
+    ) AS is_read,
+    EXISTS (
+        SELECT 1 FROM post_stars
+            WHERE post_stars.user_id = feed_follows.user_id AND post_stars.post_id = posts.id
+    ) AS is_starred
+FROM posts
+    INNER JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
+    INNER JOIN feeds ON feeds.id = posts.feed_id
+    INNER JOIN post_numbers ON post_numbers.post_id = posts.id
+    INNER JOIN feed_numbers ON feed_numbers.feed_id = posts.feed_id
+    WHERE feed_follows.user_id = $1
+        AND ($2 IS NULL OR posts.feed_id = $2)
+        AND ($3 IS NULL OR feed_follows.folder = $3)
+        AND ($4 IS NULL OR EXISTS (
+            SELECT 1 FROM post_tags
+                WHERE post_tags.post_id = posts.id
+                    AND post_tags.user_id = feed_follows.user_id
+                    AND post_tags.tag = $4))
+        AND ($5 IS NULL OR $5 = EXISTS (
+            SELECT 1 FROM post_reads
+                WHERE post_reads.user_id = feed_follows.user_id AND post_reads.post_id = posts.id))
+        AND (NOT $6 OR EXISTS (
+            SELECT 1 FROM post_stars
+                WHERE post_stars.user_id = feed_follows.user_id AND post_stars.post_id = posts.id))
+        AND ($7 IS NULL OR posts.published_at >= $7)
+        AND ($8 IS NULL OR posts.published_at < $8)
+        AND ($9 IS NULL OR post_numbers.number > $9)
+        AND ($10 IS NULL OR post_numbers.number < $10)
+        AND ($11 IS NULL OR post_numbers.number = $11)
+    ORDER BY CASE WHEN $12 THEN post_numbers.number ELSE -post_numbers.number END
+    LIMIT $13;
@@ -0,0 +1,43 @@
+-- SQLite versions of queries in sql/queries/posts.sql which aren't portable.
+-- Parameters and result columns must match the originals.
+
+-- name: BrowsePostsForUser :many
+-- The sort time comes from a subquery rather than a CASE so that it keeps
+-- its TIMESTAMP type, which the driver needs to read it back as a time
+SELECT
+    posts.id, posts.created_at, posts.updated_at, posts.title, posts.url, posts.description, posts.published_at, posts.feed_id, posts.description_text,
+    feeds.name AS feed_name,
+    feed_follows.folder,
+    post_reads.id IS NOT NULL AS is_read,
+    post_stars.id IS NOT NULL AS is_starred,
+    sort_keys.sort_time
+FROM posts
+    INNER JOIN (
+        SELECT id AS post_id, published_at AS sort_time FROM posts WHERE NOT $1
+        UNION ALL
+        SELECT id AS post_id, created_at AS sort_time FROM posts WHERE $1
+    ) AS sort_keys ON sort_keys.post_id = posts.id
+    INNER JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
+    INNER JOIN feeds ON feeds.id = posts.feed_id
+    LEFT JOIN post_reads ON post_reads.post_id = posts.id AND post_reads.user_id = feed_follows.user_id
+    LEFT JOIN post_stars ON post_stars.post_id = posts.id AND post_stars.user_id = feed_follows.user_id
+WHERE feed_follows.user_id = $2
+    AND ($3 IS NULL OR feeds.url = $3)
+    AND ($4 IS NULL OR feed_follows.folder = $4)
+    AND ($5 IS NULL OR posts.published_at >= $5)
+    AND ($6 IS NULL OR posts.published_at < $6)
+    AND (NOT $7 OR post_reads.id IS NULL)
+    AND (NOT $8 OR post_stars.id IS NOT NULL)
+    AND ($9 IS NULL OR EXISTS (
+        SELECT 1 FROM post_tags
+        WHERE post_tags.post_id = posts.id
+            AND post_tags.user_id = feed_follows.user_id
+            AND post_tags.tag = $9))
+    -- SQLite's LIKE is already case-insensitive
+    AND ($10 IS NULL
+        OR posts.title LIKE $10 ESCAPE '\'
+        OR posts.description_text LIKE $10 ESCAPE '\')
+    -- Keyset pagination: strictly after the (sort_time, id) of the last row of the previous page
+    AND ($11 IS NULL OR (sort_keys.sort_time, posts.id) < ($11, $12))
+ORDER BY sort_keys.sort_time DESC, posts.id DESC
+LIMIT $13;
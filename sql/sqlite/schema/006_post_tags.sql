@@ -0,0 +1,19 @@
+-- +goose Up
+CREATE TABLE post_tags (
+    id TEXT PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    user_id TEXT NOT NULL,
+    post_id TEXT NOT NULL,
+    tag TEXT NOT NULL,
+    CONSTRAINT fk_user_id
+        FOREIGN KEY (user_id) REFERENCES users(id)
+        ON DELETE CASCADE,
+    CONSTRAINT fk_post_id
+        FOREIGN KEY (post_id) REFERENCES posts(id)
+        ON DELETE CASCADE,
+    CONSTRAINT unique_user_post_tag
+        UNIQUE(user_id, post_id, tag)
+);
+
+-- +goose Down
+DROP TABLE post_tags;
@@ -0,0 +1,39 @@
+-- +goose Up
+ALTER TABLE feed_follows
+    ADD COLUMN folder TEXT;
+
+CREATE TABLE post_reads (
+    id TEXT PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    user_id TEXT NOT NULL,
+    post_id TEXT NOT NULL,
+    CONSTRAINT fk_user_id
+        FOREIGN KEY (user_id) REFERENCES users(id)
+        ON DELETE CASCADE,
+    CONSTRAINT fk_post_id
+        FOREIGN KEY (post_id) REFERENCES posts(id)
+        ON DELETE CASCADE,
+    CONSTRAINT unique_user_post_read
+        UNIQUE(user_id, post_id)
+);
+
+CREATE TABLE post_stars (
+    id TEXT PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    user_id TEXT NOT NULL,
+    post_id TEXT NOT NULL,
+    CONSTRAINT fk_user_id
+        FOREIGN KEY (user_id) REFERENCES users(id)
+        ON DELETE CASCADE,
+    CONSTRAINT fk_post_id
+        FOREIGN KEY (post_id) REFERENCES posts(id)
+        ON DELETE CASCADE,
+    CONSTRAINT unique_user_post_star
+        UNIQUE(user_id, post_id)
+);
+
+-- +goose Down
+DROP TABLE post_stars;
+DROP TABLE post_reads;
+ALTER TABLE feed_follows
+    DROP COLUMN folder;
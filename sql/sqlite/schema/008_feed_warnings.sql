@@ -0,0 +1,13 @@
+-- +goose Up
+CREATE TABLE feed_warnings (
+    id TEXT PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    feed_id TEXT NOT NULL,
+    message TEXT NOT NULL,
+    CONSTRAINT fk_feed_id
+        FOREIGN KEY (feed_id) REFERENCES feeds(id)
+        ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE feed_warnings;
@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE posts
+    ADD COLUMN description_text TEXT;
+
+-- +goose Down
+ALTER TABLE posts
+    DROP COLUMN description_text;
@@ -0,0 +1,18 @@
+-- +goose Up
+CREATE TABLE api_tokens (
+    id TEXT PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    user_id TEXT NOT NULL,
+    name TEXT NOT NULL,
+    token_hash TEXT UNIQUE NOT NULL,
+    scope TEXT NOT NULL,
+    last_used_at TIMESTAMP,
+    CONSTRAINT fk_user_id
+        FOREIGN KEY (user_id) REFERENCES users(id)
+        ON DELETE CASCADE,
+    CONSTRAINT unique_user_token_name
+        UNIQUE(user_id, name)
+);
+
+-- +goose Down
+DROP TABLE api_tokens;
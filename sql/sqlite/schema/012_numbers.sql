@@ -0,0 +1,26 @@
+-- +goose Up
+-- AUTOINCREMENT so numbers aren't reused after the newest post is deleted
+CREATE TABLE post_numbers (
+    number INTEGER PRIMARY KEY AUTOINCREMENT,
+    post_id TEXT UNIQUE NOT NULL,
+    CONSTRAINT fk_post_id
+        FOREIGN KEY (post_id) REFERENCES posts(id)
+        ON DELETE CASCADE
+);
+
+CREATE TABLE feed_numbers (
+    number INTEGER PRIMARY KEY AUTOINCREMENT,
+    feed_id TEXT UNIQUE NOT NULL,
+    CONSTRAINT fk_feed_id
+        FOREIGN KEY (feed_id) REFERENCES feeds(id)
+        ON DELETE CASCADE
+);
+
+ALTER TABLE api_tokens ADD COLUMN fever_key TEXT;
+CREATE UNIQUE INDEX api_tokens_fever_key ON api_tokens (fever_key);
+
+-- +goose Down
+DROP INDEX api_tokens_fever_key;
+ALTER TABLE api_tokens DROP COLUMN fever_key;
+DROP TABLE feed_numbers;
+DROP TABLE post_numbers;
@@ -0,0 +1,19 @@
+-- +goose Up
+CREATE TABLE shares (
+    id TEXT PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    user_id TEXT NOT NULL,
+    name TEXT NOT NULL,
+    token_hash TEXT UNIQUE NOT NULL,
+    folder TEXT,
+    tag TEXT,
+    starred BOOLEAN NOT NULL,
+    CONSTRAINT fk_user_id
+        FOREIGN KEY (user_id) REFERENCES users(id)
+        ON DELETE CASCADE,
+    CONSTRAINT unique_user_share_name
+        UNIQUE(user_id, name)
+);
+
+-- +goose Down
+DROP TABLE shares;
@@ -0,0 +1,23 @@
+-- +goose Up
+ALTER TABLE feeds ADD COLUMN hub_url TEXT;
+ALTER TABLE feeds ADD COLUMN topic_url TEXT;
+
+CREATE TABLE websub_subscriptions (
+    id TEXT PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    feed_id TEXT UNIQUE NOT NULL,
+    hub_url TEXT NOT NULL,
+    topic_url TEXT NOT NULL,
+    secret TEXT NOT NULL,
+    state TEXT NOT NULL,
+    expires_at TIMESTAMP,
+    CONSTRAINT fk_feed_id
+        FOREIGN KEY (feed_id) REFERENCES feeds(id)
+        ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE websub_subscriptions;
+ALTER TABLE feeds DROP COLUMN topic_url;
+ALTER TABLE feeds DROP COLUMN hub_url;
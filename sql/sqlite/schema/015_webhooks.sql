@@ -0,0 +1,44 @@
+-- +goose Up
+CREATE TABLE webhooks (
+    id TEXT PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    user_id TEXT NOT NULL,
+    name TEXT NOT NULL,
+    url TEXT NOT NULL,
+    feed_id TEXT,
+    folder TEXT,
+    match TEXT,
+    template TEXT,
+    secret TEXT NOT NULL,
+    CONSTRAINT fk_user_id
+        FOREIGN KEY (user_id) REFERENCES users(id)
+        ON DELETE CASCADE,
+    CONSTRAINT fk_feed_id
+        FOREIGN KEY (feed_id) REFERENCES feeds(id)
+        ON DELETE CASCADE,
+    CONSTRAINT unique_user_webhook_name
+        UNIQUE(user_id, name)
+);
+
+CREATE TABLE webhook_deliveries (
+    id TEXT PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    webhook_id TEXT NOT NULL,
+    post_id TEXT,
+    payload TEXT NOT NULL,
+    attempts INTEGER NOT NULL,
+    last_status INTEGER,
+    last_error TEXT,
+    delivered_at TIMESTAMP,
+    next_attempt_at TIMESTAMP,
+    CONSTRAINT fk_webhook_id
+        FOREIGN KEY (webhook_id) REFERENCES webhooks(id)
+        ON DELETE CASCADE,
+    CONSTRAINT fk_post_id
+        FOREIGN KEY (post_id) REFERENCES posts(id)
+        ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE webhook_deliveries;
+DROP TABLE webhooks;
@@ -0,0 +1,7 @@
+-- +goose Up
+-- Fever keys are stored hashed like tokens now. The old ones can't be
+-- hashed here, so tokens made before this need making again for Fever.
+UPDATE api_tokens SET fever_key = NULL;
+
+-- +goose Down
+UPDATE api_tokens SET fever_key = NULL;
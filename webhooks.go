@@ -0,0 +1,546 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/venzy/gator/internal/database"
+)
+
+// Outgoing webhooks: each new post is posted as JSON to the URLs of the
+// webhooks of users following its feed, signed with the webhook's secret in
+// an X-Gator-Signature header like WebSub's. Deliveries are queued as posts
+// are stored, and sent by agg, refresh and serve, which each claim one before
+// sending it so none goes twice. Failed deliveries are retried with
+// exponential backoff.
+
+// Payload events
+const (
+	webhookEventPost = "post.created"
+	webhookEventTest = "test"
+)
+
+const (
+	// How long a webhook's URL gets to answer
+	webhookTimeout = 10 * time.Second
+	// Tries before giving up on a delivery, the first retry coming after
+	// webhookFirstRetry and each one after twice as long as the last
+	webhookMaxAttempts = 6
+	webhookFirstRetry  = time.Minute
+	// Most deliveries sent at once
+	webhookSendBatch = 100
+	// How long a claimed delivery is left alone before it's due again, in
+	// case whatever claimed it doesn't live to say how it went
+	webhookClaim = time.Minute
+	// How often serve sends what's due
+	webhookSendInterval = 10 * time.Second
+)
+
+// What's posted, unless the webhook has a template, which gets this as its
+// data - the same fields by their Go names, e.g. {{.Post.Title}}
+type webhookPayload struct {
+	Event   string      `json:"event"`
+	Webhook string      `json:"webhook"`
+	Post    webhookPost `json:"post"`
+}
+
+type webhookPost struct {
+	ID          uuid.UUID   `json:"id"`
+	Title       string      `json:"title"`
+	Url         string      `json:"url"`
+	PublishedAt time.Time   `json:"published_at"`
+	Description string      `json:"description"`
+	Text        string      `json:"text"`
+	Feed        webhookFeed `json:"feed"`
+}
+
+type webhookFeed struct {
+	Name string `json:"name"`
+	Url  string `json:"url"`
+}
+
+// Templates build JSON, so strings need quoting and escaping, which json
+// does: {"text": {{json .Post.Title}}}
+var webhookTemplateFuncs = template.FuncMap{
+	"json": func(value any) (string, error) {
+		encoded, err := json.Marshal(value)
+		return string(encoded), err
+	},
+}
+
+func handlerWebhooks(s *state, cmd command, user database.User) error {
+	action := cmd.args[0]
+	var name string
+	if len(cmd.args) > 1 {
+		name = cmd.args[1]
+	}
+	if name == "" && action != "list" && action != "log" {
+		return fmt.Errorf("'webhooks %s' needs the name of a webhook", action)
+	}
+
+	switch action {
+	case "add":
+		if len(cmd.args) < 3 {
+			return fmt.Errorf("'webhooks add' needs the URL to post to")
+		}
+		return addWebhook(s, cmd, user, name, cmd.args[2])
+
+	case "list":
+		webhooks, err := s.db.GetWebhooksForUser(context.Background(), user.ID)
+		if err != nil {
+			return fmt.Errorf("Problem fetching webhooks for user '%s': %v", user.Name, err)
+		}
+
+		records := make([]webhookRecord, 0, len(webhooks))
+		for _, webhook := range webhooks {
+			record := webhookRecord{
+				Name:      webhook.Name,
+				Url:       webhook.Url,
+				Folder:    webhook.Folder.String,
+				Match:     webhook.Match.String,
+				Template:  webhook.Template.String,
+				CreatedAt: webhook.CreatedAt,
+			}
+			if webhook.FeedID.Valid {
+				feed, err := s.db.GetFeedByID(context.Background(), webhook.FeedID.UUID)
+				if err != nil {
+					return fmt.Errorf("Problem fetching feed of webhook '%s': %v", webhook.Name, err)
+				}
+				record.Feed = feed.Url
+			}
+			records = append(records, record)
+		}
+		return printRecords(s, records)
+
+	case "remove":
+		deleted, err := s.db.DeleteWebhook(
+			context.Background(),
+			database.DeleteWebhookParams{
+				UserID: user.ID,
+				Name:   name,
+			})
+		if err != nil {
+			return fmt.Errorf("Problem removing webhook '%s' for user '%s': %v", name, user.Name, err)
+		}
+		if deleted == 0 {
+			return fmt.Errorf("User '%s' has no webhook called '%s'", user.Name, name)
+		}
+		fmt.Printf("Removed webhook '%s'\n", name)
+
+	case "test":
+		return testWebhook(s, user, name)
+
+	case "log":
+		deliveries, err := s.db.GetWebhookDeliveriesForUser(
+			context.Background(),
+			database.GetWebhookDeliveriesForUserParams{
+				UserID:      user.ID,
+				WebhookName: name,
+				RowLimit:    int32(cmd.intFlag("limit")),
+			})
+		if err != nil {
+			return fmt.Errorf("Problem fetching webhook deliveries for user '%s': %v", user.Name, err)
+		}
+
+		records := make([]deliveryRecord, 0, len(deliveries))
+		for _, delivery := range deliveries {
+			record := deliveryRecord{
+				CreatedAt: delivery.CreatedAt,
+				Webhook:   delivery.WebhookName,
+				Post:      delivery.PostTitle.String,
+				Attempts:  delivery.Attempts,
+				Error:     delivery.LastError.String,
+			}
+			if !delivery.PostTitle.Valid {
+				record.Post = "(test)"
+			}
+			if delivery.LastStatus.Valid {
+				record.Status = strconv.Itoa(int(delivery.LastStatus.Int32))
+			}
+			switch {
+			case delivery.DeliveredAt.Valid:
+				record.Result = "delivered"
+			case delivery.NextAttemptAt.Valid && delivery.Attempts == 0:
+				record.Result = "queued"
+			case delivery.NextAttemptAt.Valid:
+				record.Result = "retrying at " + delivery.NextAttemptAt.Time.Local().Format(time.DateTime)
+			default:
+				record.Result = "failed"
+			}
+			records = append(records, record)
+		}
+		return printRecords(s, records)
+
+	default:
+		return fmt.Errorf("Unknown webhooks action '%s': must be add, list, remove, test or log", action)
+	}
+
+	return nil
+}
+
+type webhookRecord struct {
+	Name      string    `json:"name"`
+	Url       string    `json:"url"`
+	Feed      string    `json:"feed"`
+	Folder    string    `json:"folder"`
+	Match     string    `json:"match"`
+	Template  string    `json:"template" table:"-"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type deliveryRecord struct {
+	CreatedAt time.Time `json:"created_at"`
+	Webhook   string    `json:"webhook"`
+	Post      string    `json:"post"`
+	Attempts  int32     `json:"attempts"`
+	Status    string    `json:"status"`
+	Result    string    `json:"result"`
+	Error     string    `json:"error"`
+}
+
+func addWebhook(s *state, cmd command, user database.User, name string, webhookURL string) error {
+	parsed, err := url.Parse(webhookURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("Invalid webhook URL '%s': must be an http or https URL", webhookURL)
+	}
+
+	params := database.CreateWebhookParams{
+		ID:        uuid.New(),
+		CreatedAt: time.Now(),
+		UserID:    user.ID,
+		Name:      name,
+		Url:       webhookURL,
+		Secret:    cmd.stringFlag("secret"),
+	}
+	if feedURL := cmd.stringFlag("feed"); feedURL != "" {
+		feed, err := s.db.GetFeedByURL(context.Background(), feedURL)
+		if err != nil {
+			return fmt.Errorf("Feed URL '%s' not in database!", feedURL)
+		}
+		params.FeedID = uuid.NullUUID{UUID: feed.ID, Valid: true}
+	}
+	if folder := cmd.stringFlag("folder"); folder != "" {
+		params.Folder = sql.NullString{String: folder, Valid: true}
+	}
+	if match := cmd.stringFlag("match"); match != "" {
+		params.Match = sql.NullString{String: match, Valid: true}
+	}
+	if tmpl := cmd.stringFlag("template"); tmpl != "" {
+		params.Template = sql.NullString{String: tmpl, Valid: true}
+		// Caught now rather than when a post turns up
+		if _, err := renderWebhookPayload(params.Template, sampleWebhookPayload(name)); err != nil {
+			return err
+		}
+	}
+
+	generated := params.Secret == ""
+	if generated {
+		if params.Secret, err = newToken(); err != nil {
+			return err
+		}
+	}
+
+	if _, err := s.db.CreateWebhook(context.Background(), params); err != nil {
+		return fmt.Errorf("Problem adding webhook '%s' for user '%s': %v", name, user.Name, err)
+	}
+
+	fmt.Printf("Added webhook '%s' posting new posts to %s\n", name, webhookURL)
+	if generated {
+		fmt.Println("Payloads are signed with this secret, which can't be shown again:")
+		fmt.Println(params.Secret)
+	}
+	return nil
+}
+
+// Sends a made-up post to a webhook now, logging it like any other delivery
+// but without retrying
+func testWebhook(s *state, user database.User, name string) error {
+	webhook, err := s.db.GetWebhookByName(
+		context.Background(),
+		database.GetWebhookByNameParams{
+			UserID: user.ID,
+			Name:   name,
+		})
+	if err != nil {
+		return fmt.Errorf("User '%s' has no webhook called '%s'", user.Name, name)
+	}
+
+	payload, err := renderWebhookPayload(webhook.Template, sampleWebhookPayload(webhook.Name))
+	if err != nil {
+		return err
+	}
+	delivery, err := s.db.CreateWebhookDelivery(
+		context.Background(),
+		database.CreateWebhookDeliveryParams{
+			ID:        uuid.New(),
+			CreatedAt: time.Now(),
+			WebhookID: webhook.ID,
+			Payload:   payload,
+		})
+	if err != nil {
+		return fmt.Errorf("Problem recording delivery for webhook '%s': %v", webhook.Name, err)
+	}
+
+	status, err := deliverWebhook(context.Background(), s, webhook.Url, webhook.Secret, delivery, false)
+	if err != nil {
+		return fmt.Errorf("Test delivery to %s failed: %v", webhook.Url, err)
+	}
+	fmt.Printf("Test delivered to %s (status %d)\n", webhook.Url, status)
+	return nil
+}
+
+func sampleWebhookPayload(name string) webhookPayload {
+	return webhookPayload{
+		Event:   webhookEventTest,
+		Webhook: name,
+		Post: webhookPost{
+			ID:          uuid.Nil,
+			Title:       "A test post from gator",
+			Url:         defaultContactURL,
+			PublishedAt: time.Now().UTC().Truncate(time.Second),
+			Description: "<p>Your webhook works.</p>",
+			Text:        "Your webhook works.",
+			Feed:        webhookFeed{Name: "gator", Url: defaultContactURL},
+		},
+	}
+}
+
+// The body to post, from the webhook's template if it has one, which has
+// to come out as JSON
+func renderWebhookPayload(tmpl sql.NullString, payload webhookPayload) (string, error) {
+	if !tmpl.Valid {
+		encoded, err := json.Marshal(payload)
+		return string(encoded), err
+	}
+
+	parsed, err := template.New("webhook").Funcs(webhookTemplateFuncs).Parse(tmpl.String)
+	if err != nil {
+		return "", fmt.Errorf("Invalid webhook template: %v", err)
+	}
+	var body bytes.Buffer
+	if err := parsed.Execute(&body, payload); err != nil {
+		return "", fmt.Errorf("Problem executing webhook template: %v", err)
+	}
+	if !json.Valid(body.Bytes()) {
+		return "", fmt.Errorf("Webhook template doesn't make valid JSON - quote strings with json, e.g. {{json .Post.Title}}: %s", body.String())
+	}
+	return body.String(), nil
+}
+
+// Queues the new posts from a feed for the webhooks that want them, to go
+// out with the next sendWebhooks. Problems go to stderr, as they're no
+// reason to stop collecting.
+func queueWebhooks(ctx context.Context, s *state, feed database.Feed, posts []database.Post) {
+	// Recorded even if ctx, a fetch's say, has just run out
+	ctx = context.WithoutCancel(ctx)
+
+	webhooks, err := s.db.GetWebhooksForFeed(ctx, feed.ID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Problem fetching webhooks for feed '%s': %v\n", feed.Name, err)
+		return
+	}
+
+	for _, webhook := range webhooks {
+		for _, post := range posts {
+			if webhook.Match.Valid && !postMatches(post, webhook.Match.String) {
+				continue
+			}
+
+			payload, err := renderWebhookPayload(webhook.Template, webhookPayload{
+				Event:   webhookEventPost,
+				Webhook: webhook.Name,
+				Post: webhookPost{
+					ID:          post.ID,
+					Title:       post.Title,
+					Url:         post.Url,
+					PublishedAt: post.PublishedAt,
+					Description: post.Description.String,
+					Text:        post.DescriptionText.String,
+					Feed:        webhookFeed{Name: feed.Name, Url: feed.Url},
+				},
+			})
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Problem with webhook '%s': %v\n", webhook.Name, err)
+				continue
+			}
+
+			now := time.Now()
+			_, err = s.db.CreateWebhookDelivery(
+				ctx,
+				database.CreateWebhookDeliveryParams{
+					ID:            uuid.New(),
+					CreatedAt:     now,
+					WebhookID:     webhook.ID,
+					PostID:        uuid.NullUUID{UUID: post.ID, Valid: true},
+					Payload:       payload,
+					NextAttemptAt: sql.NullTime{Time: now, Valid: true},
+				})
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Problem recording delivery for webhook '%s': %v\n", webhook.Name, err)
+			}
+		}
+	}
+}
+
+// Whether a post has some text in its title or content, ignoring case, as
+// for browse --search
+func postMatches(post database.Post, match string) bool {
+	match = strings.ToLower(match)
+	return strings.Contains(strings.ToLower(post.Title), match) ||
+		strings.Contains(strings.ToLower(post.DescriptionText.String), match)
+}
+
+// Sends the deliveries that are due, whether new or to retry, until ctx is
+// cancelled. Each gets webhookTimeout, and an attempt cut short by ctx isn't
+// counted. Problems go to stderr.
+func sendWebhooks(ctx context.Context, s *state) {
+	deliveries, err := s.db.GetDueWebhookDeliveries(
+		ctx,
+		database.GetDueWebhookDeliveriesParams{
+			Now:      sql.NullTime{Time: time.Now(), Valid: true},
+			RowLimit: webhookSendBatch,
+		})
+	if err != nil {
+		if ctx.Err() == nil {
+			fmt.Fprintf(os.Stderr, "Problem fetching webhook deliveries to send: %v\n", err)
+		}
+		return
+	}
+
+	for _, due := range deliveries {
+		if ctx.Err() != nil {
+			return
+		}
+
+		now := time.Now()
+		claimed, err := s.db.ClaimWebhookDelivery(
+			ctx,
+			database.ClaimWebhookDeliveryParams{
+				ID:           due.ID,
+				Now:          sql.NullTime{Time: now, Valid: true},
+				ClaimedUntil: sql.NullTime{Time: now.Add(webhookClaim), Valid: true},
+			})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Problem claiming delivery for webhook '%s': %v\n", due.WebhookName, err)
+			continue
+		}
+		if claimed == 0 {
+			// Something else got there first
+			continue
+		}
+
+		delivery := database.WebhookDelivery{
+			ID:       due.ID,
+			Payload:  due.Payload,
+			Attempts: due.Attempts,
+		}
+		if _, err := deliverWebhook(ctx, s, due.Url, due.Secret, delivery, true); err != nil && ctx.Err() == nil {
+			fmt.Fprintf(os.Stderr, "Problem delivering to webhook '%s' (attempt %d): %v\n", due.WebhookName, due.Attempts+1, err)
+		}
+	}
+}
+
+// Sends what's due every webhookSendInterval until ctx is cancelled, for
+// serve, which stores pushed posts but doesn't otherwise get to send them
+func webhookLoop(ctx context.Context, s *state) {
+	ticker := time.NewTicker(webhookSendInterval)
+	defer ticker.Stop()
+
+	for {
+		sendWebhooks(ctx, s)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Posts a delivery's payload, giving it webhookTimeout, and records how it
+// went and, if it failed and retry is set, when to try again. Nothing's
+// recorded if ctx is cancelled first, leaving the delivery to be tried
+// again as it was. Returns the response status, if any.
+func deliverWebhook(ctx context.Context, s *state, webhookURL string, secret string, delivery database.WebhookDelivery, retry bool) (int, error) {
+	sendCtx, cancel := context.WithTimeout(ctx, webhookTimeout)
+	status, sendErr := sendWebhook(sendCtx, s, webhookURL, secret, delivery.ID, delivery.Payload)
+	cancel()
+	if ctx.Err() != nil {
+		return status, fmt.Errorf("Stopped before delivering: %v", ctx.Err())
+	}
+
+	now := time.Now()
+	attempts := delivery.Attempts + 1
+	params := database.UpdateWebhookDeliveryParams{
+		ID:         delivery.ID,
+		Attempts:   attempts,
+		LastStatus: sql.NullInt32{Int32: int32(status), Valid: status != 0},
+	}
+	if sendErr == nil {
+		params.DeliveredAt = sql.NullTime{Time: now, Valid: true}
+	} else {
+		params.LastError = sql.NullString{String: sendErr.Error(), Valid: true}
+		if retry && attempts < webhookMaxAttempts {
+			backoff := webhookFirstRetry << (attempts - 1)
+			params.NextAttemptAt = sql.NullTime{Time: now.Add(backoff), Valid: true}
+		}
+	}
+
+	if err := s.db.UpdateWebhookDelivery(ctx, params); err != nil {
+		fmt.Fprintf(os.Stderr, "Problem recording webhook delivery: %v\n", err)
+	}
+	return status, sendErr
+}
+
+func sendWebhook(ctx context.Context, s *state, webhookURL string, secret string, deliveryID uuid.UUID, payload string) (int, error) {
+	opts, err := newFetchOptions(s.cfg.Fetch)
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", webhookURL, strings.NewReader(payload))
+	if err != nil {
+		return 0, fmt.Errorf("Error creating request: %s", err)
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", opts.userAgent)
+	req.Header.Set("X-Gator-Delivery", deliveryID.String())
+	req.Header.Set("X-Gator-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+
+	client := newHTTPClient(opts, webhookTimeout)
+	defer client.CloseIdleConnections()
+
+	res, err := client.Do(req)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return 0, fmt.Errorf("Error making request: %s", err)
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(res.Body, 1<<16))
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return res.StatusCode, fmt.Errorf("Unexpected response status: %s", res.Status)
+	}
+	return res.StatusCode, nil
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/venzy/gator/internal/database"
+)
+
+// Receives webhook payloads, checking their signatures, and failing while
+// failing is set
+type webhookReceiver struct {
+	*httptest.Server
+	mu       sync.Mutex
+	failing  bool
+	payloads map[string][]string
+}
+
+func newWebhookReceiver(t *testing.T, secret string) *webhookReceiver {
+	t.Helper()
+
+	receiver := &webhookReceiver{payloads: make(map[string][]string)}
+	receiver.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(body)
+		if r.Header.Get("X-Gator-Signature") != "sha256="+hex.EncodeToString(mac.Sum(nil)) {
+			t.Errorf("got signature %q on %s", r.Header.Get("X-Gator-Signature"), body)
+		}
+
+		receiver.mu.Lock()
+		defer receiver.mu.Unlock()
+		if receiver.failing {
+			http.Error(w, "Down for maintenance", http.StatusServiceUnavailable)
+			return
+		}
+		receiver.payloads[r.URL.Path] = append(receiver.payloads[r.URL.Path], string(body))
+	}))
+	t.Cleanup(receiver.Close)
+	return receiver
+}
+
+func (r *webhookReceiver) received(path string) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.payloads[path]
+}
+
+func (r *webhookReceiver) setFailing(failing bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.failing = failing
+}
+
+func TestWebhooks(t *testing.T) {
+	feeds := newFeedServer(t)
+	const secret = "sesame"
+
+	for name, newState := range testStates {
+		t.Run(name, func(t *testing.T) {
+			s := newState(t)
+			receiver := newWebhookReceiver(t, secret)
+			mustRun(t, s, "register", "alice")
+			mustRun(t, s, "addfeed", "RSS", feeds.URL+"/feeds/rss.xml")
+
+			mustRun(t, s, "webhooks", "add", "--secret", secret, "all", receiver.URL+"/all")
+			mustRun(t, s, "webhooks", "add", "--secret", secret, "--match", "second", "--template", `{"text": {{json .Post.Title}}}`, "second", receiver.URL+"/second")
+			mustRun(t, s, "webhooks", "add", "--secret", secret, "--folder", "Blogs", "blogs", receiver.URL+"/blogs")
+			if _, err := runCommand(t, s, "webhooks", "add", "--template", `{"text": {{.Post.Title}}}`, "broken", receiver.URL); err == nil {
+				t.Error("added a webhook whose template doesn't make JSON")
+			}
+
+			// Queued by collecting, and only sent after
+			captureStdout(t, func() { scapeFeeds(context.Background(), s) })
+			queued := runListing[deliveryRecord](t, s, "webhooks", "log", "all")
+			if len(queued) != 3 || queued[0].Result != "queued" || len(receiver.received("/all")) != 0 {
+				t.Fatalf("got deliveries %+v, want 3 queued and none sent", queued)
+			}
+			sendWebhooks(context.Background(), s)
+			all := receiver.received("/all")
+			if len(all) != 3 {
+				t.Fatalf("got %d payloads for all posts, want 3", len(all))
+			}
+			var payload webhookPayload
+			if err := json.Unmarshal([]byte(all[0]), &payload); err != nil {
+				t.Fatal(err)
+			}
+			if payload.Event != webhookEventPost || payload.Post.Feed.Name != "RSS" || payload.Post.Url == "" {
+				t.Errorf("got payload %+v, want a new post from the RSS feed", payload)
+			}
+			if second := receiver.received("/second"); len(second) != 1 || second[0] != `{"text": "Second Post"}` {
+				t.Errorf("got %q for the matching webhook, want just the second post from its template", second)
+			}
+			if blogs := receiver.received("/blogs"); len(blogs) != 0 {
+				t.Errorf("got %d payloads for a folder the feed isn't in", len(blogs))
+			}
+
+			// A failed delivery is retried when due, until it gets through
+			mustRun(t, s, "webhooks", "remove", "second")
+			receiver.setFailing(true)
+			mustRun(t, s, "addfeed", "HTML", feeds.URL+"/feeds/html.xml")
+			captureStdout(t, func() { scapeFeeds(context.Background(), s) })
+			sendWebhooks(context.Background(), s)
+			deliveries := runListing[deliveryRecord](t, s, "webhooks", "log", "all")
+			if len(deliveries) != 4 || deliveries[0].Status != "503" || !strings.HasPrefix(deliveries[0].Result, "retrying") {
+				t.Fatalf("got deliveries %+v, want the newest retrying after a 503", deliveries)
+			}
+			alice, err := s.db.GetUserByName(context.Background(), "alice")
+			if err != nil {
+				t.Fatal(err)
+			}
+			due, err := s.db.GetWebhookDeliveriesForUser(context.Background(), database.GetWebhookDeliveriesForUserParams{UserID: alice.ID, RowLimit: 1})
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Due now rather than in a minute
+			makeDue := func() {
+				s.db.UpdateWebhookDelivery(context.Background(), database.UpdateWebhookDeliveryParams{
+					ID:            due[0].ID,
+					Attempts:      due[0].Attempts,
+					LastStatus:    due[0].LastStatus,
+					LastError:     due[0].LastError,
+					NextAttemptAt: sql.NullTime{Time: time.Now().Add(-time.Second), Valid: true},
+				})
+			}
+			receiver.setFailing(false)
+
+			// Nothing's sent while something else has it claimed
+			makeDue()
+			now := time.Now()
+			s.db.ClaimWebhookDelivery(context.Background(), database.ClaimWebhookDeliveryParams{
+				ID:           due[0].ID,
+				Now:          sql.NullTime{Time: now, Valid: true},
+				ClaimedUntil: sql.NullTime{Time: now.Add(time.Minute), Valid: true},
+			})
+			sendWebhooks(context.Background(), s)
+			if all := receiver.received("/all"); len(all) != 3 {
+				t.Errorf("got %d payloads with the delivery claimed, want 3", len(all))
+			}
+
+			// An attempt cut short isn't counted
+			cancelled, cancel := context.WithCancel(context.Background())
+			cancel()
+			delivery := database.WebhookDelivery{ID: due[0].ID, Payload: "{}", Attempts: due[0].Attempts}
+			if _, err := deliverWebhook(cancelled, s, receiver.URL+"/all", secret, delivery, true); err == nil {
+				t.Error("delivering with a cancelled context didn't fail")
+			}
+
+			makeDue()
+			sendWebhooks(context.Background(), s)
+			if all := receiver.received("/all"); len(all) != 4 {
+				t.Errorf("got %d payloads after retrying, want 4", len(all))
+			}
+			deliveries = runListing[deliveryRecord](t, s, "webhooks", "log", "all")
+			if deliveries[0].Result != "delivered" || deliveries[0].Attempts != 2 {
+				t.Errorf("got %+v, want delivered on the second attempt", deliveries[0])
+			}
+
+			if out := mustRun(t, s, "webhooks", "test", "blogs"); !strings.Contains(out, "Test delivered") {
+				t.Errorf("got %q testing a webhook", out)
+			}
+			if blogs := receiver.received("/blogs"); len(blogs) != 1 || !strings.Contains(blogs[0], `"event":"test"`) {
+				t.Errorf("got %q for the test, want a test payload", blogs)
+			}
+			receiver.setFailing(true)
+			if _, err := runCommand(t, s, "webhooks", "test", "blogs"); err == nil {
+				t.Error("testing a failing webhook didn't fail")
+			}
+		})
+	}
+}
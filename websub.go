@@ -0,0 +1,356 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/venzy/gator/internal/database"
+)
+
+// WebSub (formerly PubSubHubbub): feeds that advertise a hub get pushed to
+// serve when they change, instead of being polled by agg. serve subscribes
+// at each hub with a callback URL under /websub/, which the hub checks back
+// with, then posts new content to, signed with a secret we gave it.
+// Subscriptions last for a lease, which serve renews while it's running -
+// if it isn't, leases run out and agg goes back to polling.
+
+// WebSub subscription states
+const (
+	websubPending = "pending"
+	websubActive  = "active"
+	websubDenied  = "denied"
+)
+
+const (
+	// How often serve looks for subscriptions to make or renew
+	websubCheckInterval = time.Minute
+	// The lease we ask hubs for - they may give us another
+	websubLeaseSeconds = 10 * 24 * 60 * 60
+	// How long to wait for a hub to verify a subscription before asking
+	// again, or to ask again after being denied
+	websubRetryAfter       = time.Hour
+	websubDeniedRetryAfter = 24 * time.Hour
+)
+
+func (srv *server) registerWebSubRoutes() {
+	srv.mux.HandleFunc("GET /websub/{id}", srv.websubVerify)
+	srv.mux.HandleFunc("POST /websub/{id}", srv.websubPush)
+}
+
+// Subscribes and resubscribes to hubs every websubCheckInterval until ctx
+// is cancelled. Hubs call back to publicURL, where serve can be reached.
+func websubLoop(ctx context.Context, s *state, publicURL string) {
+	ticker := time.NewTicker(websubCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := checkWebSub(ctx, s, publicURL); err != nil {
+			fmt.Fprintf(os.Stderr, "Problem checking WebSub subscriptions: %v\n", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Asks hubs for subscriptions to the feeds that need one - those without a
+// subscription, whose hub has changed, whose lease is three quarters done,
+// or whose last request got nowhere
+func checkWebSub(ctx context.Context, s *state, publicURL string) error {
+	feeds, err := s.db.GetFeedsWithHubs(ctx)
+	if err != nil {
+		return fmt.Errorf("Problem fetching feeds with hubs: %v", err)
+	}
+	opts, err := newFetchOptions(s.cfg.Fetch)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, feed := range feeds {
+		subscription, err := s.db.GetWebSubSubscriptionForFeed(ctx, feed.ID)
+		if errors.Is(err, sql.ErrNoRows) {
+			secret, err := newToken()
+			if err != nil {
+				return err
+			}
+			subscription, err = s.db.CreateWebSubSubscription(
+				ctx,
+				database.CreateWebSubSubscriptionParams{
+					ID:        uuid.New(),
+					CreatedAt: now,
+					UpdatedAt: now,
+					FeedID:    feed.ID,
+					HubUrl:    feed.HubUrl.String,
+					TopicUrl:  feed.TopicUrl.String,
+					Secret:    secret,
+					State:     websubPending,
+				})
+			if err != nil {
+				return fmt.Errorf("Problem creating WebSub subscription for feed '%s': %v", feed.Name, err)
+			}
+		} else if err != nil {
+			return fmt.Errorf("Problem fetching WebSub subscription for feed '%s': %v", feed.Name, err)
+		} else if websubDue(subscription, feed, now) {
+			// Pending until the hub verifies it again, but still pushed to
+			// us until the current lease runs out
+			subscription.HubUrl = feed.HubUrl.String
+			subscription.TopicUrl = feed.TopicUrl.String
+			err := s.db.UpdateWebSubSubscription(
+				ctx,
+				database.UpdateWebSubSubscriptionParams{
+					ID:        subscription.ID,
+					HubUrl:    subscription.HubUrl,
+					TopicUrl:  subscription.TopicUrl,
+					State:     websubPending,
+					ExpiresAt: subscription.ExpiresAt,
+					UpdatedAt: now,
+				})
+			if err != nil {
+				return fmt.Errorf("Problem updating WebSub subscription for feed '%s': %v", feed.Name, err)
+			}
+		} else {
+			continue
+		}
+
+		// A hub that's down gets asked again after websubRetryAfter
+		callback := strings.TrimSuffix(publicURL, "/") + "/websub/" + subscription.ID.String()
+		if err := requestWebSub(ctx, opts, subscription, callback); err != nil {
+			fmt.Fprintf(os.Stderr, "Problem subscribing to feed '%s' at hub %s: %v\n", feed.Name, subscription.HubUrl, err)
+		} else {
+			fmt.Printf("Asked hub %s to push feed '%s'\n", subscription.HubUrl, feed.Name)
+		}
+	}
+
+	return nil
+}
+
+// Whether to ask the hub for a subscription again
+func websubDue(subscription database.WebsubSubscription, feed database.Feed, now time.Time) bool {
+	if subscription.HubUrl != feed.HubUrl.String || subscription.TopicUrl != feed.TopicUrl.String {
+		return true
+	}
+
+	switch subscription.State {
+	case websubActive:
+		if !subscription.ExpiresAt.Valid {
+			return true
+		}
+		// Verified at UpdatedAt, so that's when the lease started
+		lease := subscription.ExpiresAt.Time.Sub(subscription.UpdatedAt)
+		return now.After(subscription.ExpiresAt.Time.Add(-lease / 4))
+	case websubDenied:
+		return now.Sub(subscription.UpdatedAt) > websubDeniedRetryAfter
+	default:
+		return now.Sub(subscription.UpdatedAt) > websubRetryAfter
+	}
+}
+
+// Asks a hub for a subscription, which it verifies later by calling back
+func requestWebSub(ctx context.Context, opts fetchOptions, subscription database.WebsubSubscription, callback string) error {
+	form := url.Values{
+		"hub.mode":          {"subscribe"},
+		"hub.topic":         {subscription.TopicUrl},
+		"hub.callback":      {callback},
+		"hub.secret":        {subscription.Secret},
+		"hub.lease_seconds": {strconv.Itoa(websubLeaseSeconds)},
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", subscription.HubUrl, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("Error creating request: %s", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("User-Agent", opts.userAgent)
+
+	client := newHTTPClient(opts, opts.responseTimeout)
+	defer client.CloseIdleConnections()
+
+	res, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("Error making request: %s", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		message, _ := io.ReadAll(io.LimitReader(res.Body, 500))
+		return fmt.Errorf("Unexpected response status: %s %s", res.Status, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
+
+// A hub checking we asked for a subscription, or telling us it won't have
+// one. We answer by echoing its challenge.
+func (srv *server) websubVerify(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	subscription, err := srv.websubSubscription(r)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		fmt.Fprintf(os.Stderr, "Problem fetching WebSub subscription: %v\n", err)
+		http.Error(w, "Problem fetching subscription", http.StatusInternalServerError)
+		return
+	}
+	found := err == nil
+
+	now := time.Now()
+	params := database.UpdateWebSubSubscriptionParams{
+		ID:        subscription.ID,
+		HubUrl:    subscription.HubUrl,
+		TopicUrl:  subscription.TopicUrl,
+		UpdatedAt: now,
+	}
+	switch query.Get("hub.mode") {
+	case "subscribe":
+		if !found || query.Get("hub.topic") != subscription.TopicUrl {
+			http.NotFound(w, r)
+			return
+		}
+		lease, err := strconv.Atoi(query.Get("hub.lease_seconds"))
+		if err != nil || lease <= 0 {
+			lease = websubLeaseSeconds
+		}
+		params.State = websubActive
+		params.ExpiresAt = sql.NullTime{Time: now.Add(time.Duration(lease) * time.Second), Valid: true}
+
+	case "unsubscribe":
+		// We never unsubscribe from a subscription we still have
+		if found {
+			http.NotFound(w, r)
+			return
+		}
+
+	case "denied":
+		if !found {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		fmt.Fprintf(os.Stderr, "Hub %s denied subscription to %s: %s\n", subscription.HubUrl, subscription.TopicUrl, query.Get("hub.reason"))
+		params.State = websubDenied
+
+	default:
+		http.Error(w, "Invalid hub.mode", http.StatusBadRequest)
+		return
+	}
+
+	if found {
+		if err := srv.s.db.UpdateWebSubSubscription(r.Context(), params); err != nil {
+			fmt.Fprintf(os.Stderr, "Problem updating WebSub subscription: %v\n", err)
+			http.Error(w, "Problem updating subscription", http.StatusInternalServerError)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	io.WriteString(w, query.Get("hub.challenge"))
+}
+
+// New content from a hub - the whole feed, or just the new items, stored as
+// if we'd fetched them. Content that isn't signed with the subscription's
+// secret is acknowledged but ignored, as the spec asks.
+func (srv *server) websubPush(w http.ResponseWriter, r *http.Request) {
+	subscription, err := srv.websubSubscription(r)
+	if errors.Is(err, sql.ErrNoRows) {
+		// Tells the hub to stop
+		http.Error(w, "No such subscription", http.StatusGone)
+		return
+	} else if err != nil {
+		fmt.Fprintf(os.Stderr, "Problem fetching WebSub subscription: %v\n", err)
+		http.Error(w, "Problem fetching subscription", http.StatusInternalServerError)
+		return
+	}
+
+	opts, err := newFetchOptions(srv.s.cfg.Fetch)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		http.Error(w, "Problem reading content", http.StatusInternalServerError)
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, opts.maxBodySize))
+	if err != nil {
+		http.Error(w, "Problem reading content", http.StatusBadRequest)
+		return
+	}
+
+	// Whatever becomes of it, so the hub doesn't send it again
+	defer w.WriteHeader(http.StatusAccepted)
+	if !validWebSubSignature(r.Header.Get("X-Hub-Signature"), subscription.Secret, body) {
+		fmt.Fprintf(os.Stderr, "Ignoring content pushed for %s without a valid signature\n", subscription.TopicUrl)
+		return
+	}
+
+	feed, err := srv.s.db.GetFeedByID(r.Context(), subscription.FeedID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Problem fetching feed for %s: %v\n", subscription.TopicUrl, err)
+		return
+	}
+	rssFeed, err := parseRSS(body, r.Header.Get("Content-Type"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Problem parsing content pushed for feed '%s': %v\n", feed.Name, err)
+		return
+	}
+	for _, warning := range rssFeed.Warnings {
+		fmt.Fprintf(os.Stderr, "Feed '%s': %s\n", feed.Name, warning)
+	}
+	feedURL, err := url.Parse(feed.Url)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Problem parsing URL of feed '%s': %v\n", feed.Name, err)
+		return
+	}
+
+	newPosts := storePosts(r.Context(), srv.s, feed, feedURL, rssFeed.Channel.Item)
+	fmt.Printf("Feed '%s' pushed %d new posts\n", feed.Name, newPosts)
+}
+
+func (srv *server) websubSubscription(r *http.Request) (database.WebsubSubscription, error) {
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		return database.WebsubSubscription{}, sql.ErrNoRows
+	}
+	return srv.s.db.GetWebSubSubscription(r.Context(), id)
+}
+
+// Checks an X-Hub-Signature header, "<method>=<hex HMAC of the body>"
+func validWebSubSignature(header string, secret string, body []byte) bool {
+	method, signature, found := strings.Cut(header, "=")
+	if !found {
+		return false
+	}
+
+	var newHash func() hash.Hash
+	switch method {
+	case "sha1":
+		newHash = sha1.New
+	case "sha256":
+		newHash = sha256.New
+	case "sha384":
+		newHash = sha512.New384
+	case "sha512":
+		newHash = sha512.New
+	default:
+		return false
+	}
+
+	expected, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(newHash, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/venzy/gator/internal/database"
+)
+
+// A feed advertising a hub, along with the hub, which just records the
+// subscription requests it gets
+type websubPublisher struct {
+	*httptest.Server
+	mu       sync.Mutex
+	requests []url.Values
+}
+
+func newWebSubPublisher(t *testing.T) *websubPublisher {
+	t.Helper()
+
+	publisher := &websubPublisher{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/feed", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/rss+xml")
+		fmt.Fprint(w, publisher.feed("Polled Post"))
+	})
+	mux.HandleFunc("POST /hub", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		publisher.mu.Lock()
+		publisher.requests = append(publisher.requests, r.PostForm)
+		publisher.mu.Unlock()
+		w.WriteHeader(http.StatusAccepted)
+	})
+	publisher.Server = httptest.NewServer(mux)
+	t.Cleanup(publisher.Close)
+	return publisher
+}
+
+func (p *websubPublisher) feed(title string) string {
+	return fmt.Sprintf(`<?xml version="1.0"?>
+<rss version="2.0" xmlns:atom="http://www.w3.org/2005/Atom">
+  <channel>
+    <title>Hubbed</title>
+    <link>https://example.com/</link>
+    <atom:link rel="hub" href="%[1]s/hub"/>
+    <atom:link rel="self" href="%[1]s/feed"/>
+    <item>
+      <title>%[2]s</title>
+      <link>https://example.com/%[2]s</link>
+      <pubDate>Mon, 06 Jan 2025 09:00:00 +0000</pubDate>
+    </item>
+  </channel>
+</rss>`, p.URL, title)
+}
+
+func (p *websubPublisher) lastRequest() (url.Values, int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.requests) == 0 {
+		return nil, 0
+	}
+	return p.requests[len(p.requests)-1], len(p.requests)
+}
+
+// Pushes content to a callback as a hub would, signed with secret
+func websubPush(t *testing.T, callback string, secret string, content string) int {
+	t.Helper()
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(content))
+	req, err := http.NewRequest(http.MethodPost, callback, strings.NewReader(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/rss+xml")
+	req.Header.Set("X-Hub-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	return res.StatusCode
+}
+
+func TestWebSub(t *testing.T) {
+	publisher := newWebSubPublisher(t)
+
+	for name, newState := range testStates {
+		t.Run(name, func(t *testing.T) {
+			s := newState(t)
+			ctx := context.Background()
+			mustRun(t, s, "register", "alice")
+			mustRun(t, s, "addfeed", "Hubbed", publisher.URL+"/feed")
+			captureStdout(t, func() { scapeFeeds(ctx, s) })
+			feed, err := s.db.GetFeedByURL(ctx, publisher.URL+"/feed")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if feed.HubUrl.String != publisher.URL+"/hub" || feed.TopicUrl.String != publisher.URL+"/feed" {
+				t.Fatalf("got hub %v and topic %v, want the feed's links", feed.HubUrl, feed.TopicUrl)
+			}
+
+			api := httptest.NewServer(newServer(s))
+			defer api.Close()
+			captureStdout(t, func() { checkWebSub(ctx, s, api.URL) })
+			request, count := publisher.lastRequest()
+			if request.Get("hub.mode") != "subscribe" || request.Get("hub.topic") != feed.TopicUrl.String || request.Get("hub.secret") == "" {
+				t.Fatalf("got hub request %v, want a subscription to the topic with a secret", request)
+			}
+			callback := request.Get("hub.callback")
+			secret := request.Get("hub.secret")
+			captureStdout(t, func() { checkWebSub(ctx, s, api.URL) })
+			if _, again := publisher.lastRequest(); again != count {
+				t.Error("asked the hub again while waiting for it to verify")
+			}
+
+			verify := func(query url.Values) (string, int) {
+				t.Helper()
+				res, err := http.Get(callback + "?" + query.Encode())
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer res.Body.Close()
+				body, _ := io.ReadAll(res.Body)
+				return string(body), res.StatusCode
+			}
+			if _, status := verify(url.Values{"hub.mode": {"subscribe"}, "hub.topic": {"https://example.com/other"}, "hub.challenge": {"abc"}}); status != http.StatusNotFound {
+				t.Errorf("got status %d verifying another topic, want 404", status)
+			}
+			body, status := verify(url.Values{"hub.mode": {"subscribe"}, "hub.topic": {feed.TopicUrl.String}, "hub.challenge": {"abc"}, "hub.lease_seconds": {"3600"}})
+			if status != http.StatusOK || body != "abc" {
+				t.Fatalf("got %d %q verifying, want the challenge back", status, body)
+			}
+			if _, err := s.db.GetNextFeedToFetch(ctx, sql.NullTime{Time: time.Now(), Valid: true}); !errors.Is(err, sql.ErrNoRows) {
+				t.Errorf("got %v polling for the next feed, want none while it's pushed", err)
+			}
+
+			websubPush(t, callback, "wrong secret", publisher.feed("Forged Post"))
+			if _, err := s.db.GetPostByURL(ctx, "https://example.com/Forged%20Post"); err == nil {
+				t.Error("stored a post pushed with the wrong signature")
+			}
+			captureStdout(t, func() {
+				if status := websubPush(t, callback, secret, publisher.feed("Pushed Post")); status != http.StatusAccepted {
+					t.Errorf("got status %d pushing, want 202", status)
+				}
+			})
+			if _, err := s.db.GetPostByURL(ctx, "https://example.com/Pushed%20Post"); err != nil {
+				t.Errorf("pushed post wasn't stored: %v", err)
+			}
+
+			verify(url.Values{"hub.mode": {"denied"}, "hub.topic": {feed.TopicUrl.String}})
+			if next, err := s.db.GetNextFeedToFetch(ctx, sql.NullTime{Time: time.Now(), Valid: true}); err != nil || next.ID != feed.ID {
+				t.Errorf("got %v polling for the next feed, want it polled again after the hub denied it", err)
+			}
+
+			if status := websubPush(t, api.URL+"/websub/00000000-0000-0000-0000-000000000000", secret, publisher.feed("Gone")); status != http.StatusGone {
+				t.Errorf("got status %d pushing to an unknown subscription, want 410", status)
+			}
+		})
+	}
+}
+
+func TestWebSubDue(t *testing.T) {
+	now := time.Now()
+	feed := database.Feed{
+		HubUrl:   sql.NullString{String: "https://hub.example.com/", Valid: true},
+		TopicUrl: sql.NullString{String: "https://example.com/feed", Valid: true},
+	}
+	subscription := func(state string, updated time.Duration, lease time.Duration) database.WebsubSubscription {
+		return database.WebsubSubscription{
+			HubUrl:    feed.HubUrl.String,
+			TopicUrl:  feed.TopicUrl.String,
+			State:     state,
+			UpdatedAt: now.Add(-updated),
+			ExpiresAt: sql.NullTime{Time: now.Add(-updated + lease), Valid: lease != 0},
+		}
+	}
+	moved := subscription(websubActive, time.Hour, 10*24*time.Hour)
+	moved.HubUrl = "https://old-hub.example.com/"
+
+	tests := []struct {
+		name         string
+		subscription database.WebsubSubscription
+		want         bool
+	}{
+		{"fresh lease", subscription(websubActive, time.Hour, 10*24*time.Hour), false},
+		{"lease three quarters done", subscription(websubActive, 8*24*time.Hour, 10*24*time.Hour), true},
+		{"hub changed", moved, true},
+		{"recently requested", subscription(websubPending, time.Minute, 0), false},
+		{"never verified", subscription(websubPending, 2*time.Hour, 0), true},
+		{"recently denied", subscription(websubDenied, 2*time.Hour, 0), false},
+		{"denied a while ago", subscription(websubDenied, 25*time.Hour, 0), true},
+	}
+	for _, test := range tests {
+		if got := websubDue(test.subscription, feed, now); got != test.want {
+			t.Errorf("%s: got due %v, want %v", test.name, got, test.want)
+		}
+	}
+}